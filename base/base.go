@@ -2,12 +2,14 @@ package base
 
 import (
 	"context"
+	"maps"
 	"net/url"
 	"os"
 	"os/signal"
 	"reflect"
+	"strings"
+	"sync"
 	"syscall"
-	"time"
 
 	"github.com/BurntSushi/toml"
 	"gopkg.cc/apibase/baseconfig"
@@ -15,6 +17,7 @@ import (
 	"gopkg.cc/apibase/db"
 	"gopkg.cc/apibase/email"
 	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/helper"
 	"gopkg.cc/apibase/log"
 	"gopkg.cc/apibase/web"
 )
@@ -24,6 +27,7 @@ type ApiBase[T any] struct {
 	SQLite     db.SQLiteConfig        `toml:"sqlite"`
 	BaseConfig *baseconfig.BaseConfig `toml:"baseconfig"`
 	ApiConfig  web.ApiConfig          `toml:"apiconfig"`
+	Secrets    helper.SecretsConfig   `toml:"secrets"`
 
 	Email     map[string]email.EmailConfig   `toml:"email"`
 	EmailTmpl map[string]email.EmailTemplate `toml:"email_template"`
@@ -31,19 +35,19 @@ type ApiBase[T any] struct {
 	Application T `toml:"application"`
 
 	// Internal Data
-	Interrupt  chan os.Signal
-	CloseChain []chan struct{}
+	Interrupt   chan os.Signal
+	Hangup      chan os.Signal // SIGHUP triggers config reload, see ApiBase.ReloadToml()
+	components  lifecycle
+	settings    cmd.Settings    // cli settings used to load config, required for reload
+	api         *web.ApiServer  // set by ApiBase.StartRest(), required to apply reloaded config
+	configMtx   sync.RWMutex    // protects reloadable config values
+	reloadFuncs []ReloadFunc[T] // see ApiBase.RegisterReloadFunc()
+	sources     helper.ConfigSources
 }
 
 // initialize ApiBase struct without any additional application settings
 func InitApiBase() *ApiBase[struct{}] {
-	apiBase := &ApiBase[struct{}]{}
-
-	// setup interrupt to catch sigint (Ctrl + C)
-	apiBase.Interrupt = make(chan os.Signal, 1)
-	signal.Notify(apiBase.Interrupt, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-
-	return apiBase
+	return InitApiBaseCustom[struct{}]()
 }
 
 // initialze ApiBase with custom config type used for decoding config file with additional application settings
@@ -54,119 +58,204 @@ func InitApiBaseCustom[T any]() *ApiBase[T] {
 	apiBase.Interrupt = make(chan os.Signal, 1)
 	signal.Notify(apiBase.Interrupt, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
-	return apiBase
-}
+	// setup hangup to reload config
+	apiBase.Hangup = make(chan os.Signal, 1)
+	signal.Notify(apiBase.Hangup, syscall.SIGHUP)
 
-// cleanup channel close chain array on error during registering a chain entry
-func (apiBase *ApiBase[T]) CleanupOnError() {
-	ccLength := len(apiBase.CloseChain)
-	if ccLength < 1 {
-		// nothing to clean up
-		return
-	}
-	if ccLength < 2 {
-		log.Logf(log.LevelCritical, "close chain array only contains one channel, this should not happen, closing it and waiting %s for go routine to exit", SLEEP_BROKEN_CLOSE_CHAIN.String())
-		close(apiBase.CloseChain[0])
-		time.Sleep(SLEEP_BROKEN_CLOSE_CHAIN)
-		return
-	}
-	if ccLength == 2 {
-		// if this is the first entry in the channel close chain, close both shutdown and next channels and clear the array,
-		// nothing should happen, since the errored stage shouldn't listen to this anymore
-		close(apiBase.CloseChain[0])
-		close(apiBase.CloseChain[1])
-		apiBase.CloseChain = nil
-		return
-	}
-	// ccLength > 2:
-	// close shutdown channel of errored stage, nothing should happen, since the errored stage shouldn't listen to this anymore
-	close(apiBase.CloseChain[0])
-	// close shutdown channel of previous stage
-	close(apiBase.CloseChain[1])
-	// waiting for last channel in close chain to be closed
-	timeout := apiBase.BaseConfig.TimeoutCloseChainShutdown
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-	select {
-	case <-apiBase.CloseChain[ccLength-1]:
-		log.Log(log.LevelInfo, "all go routines closed")
-	case <-ctx.Done():
-		log.Logf(log.LevelNotice, "timeout for close chain exceeded, not all go routines exited in defined timeout (%s)", timeout.String())
-	}
-
-	// clear CloseChain array, since all channels have been closed
-	apiBase.CloseChain = nil
+	return apiBase
 }
 
 // This shouldn't be used in most cases, WaitAndCleanup() is preferred
 func (apiBase *ApiBase[T]) Cleanup() error {
-	if len(apiBase.CloseChain) < 1 {
-		log.Log(log.LevelWarning, "no close chain found and therefore no go routines can be closed")
-		return nil
-	}
-	if len(apiBase.CloseChain) < 2 {
-		log.Logf(log.LevelCritical, "interrupt received but only one channel found, this should not happen, closing it and waiting %s for go routine to exit", SLEEP_BROKEN_CLOSE_CHAIN.String())
-		close(apiBase.CloseChain[0])
-		time.Sleep(SLEEP_BROKEN_CLOSE_CHAIN)
-		return nil
-	}
-
-	close(apiBase.CloseChain[0])
-	timeout := apiBase.BaseConfig.TimeoutCloseChainShutdown
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	// waiting for last channel in close chain to be closed
-	select {
-	case <-apiBase.CloseChain[len(apiBase.CloseChain)-1]:
-		log.Log(log.LevelInfo, "all go routines closed")
-		return nil
-	case <-ctx.Done():
-		return errx.NewWithTypef(ErrApiBaseCleanup, "timeout for close chain exceeded, not all go routines exited in defined timeout (%s)", timeout.String())
+	if err := apiBase.StopComponents(); err != nil {
+		return err
 	}
+	log.Log(log.LevelInfo, "all components stopped")
+	return nil
 }
 
-// Wait for interrupt and cleanup go routines once received (if any)
+// Wait for interrupt and stop all started components once received (if any),
+// the config is reloaded every time SIGHUP is received while waiting
 func (apiBase *ApiBase[T]) WaitAndCleanup() error {
 	if apiBase.Interrupt == nil {
 		err := apiBase.Cleanup()
 		return errx.WrapWithType(ErrApiBaseCleanup, err, "interrupt channel not initialized, make sure to initialize ApiBase struct correctly, cleaning up")
 	}
-	<-apiBase.Interrupt
-	log.Log(log.LevelNotice, "interrupt received, closing go routines")
-	return apiBase.Cleanup()
+	for {
+		select {
+		case <-apiBase.Hangup:
+			log.Log(log.LevelNotice, "hangup received, reloading config")
+			if err := apiBase.ReloadToml(); err != nil {
+				log.Logf(log.LevelError, "config reload failed, keeping current config: %s", err.Error())
+			}
+		case <-apiBase.Interrupt:
+			log.Log(log.LevelNotice, "interrupt received, stopping components")
+			return apiBase.Cleanup()
+		}
+	}
 }
 
+// Load config file and overlay env vars, see helper.OverlayEnv() for naming and precedence
 func (apiBase *ApiBase[T]) LoadToml(settings cmd.Settings) error {
+	if err := decodeToml(settings, apiBase); err != nil {
+		return err
+	}
+	if err := apiBase.resolveSecrets(); err != nil {
+		return err
+	}
+	apiBase.settings = settings
+	log.SetLogLevel(apiBase.effectiveLogLevel())
+	return apiBase.registerSecretRotation()
+}
+
+// decode config file into target and parse defaults
+func decodeToml[T any](settings cmd.Settings, target *ApiBase[T]) error {
+	if _, problems := decodeTomlProblems(settings, target, true); len(problems) > 0 {
+		return problems[0]
+	}
+	return nil
+}
+
+// configure the default secret manager with [secrets] and fetch every referenced secret
+func (apiBase *ApiBase[T]) resolveSecrets() error {
+	helper.Secrets.ConfigureVault(apiBase.Secrets.VaultAddress, apiBase.Secrets.VaultNamespace, apiBase.Secrets.VaultToken)
+	if err := helper.Secrets.Refresh(context.Background()); err != nil {
+		return errx.WrapWithType(ErrTomlParsing, err, "unable to resolve secret references")
+	}
+	return nil
+}
+
+// decode config file into target and parse defaults, every problem is returned unless stopAtFirst is set.
+// The default secret manager isn't changed, see ApiBase.resolveSecrets().
+// decoded is false if the config file itself can't be read or parsed
+func decodeTomlProblems[T any](settings cmd.Settings, target *ApiBase[T], stopAtFirst bool) (decoded bool, problems []error) {
 	if stat, err := os.Stat(settings.ConfigFile); err != nil || stat.IsDir() {
-		return errx.WrapWithType(ErrTomlParsing, err, "unable to read toml file")
+		return false, []error{errx.WrapWithType(ErrTomlParsing, err, "unable to read toml file")}
 	}
-	if _, err := toml.DecodeFile(settings.ConfigFile, apiBase); err != nil {
-		return errx.WrapWithType(ErrTomlParsing, err, "unable to parse toml")
+	md, err := toml.DecodeFile(settings.ConfigFile, target)
+	if err != nil {
+		return false, []error{errx.WrapWithType(ErrTomlParsing, err, "unable to parse toml")}
+	}
+	target.sources = helper.ConfigSources{}
+	for _, key := range md.Keys() {
+		target.sources[key.String()] = helper.SourceToml
+	}
+	problem := func(err error) bool {
+		if err != nil {
+			problems = append(problems, err)
+		}
+		return err != nil && stopAtFirst
+	}
+	if problem(overlaySecretFiles(settings.ConfigFile, md, target)) {
+		return true, problems
+	}
+	if err := helper.OverlayEnv(target, ENV_PREFIX, target.sources); err != nil && problem(errx.WrapWithType(ErrTomlParsing, err, "unable to apply env overlay")) {
+		return true, problems
+	}
+	for key, source := range target.sources {
+		if source != helper.SourceToml {
+			log.Logf(log.LevelInfo, "config '%s' set from %s", key, source)
+		}
+	}
+	if err := target.Secrets.AddMissingFromDefaults(); err != nil && problem(errx.WrapWithType(ErrTomlParsing, err, "")) {
+		return true, problems
 	}
 	if settings.ApiRoot != "" {
 		if u, err := url.ParseRequestURI(settings.ApiRoot); err == nil && u.Scheme != "" && u.Host != "" {
-			apiBase.ApiConfig.ApiRoot = web.RootOptions{
+			target.ApiConfig.ApiRoot = web.RootOptions{
 				Kind:   "proxy",
 				Target: settings.ApiRoot,
 			}
 		} else {
-			if stat, err := os.Stat(settings.ApiRoot); err != nil || !stat.IsDir() {
-				return errx.WrapWithTypef(ErrApiRootParsing, err, "string doesn't contain path or uri: %s", settings.ApiRoot)
-			}
-			apiBase.ApiConfig.ApiRoot = web.RootOptions{
-				Kind:   "static",
-				Target: settings.ApiRoot,
+			stat, err := os.Stat(settings.ApiRoot)
+			if err != nil || !stat.IsDir() {
+				if problem(errx.WrapWithTypef(ErrApiRootParsing, err, "string doesn't contain path or uri: %s", settings.ApiRoot)) {
+					return true, problems
+				}
+			} else {
+				target.ApiConfig.ApiRoot = web.RootOptions{
+					Kind:   "static",
+					Target: settings.ApiRoot,
+				}
 			}
 		}
 	}
-	if err := apiBase.ParseEmailConfig(); err != nil {
-		return err
+	if problem(target.ParseEmailConfig()) {
+		return true, problems
+	}
+	if target.BaseConfig == nil {
+		target.BaseConfig = &baseconfig.BaseConfig{}
+	}
+	problem(target.BaseConfig.AddMissingFromDefaults())
+	return true, problems
+}
+
+// read secrets from files set by '<key>_file' in config file, e.g. password_file = "/run/secrets/db" in [postgres]
+func overlaySecretFiles[T any](configFile string, md toml.MetaData, target *ApiBase[T]) error {
+	undecoded := md.Undecoded()
+	if len(undecoded) < 1 {
+		return nil
 	}
-	if apiBase.BaseConfig == nil {
-		apiBase.BaseConfig = &baseconfig.BaseConfig{}
+	raw := map[string]any{}
+	if _, err := toml.DecodeFile(configFile, &raw); err != nil {
+		return errx.WrapWithType(ErrTomlParsing, err, "unable to parse toml")
 	}
-	return apiBase.BaseConfig.AddMissingFromDefaults()
+	for _, key := range undecoded {
+		last := key[len(key)-1]
+		if !strings.HasSuffix(last, "_file") {
+			continue
+		}
+		var value any = raw
+		for _, part := range key {
+			table, ok := value.(map[string]any)
+			if !ok {
+				value = nil
+				break
+			}
+			value = table[part]
+		}
+		path, ok := value.(string)
+		if !ok {
+			return errx.NewWithTypef(ErrTomlParsing, "'%s' must be a file path", key.String())
+		}
+		secretKey := strings.TrimSuffix(key.String(), "_file")
+		if err := helper.SetSecretFromFile(target, secretKey, path); err != nil {
+			return errx.WrapWithType(ErrTomlParsing, err, "unable to read secret file")
+		}
+		target.sources[secretKey] = helper.SourceFile + " " + path
+	}
+	return nil
+}
+
+// Get effective source of every config value that isn't set from defaults, mapped by dotted toml key, e.g. "postgres.password": "env APIBASE_POSTGRES_PASSWORD"
+func (apiBase *ApiBase[T]) ConfigSources() helper.ConfigSources {
+	apiBase.configMtx.RLock()
+	defer apiBase.configMtx.RUnlock()
+	return maps.Clone(apiBase.sources)
+}
+
+// log level of the cli flag -v if given, otherwise log_level of the config, so a reloaded log_level is applied
+func (apiBase *ApiBase[T]) effectiveLogLevel() log.Level {
+	if apiBase.settings.VerbositySet {
+		return apiBase.settings.GetLogLevel()
+	}
+	return apiBase.BaseConfig.LogLevel
+}
+
+// Get email sender config by key of [email.<key>] config section, thread safe
+func (apiBase *ApiBase[T]) GetEmailConfig(key string) (email.EmailConfig, bool) {
+	apiBase.configMtx.RLock()
+	defer apiBase.configMtx.RUnlock()
+	ec, ok := apiBase.Email[key]
+	return ec, ok
+}
+
+// Get email template by key of [email_template.<key>] config section, thread safe
+func (apiBase *ApiBase[T]) GetEmailTemplate(key string) (email.EmailTemplate, bool) {
+	apiBase.configMtx.RLock()
+	defer apiBase.configMtx.RUnlock()
+	tmpl, ok := apiBase.EmailTmpl[key]
+	return tmpl, ok
 }
 
 func (apiBase *ApiBase[T]) ParseEmailConfig() error {
@@ -0,0 +1,80 @@
+package base
+
+import (
+	"embed"
+
+	"gopkg.cc/apibase/cmd"
+	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/log"
+	"gopkg.cc/apibase/web"
+)
+
+// Load config like LoadToml() and validate it like SetupRest() does, without connecting to the database.
+// Every problem found is returned instead of only the first one, values that can't be parsed are reported instead of replaced by defaults.
+// The loaded config (with defaults added) is kept, unless decoded is false because the config file itself can't be read or parsed
+func (apiBase *ApiBase[T]) CheckToml(settings cmd.Settings, embedFS *embed.FS) (decoded bool, problems []error) {
+	decoded, problems = decodeTomlProblems(settings, apiBase, false)
+	if !decoded {
+		return false, problems
+	}
+	apiBase.settings = settings
+	if err := apiBase.resolveSecrets(); err != nil {
+		problems = append(problems, err)
+	}
+	for _, err := range helper.CheckTomlConfig(apiBase.BaseConfig) {
+		problems = append(problems, wrapConfigProblem(err, "baseconfig"))
+	}
+	if embedFS != nil && apiBase.ApiConfig.ApiRoot.Kind == web.FsEmbed {
+		if err := apiBase.ApiConfig.RegisterEmbedFS(*embedFS); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	for _, err := range apiBase.ApiConfig.Validate() {
+		problems = append(problems, wrapConfigProblem(err, "apiconfig"))
+	}
+	if apiBase.ApiConfig.Settings == nil {
+		apiBase.ApiConfig.Settings = &web.ApiConfigSettings{}
+	}
+	if err := apiBase.ApiConfig.Settings.AddMissingFromDefaults(); err != nil {
+		problems = append(problems, wrapConfigProblem(err, "apiconfig.settings"))
+	}
+	if apiBase.Postgres.Host != "" {
+		if _, err := apiBase.Postgres.ConnString(); err != nil {
+			problems = append(problems, wrapConfigProblem(err, "postgres"))
+		} else if _, err := apiBase.Postgres.ReplicaConfigs(); err != nil {
+			problems = append(problems, wrapConfigProblem(err, "postgres.replicas"))
+		}
+		if apiBase.Postgres.Pool == nil {
+			apiBase.Postgres.Pool = &db.PostgresPoolConfig{}
+		}
+		for _, err := range helper.CheckTomlConfig(apiBase.Postgres.Pool) {
+			problems = append(problems, wrapConfigProblem(err, "postgres.pool"))
+		}
+		if err := apiBase.Postgres.Pool.AddMissingFromDefaults(); err != nil {
+			problems = append(problems, wrapConfigProblem(err, "postgres.pool"))
+		}
+	}
+	if apiBase.SQLite.FilePath == "" && apiBase.Postgres.Host == "" {
+		problems = append(problems, errx.NewWithType(ErrConfigInvalid, "no database configured, either [sqlite] file_path or [postgres] host must be set"))
+	}
+	return true, problems
+}
+
+// Load and check config (see CheckToml()) and run cli command (see cmd.ConfigRun()) with the effective config
+func (apiBase *ApiBase[T]) RunConfigCommand(settings cmd.Settings, fn cmd.ConfigCommandFunc, embedFS *embed.FS) error {
+	if settings.Verbosity < 1 {
+		// warnings about defaults are reported as problems instead
+		log.SetLogLevel(log.LevelError)
+	}
+	decoded, problems := apiBase.CheckToml(settings, embedFS)
+	if !decoded {
+		return fn(nil, nil, problems)
+	}
+	return fn(helper.EffectiveToml(apiBase), apiBase.ConfigSources(), problems)
+}
+
+func wrapConfigProblem(err error, section string) error {
+	return errx.WrapWithTypef(ErrConfigInvalid, err, "[%s]", section)
+}
@@ -3,5 +3,8 @@ package base
 import "time"
 
 const (
-	SLEEP_BROKEN_CLOSE_CHAIN = time.Second
+	// used for component timeouts, if ApiBase.BaseConfig isn't loaded yet
+	DEFAULT_TIMEOUT_COMPONENT = time.Second * 30
+	// env vars overwriting config values are named ENV_PREFIX + '_' + toml key, e.g. APIBASE_POSTGRES_PASSWORD
+	ENV_PREFIX = "APIBASE"
 )
@@ -3,8 +3,13 @@ package base
 import "gopkg.cc/apibase/errx"
 
 var (
-	ErrTomlParsing    = errx.NewType("toml parsing failed")
-	ErrApiBaseCleanup = errx.NewType("issue with Apibase cleanup")
-	ErrApiRootParsing = errx.NewType("invalid api root cli arg")
-	ErrEmailParsing   = errx.NewType("unable to parse email config")
+	ErrTomlParsing         = errx.NewType("toml parsing failed")
+	ErrApiBaseCleanup      = errx.NewType("issue with Apibase cleanup")
+	ErrApiRootParsing      = errx.NewType("invalid api root cli arg")
+	ErrEmailParsing        = errx.NewType("unable to parse email config")
+	ErrComponentRegister   = errx.NewType("unable to register component")
+	ErrComponentDependency = errx.NewType("unable to resolve component dependencies")
+	ErrComponentStart      = errx.NewType("component startup failed")
+	ErrRun                 = errx.NewType("apibase startup failed")
+	ErrConfigInvalid       = errx.NewType("invalid config")
 )
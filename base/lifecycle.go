@@ -0,0 +1,265 @@
+package base
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/log"
+)
+
+const (
+	ComponentPostgres = "postgres"
+	ComponentSQLite   = "sqlite"
+	ComponentRest     = "rest"
+	ComponentSecrets  = "secrets"
+	ComponentCron     = "cron"
+)
+
+type (
+	// Must be non-blocking, long running work needs to be started in its own go routine.
+	// The context is canceled once the start timeout of the component is exceeded, a start function returning later
+	// must not publish its results, since the component is stopped again if it returns nil.
+	ComponentStartFunc func(ctx context.Context) error
+	// Must return once the component has been shut down.
+	// The context is canceled once the stop timeout of the component is exceeded.
+	ComponentStopFunc func(ctx context.Context) error
+)
+
+// Component is any part of the application that needs to be started and gracefully stopped,
+// e.g. database connection, rest api server, scheduled tasks or own application go routines
+type Component struct {
+	Name      string             // unique name, used for dependencies and to report failing components
+	Start     ComponentStartFunc // required
+	Stop      ComponentStopFunc  // optional, if nil nothing is done on shutdown
+	DependsOn []string           // names of components that must be started before and stopped after this component
+
+	TimeoutStart time.Duration // if zero, BaseConfig.TimeoutComponentStartup is used
+	TimeoutStop  time.Duration // if zero, BaseConfig.TimeoutCloseChainShutdown is used
+}
+
+type lifecycle struct {
+	sync.Mutex
+	pending []Component // registered but not yet started, in order of registration
+	started []Component // in order of startup, stopped in reverse order
+}
+
+func (l *lifecycle) isRegistered(name string) bool {
+	hasName := func(c Component) bool { return c.Name == name }
+	return slices.ContainsFunc(l.pending, hasName) || slices.ContainsFunc(l.started, hasName)
+}
+
+// Register a component, which will be started by the next call to ApiBase.StartComponents().
+// Dependencies don't need to be registered yet, they are only resolved during startup
+func (apiBase *ApiBase[T]) RegisterComponent(c Component) error {
+	if c.Name == "" {
+		return errx.NewWithType(ErrComponentRegister, "component name must not be empty")
+	}
+	if c.Start == nil {
+		return errx.NewWithTypef(ErrComponentRegister, "component '%s' has no start function", c.Name)
+	}
+	if slices.Contains(c.DependsOn, c.Name) {
+		return errx.NewWithTypef(ErrComponentRegister, "component '%s' must not depend on itself", c.Name)
+	}
+	apiBase.components.Lock()
+	defer apiBase.components.Unlock()
+	if apiBase.components.isRegistered(c.Name) {
+		return errx.NewWithTypef(ErrComponentRegister, "component '%s' is already registered", c.Name)
+	}
+	apiBase.components.pending = append(apiBase.components.pending, c)
+	return nil
+}
+
+// Returns true if a component with this name was registered, regardless if it was already started
+func (apiBase *ApiBase[T]) HasComponent(name string) bool {
+	apiBase.components.Lock()
+	defer apiBase.components.Unlock()
+	return apiBase.components.isRegistered(name)
+}
+
+// Start all registered components that haven't been started yet in dependency order.
+// If any component fails to start or exceeds its start timeout, all already started components are stopped again
+func (apiBase *ApiBase[T]) StartComponents() error {
+	apiBase.components.Lock()
+	defer apiBase.components.Unlock()
+
+	order, err := apiBase.components.startOrder()
+	if err != nil {
+		apiBase.components.pending = nil
+		if stopErr := apiBase.stopComponentsLocked(); stopErr != nil {
+			log.Log(log.LevelError, stopErr.Error())
+		}
+		return err
+	}
+	apiBase.components.pending = nil
+
+	for i, c := range order {
+		timeout := apiBase.componentTimeoutStart(c)
+		log.Logf(log.LevelDebug, "starting component '%s'", c.Name)
+		err, late := runWithTimeout(c.Start, timeout)
+		if err == nil {
+			apiBase.components.started = append(apiBase.components.started, c)
+			continue
+		}
+		if late != nil {
+			go apiBase.stopLateStart(c, late)
+			err = errx.NewWithTypef(ErrComponentStart, "component '%s' didn't start within timeout (%s)", c.Name, timeout.String())
+		} else {
+			err = errx.WrapWithTypef(ErrComponentStart, err, "component '%s'", c.Name)
+		}
+		if skipped := order[i+1:]; len(skipped) > 0 {
+			names := []string{}
+			for _, s := range skipped {
+				names = append(names, s.Name)
+			}
+			log.Logf(log.LevelWarning, "not starting component(s) '%s', because '%s' failed", strings.Join(names, "', '"), c.Name)
+		}
+		if stopErr := apiBase.stopComponentsLocked(); stopErr != nil {
+			log.Log(log.LevelError, stopErr.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// Stop all started components in reverse startup order, every component is stopped,
+// even if stopping a previous one failed or exceeded its timeout
+func (apiBase *ApiBase[T]) StopComponents() error {
+	apiBase.components.Lock()
+	defer apiBase.components.Unlock()
+	return apiBase.stopComponentsLocked()
+}
+
+func (apiBase *ApiBase[T]) stopComponentsLocked() error {
+	started := apiBase.components.started
+	apiBase.components.started = nil
+
+	failed := []string{}
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		if c.Stop == nil {
+			continue
+		}
+		timeout := apiBase.componentTimeoutStop(c)
+		err, late := runWithTimeout(c.Stop, timeout)
+		if late != nil {
+			log.Logf(log.LevelError, "component '%s' didn't stop within timeout (%s)", c.Name, timeout.String())
+			failed = append(failed, c.Name+" (timeout)")
+			continue
+		}
+		if err != nil {
+			log.Logf(log.LevelError, "component '%s' failed to stop: %s", c.Name, err.Error())
+			failed = append(failed, c.Name)
+			continue
+		}
+		log.Logf(log.LevelDebug, "component '%s' stopped", c.Name)
+	}
+	if len(failed) > 0 {
+		return errx.NewWithTypef(ErrApiBaseCleanup, "component(s) not stopped cleanly: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Resolve startup order of pending components, keeping registration order where no dependencies exist
+func (l *lifecycle) startOrder() ([]Component, error) {
+	started := map[string]bool{}
+	for _, c := range l.started {
+		started[c.Name] = true
+	}
+	pending := map[string]bool{}
+	for _, c := range l.pending {
+		pending[c.Name] = true
+	}
+	for _, c := range l.pending {
+		for _, dep := range c.DependsOn {
+			if !started[dep] && !pending[dep] {
+				return nil, errx.NewWithTypef(ErrComponentDependency, "component '%s' depends on unregistered component '%s'", c.Name, dep)
+			}
+		}
+	}
+
+	order := []Component{}
+	remaining := slices.Clone(l.pending)
+	for len(remaining) > 0 {
+		progress := false
+		for i := 0; i < len(remaining); i++ {
+			c := remaining[i]
+			ready := true
+			for _, dep := range c.DependsOn {
+				if !started[dep] {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+			order = append(order, c)
+			started[c.Name] = true
+			remaining = slices.Delete(remaining, i, i+1)
+			progress = true
+			break
+		}
+		if !progress {
+			names := []string{}
+			for _, c := range remaining {
+				names = append(names, c.Name)
+			}
+			return nil, errx.NewWithTypef(ErrComponentDependency, "dependency cycle between components '%s'", strings.Join(names, "', '"))
+		}
+	}
+	return order, nil
+}
+
+func (apiBase *ApiBase[T]) componentTimeoutStart(c Component) time.Duration {
+	if c.TimeoutStart > 0 {
+		return c.TimeoutStart
+	}
+	if apiBase.BaseConfig == nil {
+		return DEFAULT_TIMEOUT_COMPONENT
+	}
+	return apiBase.BaseConfig.TimeoutComponentStartup
+}
+
+func (apiBase *ApiBase[T]) componentTimeoutStop(c Component) time.Duration {
+	if c.TimeoutStop > 0 {
+		return c.TimeoutStop
+	}
+	if apiBase.BaseConfig == nil {
+		return DEFAULT_TIMEOUT_COMPONENT
+	}
+	return apiBase.BaseConfig.TimeoutCloseChainShutdown
+}
+
+// stop component whose start function returned successfully after its start timeout, it isn't part of the started components
+func (apiBase *ApiBase[T]) stopLateStart(c Component, late <-chan error) {
+	if err := <-late; err != nil || c.Stop == nil {
+		return
+	}
+	log.Logf(log.LevelWarning, "component '%s' started after its timeout, stopping it", c.Name)
+	timeout := apiBase.componentTimeoutStop(c)
+	if err, late := runWithTimeout(c.Stop, timeout); late != nil {
+		log.Logf(log.LevelError, "component '%s' didn't stop within timeout (%s)", c.Name, timeout.String())
+	} else if err != nil {
+		log.Logf(log.LevelError, "component '%s' failed to stop: %s", c.Name, err.Error())
+	}
+}
+
+// late is set if fn didn't return within timeout, it receives the result of fn once it returns
+func runWithTimeout(fn func(ctx context.Context) error, timeout time.Duration) (err error, late <-chan error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err, nil
+	case <-ctx.Done():
+		return ctx.Err(), done
+	}
+}
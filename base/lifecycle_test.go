@@ -0,0 +1,146 @@
+package base_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.cc/apibase/base"
+)
+
+type recorder struct {
+	sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.Lock()
+	r.events = append(r.events, event)
+	r.Unlock()
+}
+
+func (r *recorder) component(name string, dependsOn ...string) base.Component {
+	return base.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			r.add("start " + name)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			r.add("stop " + name)
+			return nil
+		},
+	}
+}
+
+func TestComponentOrder(t *testing.T) {
+	r := &recorder{}
+	apiBase := base.InitApiBase()
+	for _, c := range []base.Component{
+		r.component("cron", "postgres"),
+		r.component("rest", "postgres"),
+		r.component("postgres"),
+	} {
+		if err := apiBase.RegisterComponent(c); err != nil {
+			t.Fatalf("RegisterComponent(%s) = %v", c.Name, err)
+		}
+	}
+	if err := apiBase.StartComponents(); err != nil {
+		t.Fatalf("StartComponents() = %v", err)
+	}
+	if err := apiBase.StopComponents(); err != nil {
+		t.Fatalf("StopComponents() = %v", err)
+	}
+
+	want := []string{"start postgres", "start cron", "start rest", "stop rest", "stop cron", "stop postgres"}
+	if !slices.Equal(r.events, want) {
+		t.Errorf("events = %v; want %v", r.events, want)
+	}
+}
+
+func TestComponentStartFailure(t *testing.T) {
+	r := &recorder{}
+	apiBase := base.InitApiBase()
+	failing := r.component("rest", "postgres")
+	failing.Start = func(ctx context.Context) error { return errors.New("bind failed") }
+	apiBase.RegisterComponent(r.component("postgres"))
+	apiBase.RegisterComponent(failing)
+	apiBase.RegisterComponent(r.component("cron", "rest"))
+
+	err := apiBase.StartComponents()
+	if !errors.Is(err, base.ErrComponentStart) {
+		t.Fatalf("StartComponents() = %v; want ErrComponentStart", err)
+	}
+	want := []string{"start postgres", "stop postgres"}
+	if !slices.Equal(r.events, want) {
+		t.Errorf("events = %v; want %v", r.events, want)
+	}
+}
+
+func TestComponentTimeouts(t *testing.T) {
+	apiBase := base.InitApiBase()
+	apiBase.RegisterComponent(base.Component{
+		Name:        "worker",
+		Start:       func(ctx context.Context) error { return nil },
+		Stop:        func(ctx context.Context) error { time.Sleep(time.Second); return nil },
+		TimeoutStop: 10 * time.Millisecond,
+	})
+	if err := apiBase.StartComponents(); err != nil {
+		t.Fatalf("StartComponents() = %v", err)
+	}
+	if err := apiBase.StopComponents(); !errors.Is(err, base.ErrApiBaseCleanup) {
+		t.Errorf("StopComponents() = %v; want ErrApiBaseCleanup", err)
+	}
+
+	apiBase.RegisterComponent(base.Component{
+		Name:         "hanging",
+		Start:        func(ctx context.Context) error { <-ctx.Done(); time.Sleep(time.Second); return nil },
+		TimeoutStart: 10 * time.Millisecond,
+	})
+	if err := apiBase.StartComponents(); !errors.Is(err, base.ErrComponentStart) {
+		t.Errorf("StartComponents() = %v; want ErrComponentStart", err)
+	}
+
+	stopped := make(chan struct{})
+	apiBase.RegisterComponent(base.Component{
+		Name:         "late",
+		Start:        func(ctx context.Context) error { <-ctx.Done(); return nil },
+		Stop:         func(ctx context.Context) error { close(stopped); return nil },
+		TimeoutStart: 10 * time.Millisecond,
+	})
+	if err := apiBase.StartComponents(); !errors.Is(err, base.ErrComponentStart) {
+		t.Errorf("StartComponents() = %v; want ErrComponentStart", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Errorf("component started after its timeout wasn't stopped")
+	}
+}
+
+func TestComponentDependencies(t *testing.T) {
+	r := &recorder{}
+	apiBase := base.InitApiBase()
+	apiBase.RegisterComponent(r.component("a", "b"))
+	apiBase.RegisterComponent(r.component("b", "a"))
+	if err := apiBase.StartComponents(); !errors.Is(err, base.ErrComponentDependency) {
+		t.Errorf("StartComponents() with cycle = %v; want ErrComponentDependency", err)
+	}
+
+	apiBase.RegisterComponent(r.component("c", "missing"))
+	if err := apiBase.StartComponents(); !errors.Is(err, base.ErrComponentDependency) {
+		t.Errorf("StartComponents() with missing dependency = %v; want ErrComponentDependency", err)
+	}
+
+	apiBase.RegisterComponent(r.component("d"))
+	if err := apiBase.RegisterComponent(r.component("d")); !errors.Is(err, base.ErrComponentRegister) {
+		t.Errorf("RegisterComponent() duplicate = %v; want ErrComponentRegister", err)
+	}
+	if len(r.events) != 0 {
+		t.Errorf("events = %v; want none", r.events)
+	}
+}
@@ -0,0 +1,134 @@
+package base
+
+import (
+	"context"
+	"strings"
+
+	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/log"
+	"gopkg.cc/apibase/web"
+)
+
+// Called with the newly parsed application config on reload, returning an error keeps the current application config
+type ReloadFunc[T any] func(app T) error
+
+// config keys (or key prefixes) that are applied on reload without restart, any other change requires a restart
+var reloadableConfig = []string{
+	"baseconfig.log_level",
+	"apiconfig.cors",
+	"apiconfig.settings.token_access_validity",
+	"apiconfig.settings.token_refresh_validity",
+	"apiconfig.settings.token_cookie_expiry_margin",
+	"apiconfig.settings.token_access_renew_margin",
+	"apiconfig.settings.token_refresh_renew_margin",
+	"email",
+	"email_template",
+}
+
+// Register function that applies reloaded [application] config, without any registered function
+// changes to the application config require a restart
+func (apiBase *ApiBase[T]) RegisterReloadFunc(reload ReloadFunc[T]) {
+	apiBase.configMtx.Lock()
+	defer apiBase.configMtx.Unlock()
+	apiBase.reloadFuncs = append(apiBase.reloadFuncs, reload)
+}
+
+// Reload config file that was used with ApiBase.LoadToml(), only reloadable values are applied,
+// changed values that require a restart are logged. This is done automatically on SIGHUP if ApiBase.WaitAndCleanup() is used
+func (apiBase *ApiBase[T]) ReloadToml() error {
+	if apiBase.settings.ConfigFile == "" {
+		return errx.NewWithType(ErrTomlParsing, "no config file loaded, use LoadToml() first")
+	}
+	newBase := &ApiBase[T]{}
+	if err := decodeToml(apiBase.settings, newBase); err != nil {
+		return err
+	}
+	if newBase.ApiConfig.Settings == nil {
+		newBase.ApiConfig.Settings = &web.ApiConfigSettings{}
+	}
+	if err := newBase.ApiConfig.Settings.AddMissingFromDefaults(); err != nil {
+		return err
+	}
+	if len(newBase.ApiConfig.CORS) < 1 {
+		newBase.ApiConfig.CORS = []string{"*"}
+	}
+
+	apiBase.configMtx.RLock()
+	changes := helper.DiffToml(apiBase, newBase)
+	reloadFuncs := apiBase.reloadFuncs
+	apiBase.configMtx.RUnlock()
+
+	applied := []string{}
+	for _, key := range changes {
+		switch {
+		case isReloadable(key):
+			applied = append(applied, key)
+		case strings.HasPrefix(key, "application") && len(reloadFuncs) > 0:
+			applied = append(applied, key)
+		default:
+			log.Logf(log.LevelWarning, "config reload: '%s' changed, restart required to apply", key)
+		}
+	}
+	// keyring file is always reloaded, since it is changed by cli command 'keyring'
+	if apiBase.api != nil {
+		if err := apiBase.api.LoadKeyringFile(); err != nil {
+			log.Logf(log.LevelError, "config reload: keeping current keyring: %s", err.Error())
+		}
+	}
+	if len(applied) < 1 {
+		log.Log(log.LevelNotice, "config reload: no reloadable changes found")
+		return nil
+	}
+	// secrets config requires a restart, only references added by the reloaded config are fetched
+	if err := helper.Secrets.RefreshNew(context.Background()); err != nil {
+		return errx.WrapWithType(ErrTomlParsing, err, "unable to resolve secret references")
+	}
+
+	for _, reload := range reloadFuncs {
+		if err := reload(newBase.Application); err != nil {
+			return errx.Wrap(err, "application config reload failed")
+		}
+	}
+
+	apiBase.configMtx.Lock()
+	apiBase.BaseConfig.LogLevel = newBase.BaseConfig.LogLevel
+	apiBase.BaseConfig.TomlLogLevel = newBase.BaseConfig.TomlLogLevel
+	apiBase.Email = newBase.Email
+	apiBase.sources = newBase.sources
+	apiBase.EmailTmpl = newBase.EmailTmpl
+	if len(reloadFuncs) > 0 {
+		apiBase.Application = newBase.Application
+	}
+	apiBase.ApiConfig.CORS = newBase.ApiConfig.CORS
+	if apiBase.ApiConfig.Settings == nil {
+		apiBase.ApiConfig.Settings = &web.ApiConfigSettings{}
+	}
+	// timeouts are only used on startup/shutdown and the purge task is scheduled on startup, keep current values
+	settings := *newBase.ApiConfig.Settings
+	current := apiBase.ApiConfig.Settings
+	settings.TomlTimeoutSubprocStartup, settings.TimeoutSubprocStartup = current.TomlTimeoutSubprocStartup, current.TimeoutSubprocStartup
+	settings.TomlTimeoutSubprocShutdown, settings.TimeoutSubprocShutdown = current.TomlTimeoutSubprocShutdown, current.TimeoutSubprocShutdown
+	settings.TomlTimeoutScheduledTaskStartup, settings.TimeoutScheduledTaskStartup = current.TomlTimeoutScheduledTaskStartup, current.TimeoutScheduledTaskStartup
+	settings.TomlTimeoutScheduledTaskShutdown, settings.TimeoutScheduledTaskShutdown = current.TomlTimeoutScheduledTaskShutdown, current.TimeoutScheduledTaskShutdown
+	settings.TomlPurgeDeletedAfter, settings.PurgeDeletedAfter = current.TomlPurgeDeletedAfter, current.PurgeDeletedAfter
+	settings.TomlPurgeInterval, settings.PurgeInterval = current.TomlPurgeInterval, current.PurgeInterval
+	apiBase.ApiConfig.Settings = &settings
+	apiBase.configMtx.Unlock()
+
+	log.SetLogLevel(apiBase.effectiveLogLevel())
+	if apiBase.api != nil {
+		apiBase.api.Reload(newBase.ApiConfig.CORS, &settings)
+	}
+	log.Logf(log.LevelNotice, "config reload: applied %s", strings.Join(applied, ", "))
+	return nil
+}
+
+func isReloadable(key string) bool {
+	for _, prefix := range reloadableConfig {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package base_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.cc/apibase/base"
+	"gopkg.cc/apibase/cmd"
+	"gopkg.cc/apibase/helper"
+)
+
+type reloadApp struct {
+	Name   string              `toml:"name"`
+	Secret helper.SecretString `toml:"secret"`
+}
+
+func TestReloadToml(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" || r.URL.Path != "/v1/kv/data/reload" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"data": map[string]any{"secret": "s3cret"}},
+		})
+	}))
+	defer server.Close()
+	// configured by LoadToml() through helper.Secrets, vault references aren't used since they would stay tracked for later tests
+	vault := &helper.VaultSecretProvider{}
+	helper.Secrets.RegisterProvider("vault", vault)
+	defer helper.Secrets.RegisterProvider("vault", &helper.VaultSecretProvider{})
+	vaultConfigured := func() bool {
+		secret, err := vault.Fetch(context.Background(), "kv/reload#secret")
+		return err == nil && secret == "s3cret"
+	}
+
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	writeConfig := func(vaultAddress string, name string, purgeInterval string) {
+		config := "[apiconfig.settings]\npurge_interval = \"" + purgeInterval + "\"\n\n" +
+			"[secrets]\nvault_address = \"" + vaultAddress + "\"\nvault_token = \"test-token\"\n\n" +
+			"[application]\nname = \"" + name + "\"\nsecret = \"${env:PATH}\"\n"
+		if err := os.WriteFile(configFile, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(server.URL, "first", "1h")
+
+	apiBase := base.InitApiBaseCustom[reloadApp]()
+	if err := apiBase.LoadToml(cmd.Settings{ConfigFile: configFile}); err != nil {
+		t.Fatalf("LoadToml() = %v", err)
+	}
+	if !vaultConfigured() || apiBase.Application.Secret.GetSecret() != os.Getenv("PATH") {
+		t.Fatalf("LoadToml() didn't configure vault or resolve secret references")
+	}
+
+	var reloadErr error
+	apiBase.RegisterReloadFunc(func(app reloadApp) error { return reloadErr })
+
+	// changed vault address and purge interval require a restart, the secret manager must not be reconfigured
+	writeConfig("http://127.0.0.1:1", "second", "2h")
+	reloadErr = errors.New("rejected")
+	if err := apiBase.ReloadToml(); err == nil {
+		t.Errorf("ReloadToml() with failing reload func = nil; want error")
+	}
+	if apiBase.Application.Name != "first" {
+		t.Errorf("Application.Name after failed reload = %s; want first", apiBase.Application.Name)
+	}
+	if !vaultConfigured() {
+		t.Errorf("vault reconfigured by failed reload")
+	}
+
+	reloadErr = nil
+	if err := apiBase.ReloadToml(); err != nil {
+		t.Fatalf("ReloadToml() = %v", err)
+	}
+	if apiBase.Application.Name != "second" {
+		t.Errorf("Application.Name after reload = %s; want second", apiBase.Application.Name)
+	}
+	if settings := apiBase.ApiConfig.Settings; settings.TomlPurgeInterval != "1h" || settings.PurgeInterval == time.Hour*2 {
+		t.Errorf("ApiConfig.Settings.PurgeInterval after reload = %s, %s; want 1h until restart", settings.TomlPurgeInterval, settings.PurgeInterval)
+	}
+	if apiBase.Secrets.VaultAddress != server.URL || !vaultConfigured() {
+		t.Errorf("vault reconfigured by reload, vault_address = %s; want %s", apiBase.Secrets.VaultAddress, server.URL)
+	}
+}
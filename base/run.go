@@ -0,0 +1,127 @@
+package base
+
+import (
+	"context"
+	"embed"
+
+	"github.com/spf13/cobra"
+	"gopkg.cc/apibase/cmd"
+	"gopkg.cc/apibase/cron"
+	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/log"
+	"gopkg.cc/apibase/web"
+	"gopkg.cc/apibase/web_setup"
+)
+
+// Options for ApiBase.Run(), every callback is optional
+type RunOptions[T any] struct {
+	CLI     cmd.CmdConfig // app name, version and default config path, version is also returned by /api/version
+	EmbedFS *embed.FS     // required if api_root kind is embedfs
+
+	// add own cli commands, called before cli args are parsed
+	ConfigureCLI func(root *cobra.Command)
+	// register hooks (see package hook), called once config is loaded and before the rest api is set up
+	RegisterHooks func(apiBase *ApiBase[T]) error
+	// see web.AccessClaimDataFunc
+	AccessClaimDataFunc web.AccessClaimDataFunc
+	// register own routes with api.Api (/api/<route>) or api.E, called before the rest api is started
+	RegisterRoutes func(apiBase *ApiBase[T], api *web.ApiServer) error
+	// cron.TaskFunc for every task type of scheduled tasks saved in database, startup fails if a function is missing
+	TaskFuncs map[string]cron.TaskFunc
+	// called once all components are started
+	Started func(apiBase *ApiBase[T], api *web.ApiServer) error
+}
+
+// Run the whole apibase stack: parse cli args, load config, connect to database (see DatabaseInit()), set up the rest api,
+// start scheduled tasks and the rest api, then wait for interrupt and stop all components in reverse order.
+// Returns nil if program exits normally, e.g. cli arg --help or --version
+func (apiBase *ApiBase[T]) Run(opts RunOptions[T]) error {
+	root := cmd.ConfigureCLI(opts.CLI)
+	if opts.ConfigureCLI != nil {
+		opts.ConfigureCLI(root)
+	}
+	settings, exit := cmd.Execute(root)
+	if exit {
+		return nil
+	}
+	if settings.ConfigCommand != nil {
+		return apiBase.RunConfigCommand(settings, settings.ConfigCommand, opts.EmbedFS)
+	}
+	if err := apiBase.LoadToml(settings); err != nil {
+		return err
+	}
+	if opts.RegisterHooks != nil {
+		if err := opts.RegisterHooks(apiBase); err != nil {
+			return errx.WrapWithType(ErrRun, err, "unable to register hooks")
+		}
+	}
+	if settings.DatabaseCommand != nil {
+		return apiBase.RunDatabaseCommand(settings.DatabaseCommand)
+	}
+	if opts.EmbedFS != nil {
+		if err := apiBase.ApiConfig.RegisterEmbedFS(*opts.EmbedFS); err != nil {
+			return errx.WrapWithType(ErrRun, err, "")
+		}
+	}
+
+	database, err := apiBase.DatabaseInit()
+	if err != nil {
+		return err
+	}
+	api, err := web_setup.SetupRest(apiBase.ApiConfig, database, opts.CLI.Version)
+	if err != nil {
+		return apiBase.cleanupAfter(err, "unable to setup rest api")
+	}
+	if opts.AccessClaimDataFunc != nil {
+		api.RegisterAccessClaimDataFunc(opts.AccessClaimDataFunc)
+	}
+	if opts.RegisterRoutes != nil {
+		if err := opts.RegisterRoutes(apiBase, api); err != nil {
+			return apiBase.cleanupAfter(err, "unable to register routes")
+		}
+	}
+	if err := apiBase.StartScheduledTasks(api, opts.TaskFuncs); err != nil {
+		return apiBase.cleanupAfter(err, "")
+	}
+	if err := apiBase.StartRest(api); err != nil {
+		return apiBase.cleanupAfter(err, "")
+	}
+	if opts.Started != nil {
+		if err := opts.Started(apiBase, api); err != nil {
+			return apiBase.cleanupAfter(err, "")
+		}
+	}
+	return apiBase.WaitAndCleanup()
+}
+
+// Connect to database, run cli command (see cmd.DatabaseRun()) and stop all started components again.
+// The command is canceled on interrupt
+func (apiBase *ApiBase[T]) RunDatabaseCommand(fn cmd.DatabaseCommandFunc) error {
+	database, err := apiBase.DatabaseInit()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-apiBase.Interrupt:
+			log.Log(log.LevelNotice, "interrupt received, canceling command")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	err = fn(ctx, database)
+	if cleanupErr := apiBase.Cleanup(); cleanupErr != nil {
+		log.Logf(log.LevelError, "cleanup after cli command failed: %s", cleanupErr.Error())
+	}
+	return err
+}
+
+// stop all started components after startup error
+func (apiBase *ApiBase[T]) cleanupAfter(err error, text string) error {
+	if cleanupErr := apiBase.Cleanup(); cleanupErr != nil {
+		log.Logf(log.LevelError, "cleanup after startup error failed: %s", cleanupErr.Error())
+	}
+	return errx.WrapWithType(ErrRun, err, text)
+}
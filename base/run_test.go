@@ -0,0 +1,82 @@
+package base_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"gopkg.cc/apibase/base"
+	"gopkg.cc/apibase/cmd"
+	"gopkg.cc/apibase/cron"
+	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/web"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.toml")
+	config := cmd.InitConfig{
+		AppName:     "app",
+		Database:    "sqlite",
+		SQLite:      db.SQLiteConfig{FilePath: filepath.Join(dir, "app.db"), LockFile: filepath.Join(dir, "app.db.lock")},
+		ApiBind:     "127.0.0.1:0",
+		AppURI:      "http://127.0.0.1",
+		LocalAuth:   true,
+		ApiRootKind: web.FsLocal,
+		TokenSecret: web.GenerateTokenSecret(),
+	}
+	if err := config.Write(configFile, false); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	cli := cmd.CmdConfig{AppName: "app", Version: "1.0.0"}
+
+	migrate := base.InitApiBase()
+	if err := migrate.Run(base.RunOptions[struct{}]{
+		CLI:          cli,
+		ConfigureCLI: func(root *cobra.Command) { root.SetArgs([]string{"-c", configFile, "migrate", "up"}) },
+	}); err != nil {
+		t.Fatalf("Run(migrate up) = %v", err)
+	}
+
+	r := &recorder{}
+	var settings *web.ApiConfigSettings
+	apiBase := base.InitApiBase()
+	err := apiBase.Run(base.RunOptions[struct{}]{
+		CLI:          cli,
+		ConfigureCLI: func(root *cobra.Command) { root.SetArgs([]string{"-c", configFile}) },
+		RegisterHooks: func(apiBase *base.ApiBase[struct{}]) error {
+			r.add("hooks")
+			return nil
+		},
+		RegisterRoutes: func(apiBase *base.ApiBase[struct{}], api *web.ApiServer) error {
+			r.add("routes")
+			return apiBase.RegisterComponent(r.component("app", base.ComponentSQLite))
+		},
+		Started: func(apiBase *base.ApiBase[struct{}], api *web.ApiServer) error {
+			for _, name := range []string{base.ComponentSQLite, base.ComponentCron, base.ComponentRest} {
+				if !apiBase.HasComponent(name) {
+					t.Errorf("component '%s' isn't registered once started", name)
+				}
+			}
+			settings = api.Settings()
+			r.add("started")
+			apiBase.Interrupt <- os.Interrupt
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	want := []string{"hooks", "routes", "start app", "started", "stop app"}
+	if !slices.Equal(r.events, want) {
+		t.Errorf("events = %v; want %v", r.events, want)
+	}
+	// scheduled tasks are shut down on cleanup
+	if err := cron.Remove(settings, cron.PurgeTaskID); !errors.Is(err, cron.ErrTaskRemove) {
+		t.Errorf("Remove() of purge task after Run() = %v; want ErrTaskRemove", err)
+	}
+}
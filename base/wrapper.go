@@ -1,52 +1,196 @@
 package base
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gopkg.cc/apibase/cron"
 	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/log"
 	"gopkg.cc/apibase/web"
 	"gopkg.cc/apibase/web_setup"
 )
 
-// used to add a stage in the close channel chain, returns offset of channel to listen to,
-// will always be 0, since cleanup is done in reverse order
-func (apiBase *ApiBase[T]) registerCloseStage() uint {
-	if len(apiBase.CloseChain) < 1 {
-		// if CloseChain is an empty array, also create the "next" channel
-		apiBase.CloseChain = append(apiBase.CloseChain, make(chan struct{}))
+// setup pgx database connection as component "postgres", which is closed on cleanup (on error, cleanup isn't required)
+func (apiBase *ApiBase[T]) PostgresInit() (db.DB, error) {
+	database := &componentDB{timeoutClose: apiBase.BaseConfig.TimeoutDatabaseShutdown}
+	err := apiBase.RegisterComponent(Component{
+		Name: ComponentPostgres,
+		Start: func(ctx context.Context) error {
+			return database.open(ctx, func(ctx context.Context) (db.DB, error) {
+				return db.PostgresInit(ctx, apiBase.Postgres, apiBase.BaseConfig)
+			})
+		},
+		Stop: func(ctx context.Context) error {
+			return database.get().Close(ctx)
+		},
+		TimeoutStop: apiBase.BaseConfig.TimeoutDatabaseShutdown,
+	})
+	if err != nil {
+		return db.DB{}, err
+	}
+	if err := apiBase.StartComponents(); err != nil {
+		return db.DB{}, err
 	}
-	apiBase.CloseChain = append([]chan struct{}{make(chan struct{})}, apiBase.CloseChain...)
-	// since the close chain array should cleanup everything in reverse order,
-	// the new close channel will always be prepended.
-	// Therefore this function always returns index 0
-	return 0 // TODO: maybe don't return index at all
+	return database.get(), nil
 }
 
-// returns shutdown and next channels which can be used for own application go routines
-func (apiBase *ApiBase[T]) GetCloseStageChannels() (shutdown chan struct{}, next chan struct{}) {
-	i := apiBase.registerCloseStage()
-	return apiBase.CloseChain[i], apiBase.CloseChain[i+1]
+// open sqlite database as component "sqlite", which is closed on cleanup and releases SQLiteConfig.LockFile (on error, cleanup isn't required)
+func (apiBase *ApiBase[T]) SQLiteInit() (db.DB, error) {
+	database := &componentDB{timeoutClose: apiBase.BaseConfig.TimeoutDatabaseShutdown}
+	err := apiBase.RegisterComponent(Component{
+		Name: ComponentSQLite,
+		Start: func(ctx context.Context) error {
+			return database.open(ctx, func(ctx context.Context) (db.DB, error) {
+				return db.SQLiteInit(ctx, apiBase.SQLite, apiBase.BaseConfig)
+			})
+		},
+		Stop: func(ctx context.Context) error {
+			return database.get().Close(ctx)
+		},
+		TimeoutStop: apiBase.BaseConfig.TimeoutDatabaseShutdown,
+	})
+	if err != nil {
+		return db.DB{}, err
+	}
+	if err := apiBase.StartComponents(); err != nil {
+		return db.DB{}, err
+	}
+	return database.get(), nil
 }
 
-// setup pgx database connection, requires cleanup (on error, cleanup isn't required)
-func (apiBase *ApiBase[T]) PostgresInit() (db.DB, error) {
-	i := apiBase.registerCloseStage()
+// setup database connection, sqlite is used if SQLiteConfig.FilePath is set, otherwise postgres
+func (apiBase *ApiBase[T]) DatabaseInit() (db.DB, error) {
+	if apiBase.SQLite.FilePath != "" {
+		return apiBase.SQLiteInit()
+	}
+	return apiBase.PostgresInit()
+}
 
-	database, err := db.PostgresInit(apiBase.Postgres, apiBase.BaseConfig, apiBase.CloseChain[i], apiBase.CloseChain[i+1])
+// database opened by the start function of a database component, which may still return after its start timeout
+type componentDB struct {
+	mtx          sync.Mutex
+	db           db.DB
+	timeoutClose time.Duration
+}
 
+// set database returned by open, unless ctx is done because the start timed out, in which case it is closed again
+func (c *componentDB) open(ctx context.Context, open func(ctx context.Context) (db.DB, error)) error {
+	database, err := open(ctx)
 	if err != nil {
-		apiBase.CleanupOnError()
-		return database, err
+		return err
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if ctx.Err() != nil {
+		closeCtx, cancel := context.WithTimeout(context.Background(), c.timeoutClose)
+		defer cancel()
+		if err := database.Close(closeCtx); err != nil {
+			log.Logf(log.LevelError, "unable to close database opened after start timeout: %s", err.Error())
+		}
+		return ctx.Err()
+	}
+	c.db = database
+	return nil
+}
+
+func (c *componentDB) get() db.DB {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.db
+}
+
+// database components registered by PostgresInit() or SQLiteInit()
+func (apiBase *ApiBase[T]) databaseComponents() []string {
+	names := []string{}
+	for _, name := range []string{ComponentPostgres, ComponentSQLite} {
+		if apiBase.HasComponent(name) {
+			names = append(names, name)
+		}
 	}
-	return database, nil
+	return names
 }
 
-// start rest api server, is non-blocking, requires cleanup (on error, cleanup isn't required)
+// start rest api server as component "rest", is non-blocking, requires cleanup (on error, cleanup isn't required)
 func (apiBase *ApiBase[T]) StartRest(api *web.ApiServer) error {
-	i := apiBase.registerCloseStage()
+	// rest is stopped before scheduled tasks and database
+	dependsOn := apiBase.databaseComponents()
+	if apiBase.HasComponent(ComponentCron) {
+		dependsOn = append(dependsOn, ComponentCron)
+	}
+	err := apiBase.RegisterComponent(Component{
+		Name: ComponentRest,
+		Start: func(ctx context.Context) error {
+			return web_setup.StartRest(api, apiBase.ApiConfig.ApiBind)
+		},
+		Stop: func(ctx context.Context) error {
+			return web_setup.ShutdownRest(ctx, api)
+		},
+		DependsOn:   dependsOn,
+		TimeoutStop: api.Config.Settings.TimeoutSubprocShutdown,
+	})
+	if err != nil {
+		return err
+	}
+	apiBase.api = api
+	return apiBase.StartComponents()
+}
 
-	err := web_setup.StartRest(api, apiBase.ApiConfig.ApiBind, apiBase.CloseChain[i], apiBase.CloseChain[i+1])
+// start all scheduled tasks saved in database as component "cron", taskFuncs contains the cron.TaskFunc for every task type.
+// Also schedules purging of soft deleted entries, see cron.SchedulePurge().
+// Tasks are shut down on cleanup after the rest api is stopped and before the database connection is closed (on error, cleanup isn't required)
+func (apiBase *ApiBase[T]) StartScheduledTasks(api *web.ApiServer, taskFuncs map[string]cron.TaskFunc) error {
+	dependsOn := apiBase.databaseComponents()
+	err := apiBase.RegisterComponent(Component{
+		Name: ComponentCron,
+		Start: func(ctx context.Context) error {
+			tasks, err := cron.GetScheduledTasksFromDB(ctx, api)
+			if err != nil && !errors.Is(err, db.ErrDatabaseNotFound) {
+				return err
+			}
+			for i := range tasks {
+				tasks[i].Run = taskFuncs[tasks[i].TaskType]
+			}
+			if err := cron.StartScheduledTasks(api.Settings(), tasks); err != nil {
+				return err
+			}
+			if err := cron.SchedulePurge(api); err != nil {
+				_ = cron.Shutdown(api.Settings())
+				return err
+			}
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			return cron.Shutdown(api.Settings())
+		},
+		DependsOn: dependsOn,
+	})
 	if err != nil {
-		apiBase.CleanupOnError()
 		return err
 	}
-	return nil
+	return apiBase.StartComponents()
+}
+
+// refresh referenced secrets periodically as component "secrets", only registered if config contains any secret references
+func (apiBase *ApiBase[T]) registerSecretRotation() error {
+	if helper.Secrets.Len() < 1 || apiBase.HasComponent(ComponentSecrets) {
+		return nil
+	}
+	var cancel context.CancelFunc
+	return apiBase.RegisterComponent(Component{
+		Name: ComponentSecrets,
+		Start: func(ctx context.Context) error {
+			var runCtx context.Context
+			runCtx, cancel = context.WithCancel(context.Background())
+			go helper.Secrets.Run(runCtx, apiBase.Secrets.RefreshInterval)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			cancel()
+			return nil
+		},
+	})
 }
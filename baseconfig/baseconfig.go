@@ -4,34 +4,45 @@ import (
 	"time"
 
 	h "gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/log"
 )
 
 type BaseConfig struct {
 	DatabaseMaxReconnectAttempts  uint   `toml:"db_max_reconnect_attempts"`
+	DatabaseAutoMigrate           bool   `toml:"db_auto_migrate"` // apply pending migrations on startup, otherwise startup fails if any is pending
 	SQLiteDatetimeFormat          string `toml:"sqlite_datetime_format"`
-	TomlTimeoutCloseChainShutdown string `toml:"timeout_closechain_shutdown"`
+	TomlLogLevel                  string `toml:"log_level"` // is reloadable, cli verbosity flag takes precedence if given
+	TomlTimeoutComponentStartup   string `toml:"timeout_component_startup"`
+	TomlTimeoutCloseChainShutdown string `toml:"timeout_closechain_shutdown"` // default stop timeout per component
 	TomlTimeoutDatabaseConnect    string `toml:"timeout_database_connect"`
 	TomlTimeoutDatabaseShutdown   string `toml:"timeout_database_shutdown"`
 	TomlTimeoutDatabaseQuery      string `toml:"timeout_database_query"`
 	TomlTimeoutDatabaseLargeQuery string `toml:"timeout_database_large_query"`
+	TomlDatabaseSlowQuery         string `toml:"db_slow_query_threshold"` // queries taking longer are logged
 
+	LogLevel                  log.Level     `internal:"log_level" parsetype:"loglevel"`
+	TimeoutComponentStartup   time.Duration `internal:"timeout_component_startup"`
 	TimeoutCloseChainShutdown time.Duration `internal:"timeout_closechain_shutdown"`
 	TimeoutDatabaseConnect    time.Duration `internal:"timeout_database_connect"`
 	TimeoutDatabaseShutdown   time.Duration `internal:"timeout_database_shutdown"`
 	TimeoutDatabaseQuery      time.Duration `internal:"timeout_database_query"`
 	TimeoutDatabaseLargeQuery time.Duration `internal:"timeout_database_large_query"`
+	DatabaseSlowQuery         time.Duration `internal:"db_slow_query_threshold"`
 }
 
 func (bc *BaseConfig) AddMissingFromDefaults() error {
 	defaults := &BaseConfig{
 		DatabaseMaxReconnectAttempts: 3,
 		SQLiteDatetimeFormat:         "2006-01-02 15:04:05",
+		LogLevel:                     log.LevelNotice,
 
+		TimeoutComponentStartup:   time.Second * 30,
 		TimeoutCloseChainShutdown: time.Second * 30,
 		TimeoutDatabaseConnect:    time.Second * 3,
 		TimeoutDatabaseShutdown:   time.Second * 3,
 		TimeoutDatabaseQuery:      time.Second * 10,
 		TimeoutDatabaseLargeQuery: time.Minute * 5,
+		DatabaseSlowQuery:         time.Millisecond * 500,
 	}
 	return h.ParseTomlConfigAndDefaults(bc, defaults)
 }
@@ -0,0 +1,470 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.cc/apibase/cli"
+	"gopkg.cc/apibase/db"
+	h "gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/table"
+	"gopkg.cc/apibase/web_auth"
+)
+
+// Run by base.ApiBase[T].Run() once config is loaded and the database is connected, instead of starting the server
+type DatabaseCommandFunc func(ctx context.Context, database db.DB) error
+
+// Use as cobra.Command.Run for own subcommands operating on the configured database,
+// fn is run by base.ApiBase[T].Run() (or base.ApiBase[T].RunDatabaseCommand()) with the cli args of the command,
+// ctx is canceled on interrupt and reads the primary database, not its read replicas
+func DatabaseRun(fn func(ctx context.Context, database db.DB, args []string) error) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		appSettings.DatabaseCommand = func(ctx context.Context, database db.DB) error {
+			return fn(db.WithPrimary(ctx), database, args)
+		}
+	}
+}
+
+func serveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "start the server, same as running without command",
+		Args:  cobra.NoArgs,
+		Run:   func(cmd *cobra.Command, args []string) {},
+	}
+}
+
+func migrateCommand() *cobra.Command {
+	migrate := &cobra.Command{
+		Use:   "migrate",
+		Short: "manage database migrations of the default apibase tables and registered sources",
+	}
+	migrate.AddCommand(&cobra.Command{
+		Use:   "up",
+		Short: "apply all pending migrations",
+		Args:  cobra.NoArgs,
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			if err := db.MigrateUp(ctx, database); err != nil {
+				return err
+			}
+			fmt.Println("database migrated")
+			return nil
+		}),
+	})
+
+	var yes, all bool
+	var steps int
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "revert the last applied migration, the reverted tables are dropped including their data",
+		Args:  cobra.NoArgs,
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			if all {
+				steps = math.MaxInt
+			}
+			prompt := fmt.Sprintf("Revert the last %d migrations, dropped tables lose their data?", steps)
+			if all {
+				prompt = "Revert all migrations, dropped tables lose their data?"
+			}
+			if !yes && !(cli.Confirm{Prompt: prompt, OnlyOnce: true}).AskBoolFalseOnErr() {
+				fmt.Println("aborted")
+				return nil
+			}
+			if err := db.MigrateDown(ctx, database, steps); err != nil {
+				return err
+			}
+			fmt.Println("migrations reverted")
+			return nil
+		}),
+	}
+	down.Flags().BoolVarP(&yes, "yes", "y", false, "don't ask for confirmation")
+	down.Flags().IntVarP(&steps, "steps", "n", 1, "number of migrations to revert")
+	down.Flags().BoolVar(&all, "all", false, "revert all migrations")
+	migrate.AddCommand(down)
+
+	migrate.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "show applied and pending migrations",
+		Args:  cobra.NoArgs,
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			status, err := db.MigrationsStatus(ctx, database)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%-12s %-8s %-30s %s\n", "SOURCE", "VERSION", "NAME", "APPLIED")
+			for _, m := range status {
+				applied := "pending"
+				if m.AppliedAt != nil {
+					applied = m.AppliedAt.Local().Format(time.DateTime)
+				}
+				fmt.Printf("%-12s %-8d %-30s %s\n", m.Source, m.Version, m.Name, applied)
+			}
+			return nil
+		}),
+	})
+	migrate.AddCommand(&cobra.Command{
+		Use:   "verify",
+		Short: "compare table structs to the database tables, exits with error if any difference is found",
+		Args:  cobra.NoArgs,
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			diffs, err := db.VerifySchema(ctx, database)
+			if err != nil {
+				return err
+			}
+			for _, diff := range diffs {
+				fmt.Println(diff.String())
+			}
+			if len(diffs) > 0 {
+				return fmt.Errorf("%d differences between table structs and database found", len(diffs))
+			}
+			fmt.Println("database tables match table structs")
+			return nil
+		}),
+	})
+	return migrate
+}
+
+func userCommand() *cobra.Command {
+	user := &cobra.Command{
+		Use:   "user",
+		Short: "manage users",
+	}
+
+	var name, email, password, org string
+	var superAdmin bool
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "create local user, a new organization is created for the user unless --org is set",
+		Args:  cobra.NoArgs,
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			generated := password == ""
+			if generated {
+				password = h.RandomBase64(18)
+			}
+			hash, err := web_auth.HashPassword(password)
+			if err != nil {
+				return err
+			}
+			newUser := table.User{
+				Name:           name,
+				AuthProvider:   "local",
+				Email:          email,
+				EmailVerified:  true,
+				PasswordHash:   hash,
+				SecretsVersion: 1,
+				SuperAdmin:     superAdmin,
+			}
+			roles := []table.UserRole{}
+			if org != "" {
+				o, err := database.GetOrgByName(ctx, org)
+				if err != nil {
+					return err
+				}
+				roles = append(roles, table.UserRole{OrgID: o.ID, OrgView: true, OrgEdit: true, OrgAdmin: true})
+			}
+			created, err := database.CreateNewUserWithOrg(ctx, newUser, roles...)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("user '%s' created (id: %s)\n", created.Email, created.ID)
+			if generated {
+				fmt.Printf("generated password: %s\n", password)
+			}
+			return nil
+		}),
+	}
+	create.Flags().StringVar(&name, "name", "", "user name (required)")
+	create.Flags().StringVar(&email, "email", "", "email address (required)")
+	create.Flags().StringVar(&password, "password", "", "password, a random password is generated and printed if not set")
+	create.Flags().StringVar(&org, "org", "", "add user as admin to this existing organization instead of creating a new one")
+	create.Flags().BoolVar(&superAdmin, "super-admin", false, "make user super admin")
+	create.MarkFlagRequired("name")
+	create.MarkFlagRequired("email")
+	user.AddCommand(create)
+
+	user.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "list all users",
+		Args:  cobra.NoArgs,
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			users, err := database.GetAllUsers(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%-36s %-20s %-30s %-10s %-11s %s\n", "ID", "NAME", "EMAIL", "PROVIDER", "SUPERADMIN", "DISABLED")
+			for _, u := range users {
+				fmt.Printf("%-36s %-20s %-30s %-10s %-11t %t\n", u.ID, u.Name, u.Email, u.AuthProvider, u.SuperAdmin, u.Disabled)
+			}
+			return nil
+		}),
+	})
+
+	var enable bool
+	disable := &cobra.Command{
+		Use:   "disable <email>",
+		Short: "disable user and revoke all sessions, the user can't login anymore",
+		Args:  cobra.ExactArgs(1),
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			u, err := database.GetUserByEmail(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if err := database.SetUserDisabled(ctx, u.ID, !enable); err != nil {
+				return err
+			}
+			if enable {
+				fmt.Printf("user '%s' enabled\n", u.Email)
+			} else {
+				fmt.Printf("user '%s' disabled, all sessions revoked\n", u.Email)
+			}
+			return nil
+		}),
+	}
+	disable.Flags().BoolVar(&enable, "enable", false, "enable previously disabled user")
+	user.AddCommand(disable)
+
+	var revoke bool
+	setSuperAdmin := &cobra.Command{
+		Use:   "set-superadmin <email>",
+		Short: "grant super admin to user, takes effect once the access token of the user is renewed",
+		Args:  cobra.ExactArgs(1),
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			u, err := database.GetUserByEmail(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if err := database.SetUserSuperAdmin(ctx, u.ID, !revoke); err != nil {
+				return err
+			}
+			fmt.Printf("super admin of user '%s' set to %t\n", u.Email, !revoke)
+			return nil
+		}),
+	}
+	setSuperAdmin.Flags().BoolVar(&revoke, "revoke", false, "revoke super admin instead")
+	user.AddCommand(setSuperAdmin)
+
+	var newPassword string
+	resetPassword := &cobra.Command{
+		Use:   "reset-password <email>",
+		Short: "set new password for local user and revoke all sessions",
+		Args:  cobra.ExactArgs(1),
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			u, err := database.GetUserByEmail(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if u.AuthProvider != "local" {
+				return fmt.Errorf("user '%s' uses auth provider '%s', password can only be set for local users", u.Email, u.AuthProvider)
+			}
+			generated := newPassword == ""
+			if generated {
+				newPassword = h.RandomBase64(18)
+			}
+			hash, err := web_auth.HashPassword(newPassword)
+			if err != nil {
+				return err
+			}
+			if err := database.UpdateUserPassword(ctx, u.ID, hash); err != nil {
+				return err
+			}
+			fmt.Printf("password of user '%s' reset, all sessions revoked\n", u.Email)
+			if generated {
+				fmt.Printf("generated password: %s\n", newPassword)
+			}
+			return nil
+		}),
+	}
+	resetPassword.Flags().StringVar(&newPassword, "password", "", "new password, a random password is generated and printed if not set")
+	user.AddCommand(resetPassword)
+
+	user.AddCommand(&cobra.Command{
+		Use:   "delete <email>",
+		Short: "delete user and its roles and revoke all sessions, the user can be restored until it is purged",
+		Args:  cobra.ExactArgs(1),
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			u, err := database.GetUserByEmail(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if err := database.DeleteUser(ctx, u.ID); err != nil {
+				return err
+			}
+			fmt.Printf("user '%s' deleted (id: %s)\n", u.Email, u.ID)
+			return nil
+		}),
+	})
+
+	user.AddCommand(&cobra.Command{
+		Use:   "restore <email>",
+		Short: "restore the last deleted user with this email and the roles deleted together with it",
+		Args:  cobra.ExactArgs(1),
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			deleted, err := database.GetDeletedUsers(ctx)
+			if err != nil {
+				return err
+			}
+			i := slices.IndexFunc(deleted, func(u table.User) bool { return u.Email == args[0] })
+			if i < 0 {
+				return fmt.Errorf("no deleted user with email '%s' found, it may already be purged", args[0])
+			}
+			if err := database.RestoreUser(ctx, deleted[i].ID); err != nil {
+				return err
+			}
+			fmt.Printf("user '%s' restored (id: %s)\n", deleted[i].Email, deleted[i].ID)
+			return nil
+		}),
+	})
+
+	return user
+}
+
+func orgCommand() *cobra.Command {
+	org := &cobra.Command{
+		Use:   "org",
+		Short: "manage organizations",
+	}
+
+	var description, tenantType, slug, externalID string
+	create := &cobra.Command{
+		Use:   "create <name>",
+		Short: "create organization",
+		Args:  cobra.ExactArgs(1),
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			created, err := database.CreateOrg(ctx, table.Organization{Name: args[0], Description: description, TenantType: tenantType, Slug: slug, ExternalID: externalID})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("organization '%s' created (id: %s, type: %s, slug: %s)\n", created.Name, created.ID, created.TenantType, created.Slug)
+			return nil
+		}),
+	}
+	create.Flags().StringVar(&description, "description", "", "organization description")
+	create.Flags().StringVar(&tenantType, "type", table.TenantTypeOrg, "tenant type")
+	create.Flags().StringVar(&slug, "slug", "", "unique slug, generated from name if not set")
+	create.Flags().StringVar(&externalID, "external-id", "", "id of the tenant in another system, unique per tenant type")
+	org.AddCommand(create)
+
+	var listType string
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "list all organizations, sorted by name",
+		Args:  cobra.NoArgs,
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			q := db.ListQuery{Filters: map[string]string{}, Limit: db.ListMaxLimit}
+			if listType != "" {
+				q.Filters["tenant_type"] = listType
+			}
+			fmt.Printf("%-36s %-20s %-20s %-10s %s\n", "ID", "NAME", "SLUG", "TYPE", "EXTERNAL ID")
+			for {
+				page, err := database.ListOrgs(ctx, q)
+				if err != nil {
+					return err
+				}
+				for _, o := range page.Items {
+					fmt.Printf("%-36s %-20s %-20s %-10s %s\n", o.ID, o.Name, o.Slug, o.TenantType, o.ExternalID)
+				}
+				if page.NextCursor == "" {
+					return nil
+				}
+				q.Cursor = page.NextCursor
+			}
+		}),
+	}
+	list.Flags().StringVar(&listType, "type", "", "only list organizations of this tenant type")
+	org.AddCommand(list)
+
+	var edit, admin bool
+	addMember := &cobra.Command{
+		Use:   "add-member <org name> <email>",
+		Short: "add user to organization with view permission",
+		Args:  cobra.ExactArgs(2),
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			o, err := database.GetOrgByName(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			u, err := database.GetUserByEmail(ctx, args[1])
+			if err != nil {
+				return err
+			}
+			role := table.UserRole{UserID: u.ID, OrgID: o.ID, OrgView: true, OrgEdit: edit || admin, OrgAdmin: admin}
+			if err := database.CreateUserRole(ctx, role); err != nil {
+				return err
+			}
+			fmt.Printf("user '%s' added to organization '%s' (view: %t, edit: %t, admin: %t)\n", u.Email, o.Name, role.OrgView, role.OrgEdit, role.OrgAdmin)
+			return nil
+		}),
+	}
+	addMember.Flags().BoolVar(&edit, "edit", false, "grant edit permission")
+	addMember.Flags().BoolVar(&admin, "admin", false, "grant admin permission, implies --edit")
+	org.AddCommand(addMember)
+
+	org.AddCommand(&cobra.Command{
+		Use:   "delete <name>",
+		Short: "delete organization with its roles and scheduled tasks, it can be restored until it is purged",
+		Args:  cobra.ExactArgs(1),
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			o, err := database.GetOrgByName(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if err := database.DeleteOrg(ctx, o.ID); err != nil {
+				return err
+			}
+			fmt.Printf("organization '%s' deleted (id: %s), running instances keep its scheduled tasks until restarted\n", o.Name, o.ID)
+			return nil
+		}),
+	})
+
+	org.AddCommand(&cobra.Command{
+		Use:   "restore <name>",
+		Short: "restore the last deleted organization with this name and the roles and scheduled tasks deleted together with it",
+		Args:  cobra.ExactArgs(1),
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			deleted, err := database.GetDeletedOrgs(ctx)
+			if err != nil {
+				return err
+			}
+			i := slices.IndexFunc(deleted, func(o table.Organization) bool { return o.Name == args[0] })
+			if i < 0 {
+				return fmt.Errorf("no deleted organization with name '%s' found, it may already be purged", args[0])
+			}
+			if err := database.RestoreOrg(ctx, deleted[i].ID); err != nil {
+				return err
+			}
+			fmt.Printf("organization '%s' restored (id: %s), restored scheduled tasks start once instances are restarted\n", deleted[i].Name, deleted[i].ID)
+			return nil
+		}),
+	})
+
+	return org
+}
+
+func sessionCommand() *cobra.Command {
+	session := &cobra.Command{
+		Use:   "session",
+		Short: "manage user sessions",
+	}
+	session.AddCommand(&cobra.Command{
+		Use:   "revoke <email>",
+		Short: "revoke all sessions of user, takes effect once the access tokens expire",
+		Args:  cobra.ExactArgs(1),
+		Run: DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+			u, err := database.GetUserByEmail(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			revoked, err := database.DeleteRefreshTokens(ctx, u.ID)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d sessions of user '%s' revoked\n", revoked, u.Email)
+			return nil
+		}),
+	})
+	return session
+}
@@ -0,0 +1,115 @@
+package cmd_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"gopkg.cc/apibase/base"
+	"gopkg.cc/apibase/cmd"
+	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/web"
+)
+
+// run app with cli args, check is run by the own subcommand 'check'
+func runApp(args []string, check func(ctx context.Context, database db.DB) error) error {
+	apiBase := base.InitApiBase()
+	return apiBase.Run(base.RunOptions[struct{}]{
+		CLI: cmd.CmdConfig{AppName: "app", Version: "1.0.0"},
+		ConfigureCLI: func(root *cobra.Command) {
+			root.AddCommand(&cobra.Command{
+				Use: "check",
+				Run: cmd.DatabaseRun(func(ctx context.Context, database db.DB, args []string) error {
+					return check(ctx, database)
+				}),
+			})
+			root.SetArgs(args)
+		},
+	})
+}
+
+func TestAdminCommands(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.toml")
+	config := cmd.InitConfig{
+		AppName:     "app",
+		Database:    "sqlite",
+		SQLite:      db.SQLiteConfig{FilePath: filepath.Join(dir, "app.db"), LockFile: filepath.Join(dir, "app.db.lock")},
+		ApiBind:     "127.0.0.1:0",
+		AppURI:      "http://127.0.0.1",
+		LocalAuth:   true,
+		ApiRootKind: web.FsLocal,
+		TokenSecret: web.GenerateTokenSecret(),
+	}
+	if err := config.Write(configFile, false); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"migrate", "up"},
+		{"user", "create", "--name", "alice", "--email", "alice@example.com", "--password", "secret123"},
+		{"org", "create", "Acme"},
+		{"org", "add-member", "Acme", "alice@example.com", "--admin"},
+		{"user", "set-superadmin", "alice@example.com"},
+		{"user", "disable", "alice@example.com"},
+	} {
+		if err := runApp(append([]string{"-c", configFile}, args...), nil); err != nil {
+			t.Fatalf("%v: error: %v", args, err)
+		}
+	}
+	if err := runApp([]string{"-c", configFile, "user", "disable", "bob@example.com"}, nil); !errors.Is(err, db.ErrDatabaseNotFound) {
+		t.Errorf("user disable of missing user error = %v, want ErrDatabaseNotFound", err)
+	}
+
+	checked := false
+	err := runApp([]string{"-c", configFile, "check"}, func(ctx context.Context, database db.DB) error {
+		checked = true
+		user, err := database.GetUserByEmail(ctx, "alice@example.com")
+		if err != nil {
+			return err
+		}
+		if !user.SuperAdmin || !user.Disabled || user.AuthProvider != "local" {
+			t.Errorf("user = %+v, want disabled local super admin", user)
+		}
+		acme, err := database.GetOrgByName(ctx, "Acme")
+		if err != nil {
+			return err
+		}
+		roles, err := database.GetUserRoles(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		if len(roles) != 2 {
+			t.Errorf("roles = %+v, want roles of own organization and 'Acme'", roles)
+		}
+		for _, role := range roles {
+			if role.OrgID == acme.ID && !(role.OrgView && role.OrgEdit && role.OrgAdmin) {
+				t.Errorf("role of 'Acme' = %+v, want admin", role)
+			}
+		}
+		return nil
+	})
+	if err != nil || !checked {
+		t.Errorf("check error: %v, run: %t", err, checked)
+	}
+}
+
+func TestExecuteSelectsCommand(t *testing.T) {
+	for _, tt := range []struct {
+		args     []string
+		database bool
+	}{
+		{[]string{"migrate", "up"}, true},
+		{[]string{}, false},
+		{[]string{"serve"}, false},
+	} {
+		root := cmd.ConfigureCLI(cmd.CmdConfig{AppName: "app", Version: "1.0.0"})
+		root.SetArgs(tt.args)
+		settings, exit := cmd.Execute(root)
+		if exit || (settings.DatabaseCommand != nil) != tt.database {
+			t.Errorf("Execute(%v) = database command %t, exit %t; want database command %t", tt.args, settings.DatabaseCommand != nil, exit, tt.database)
+		}
+	}
+}
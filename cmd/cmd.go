@@ -27,7 +27,14 @@ type Settings struct {
 	ConfigFile string
 	ApiRoot    string
 	Verbosity  int
-	Help       bool
+	// -v/--verbose was given, the log level of Verbosity is used instead of log_level of the config
+	VerbositySet bool
+	Help         bool
+
+	// set if a command operating on the database was selected, e.g. "user create", nil if the server should be started
+	DatabaseCommand DatabaseCommandFunc
+	// set if a command operating on the loaded config was selected, e.g. "config check", nil otherwise
+	ConfigCommand ConfigCommandFunc
 }
 
 func (s Settings) GetLogLevel() log.Level {
@@ -68,6 +75,8 @@ func ConfigureCLI(conf CmdConfig) *cobra.Command {
 
 // Parse cli arguments, returns true if program should exit
 func Execute(root *cobra.Command) (Settings, bool) {
+	// settings of a previous call, e.g. the selected command, must not be kept
+	appSettings, stopExec = Settings{}, false
 	defaultHelpFunc := root.HelpFunc()
 
 	root.PersistentFlags().StringVarP(&appSettings.ConfigFile, "config", "c", appConfig.DefaultConfigPath, "config file")
@@ -88,7 +97,10 @@ func Execute(root *cobra.Command) (Settings, bool) {
 			stopExec = true
 		},
 	})
+	root.AddCommand(keyringCommand(), configCommand())
+	root.AddCommand(serveCommand(), migrateCommand(), userCommand(), orgCommand(), sessionCommand())
 	err := root.Execute()
+	appSettings.VerbositySet = root.PersistentFlags().Changed("verbose")
 	if err != nil {
 		return appSettings, true
 	}
@@ -0,0 +1,461 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+	"gopkg.cc/apibase/baseconfig"
+	"gopkg.cc/apibase/cli"
+	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/email"
+	h "gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/web"
+)
+
+// timeout for connection tests of config init
+const CONFIG_INIT_TEST_TIMEOUT = time.Second * 10
+
+// Answers of the config init wizard, used to write a commented config file
+type InitConfig struct {
+	AppName       string
+	Database      string // "postgres" or "sqlite"
+	Postgres      db.PostgresConfig
+	SQLite        db.SQLiteConfig
+	ApiBind       string
+	AppURI        string
+	LocalAuth     bool
+	OAuthEnabled  bool
+	ApiRootKind   web.FileSystemKind
+	ApiRootTarget string
+	TokenSecret   string             // see web.GenerateTokenSecret()
+	Email         *email.EmailConfig // nil if no email sender is configured
+}
+
+var initConfigTemplate = template.Must(template.New("config").Funcs(template.FuncMap{"quote": strconv.Quote, "origin": configOrigin}).Parse(`# {{ .AppName }} config, created by '{{ .AppName }} config init'
+# Every value may be overwritten by an env var, e.g. APIBASE_APICONFIG_API_BIND,
+# secrets may also be read from files, e.g. token_secret_file = "/run/secrets/token_secret"
+
+[apiconfig]
+# address the rest api listens on
+api_bind = {{ quote .ApiBind }}
+# public url of the application, used for redirects
+app_uri = {{ quote .AppURI }}
+# allowed CORS origins, "*" allows any origin and should not be used in production
+cors = [{{ quote (origin .AppURI) }}]
+# random base64 string with at least 64 bytes used to sign jwt, keep it secret
+token_secret = {{ quote .TokenSecret }}
+# enabled authentication methods, oauth providers need to be configured by the application
+local_auth = {{ .LocalAuth }}
+oauth_enabled = {{ .OAuthEnabled }}
+allow_registration = false
+
+[apiconfig.api_root]
+# local: only api, static: serve files of target folder, proxy: forward to target url, embedfs: serve embedded target folder
+kind = {{ quote (print .ApiRootKind) }}
+target = {{ quote .ApiRootTarget }}
+
+[baseconfig]
+# debug, info, notice, warning, error or critical
+log_level = "notice"
+# apply pending database migrations on startup, otherwise run '{{ .AppName }} migrate up' after upgrading
+db_auto_migrate = false
+{{ if eq .Database "sqlite" }}
+[sqlite]
+file_path = {{ quote .SQLite.FilePath }}
+# created on startup and removed on shutdown, so only one instance uses the database
+lock_file = {{ quote .SQLite.LockFile }}
+{{ else }}
+[postgres]
+host = {{ quote .Postgres.Host }}
+port = {{ quote .Postgres.Port }}
+user = {{ quote .Postgres.User }}
+password = {{ quote .Postgres.Password.GetSecret }}
+db = {{ quote .Postgres.DB }}
+# disable, allow, prefer, require, verify-ca or verify-full
+ssl_mode = "prefer"
+# ssl_root_cert = "/etc/ssl/certs/db-ca.pem"
+# ssl_cert = "/etc/ssl/certs/db-client.pem"
+# ssl_key = "/etc/ssl/private/db-client.key"
+application_name = {{ quote .AppName }}
+# statement_timeout = "30s"
+# params = ["connect_timeout=5"]
+# read replicas (host or host:port) serving read-only queries, e.g. of the auth middleware
+# replicas = ["db-replica-1:5432", "db-replica-2:5432"]
+
+[postgres.pool]
+# size of the connection pool shared by all requests
+max_conns = 10
+min_conns = 0
+max_conn_lifetime = "1h"
+max_conn_idle_time = "30m"
+health_check_period = "1m"
+{{ end }}{{ with .Email }}
+[email.default]
+# smtp server used to send emails
+host = {{ quote .Host }}
+port = {{ .Port }}
+username = {{ quote .Username }}
+password = {{ quote .Password.GetSecret }}
+# default sender address
+from = {{ quote .From }}
+{{ end }}`))
+
+// origin of app uri, used as default cors origin
+func configOrigin(appURI string) string {
+	uri, err := url.Parse(appURI)
+	if err != nil || uri.Host == "" {
+		return "*"
+	}
+	return uri.Scheme + "://" + uri.Host
+}
+
+// Render config file content
+func (c InitConfig) TOML() (string, error) {
+	out := &strings.Builder{}
+	if err := initConfigTemplate.Execute(out, c); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// Write config file, only readable by the current user since it contains secrets
+func (c InitConfig) Write(path string, overwrite bool) error {
+	content, err := c.TOML()
+	if err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func configCommand() *cobra.Command {
+	config := &cobra.Command{
+		Use:   "config",
+		Short: "create, check and print config file",
+	}
+
+	var output string
+	var force bool
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "interactively create a new config file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stopExec = true
+			if output == "" {
+				output = appSettings.ConfigFile
+			}
+			if _, err := os.Stat(output); err == nil && !force {
+				if !(cli.Confirm{Prompt: fmt.Sprintf("Config file '%s' exists, overwrite it?", output)}).AskBoolFalseOnErr() {
+					return nil
+				}
+			}
+			c, err := configWizard()
+			if errors.Is(err, cli.ERR_SIGINT_RECEIVED) {
+				fmt.Println("aborted")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := c.Write(output, true); err != nil {
+				return err
+			}
+			fmt.Printf("config file '%s' written\n", output)
+			testConfigConnections(c)
+			return nil
+		},
+	}
+	initCmd.Flags().StringVarP(&output, "output", "o", "", "config file to write, defaults to --config")
+	initCmd.Flags().BoolVar(&force, "force", false, "overwrite existing config file without asking")
+	config.AddCommand(initCmd)
+
+	config.AddCommand(&cobra.Command{
+		Use:   "check",
+		Short: "load config file including env vars and secrets and report every problem, exits with error if any is found",
+		Args:  cobra.NoArgs,
+		Run: ConfigRun(func(effective map[string]any, sources h.ConfigSources, problems []error) error {
+			if len(problems) < 1 {
+				fmt.Printf("config file '%s' is valid\n", appSettings.ConfigFile)
+				return nil
+			}
+			fmt.Printf("config file '%s' has %d problems:\n", appSettings.ConfigFile, len(problems))
+			for _, problem := range problems {
+				fmt.Printf("  - %s\n", problem.Error())
+			}
+			return fmt.Errorf("config file '%s' is invalid", appSettings.ConfigFile)
+		}),
+	})
+
+	var effectiveFlag bool
+	var format string
+	printCmd := &cobra.Command{
+		Use:   "print",
+		Short: "print loaded config including env vars with secrets redacted",
+		Args:  cobra.NoArgs,
+		Run: ConfigRun(func(effective map[string]any, sources h.ConfigSources, problems []error) error {
+			for _, problem := range problems {
+				fmt.Fprintf(os.Stderr, "problem: %s\n", problem.Error())
+			}
+			if effective == nil {
+				return fmt.Errorf("unable to load config file '%s'", appSettings.ConfigFile)
+			}
+			if !effectiveFlag {
+				effective = filterConfigSources(effective, sources, "")
+			}
+			return printConfig(effective, format)
+		}),
+	}
+	printCmd.Flags().BoolVar(&effectiveFlag, "effective", false, "print fully resolved config including defaults, otherwise only values set by config file, env vars or secret files are printed")
+	printCmd.Flags().StringVarP(&format, "format", "f", "toml", "output format, toml or json")
+	config.AddCommand(printCmd)
+
+	return config
+}
+
+// Run by base.ApiBase[T].Run() instead of starting the server, once the config is loaded and checked (see base.ApiBase[T].CheckToml()).
+// effective contains the loaded config by toml key with defaults added and secrets redacted (see helper.EffectiveToml()),
+// it is nil if the config file itself can't be parsed
+type ConfigCommandFunc func(effective map[string]any, sources h.ConfigSources, problems []error) error
+
+// Use as cobra.Command.Run for own subcommands operating on the loaded config, fn is run by base.ApiBase[T].Run() (or base.ApiBase[T].RunConfigCommand())
+func ConfigRun(fn ConfigCommandFunc) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		appSettings.ConfigCommand = fn
+	}
+}
+
+func printConfig(config map[string]any, format string) error {
+	switch format {
+	case "toml":
+		return toml.NewEncoder(os.Stdout).Encode(config)
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(config)
+	}
+	return fmt.Errorf("unknown format '%s', must be toml or json", format)
+}
+
+// keep only values with a source, tables are filtered recursively
+func filterConfigSources(config map[string]any, sources h.ConfigSources, parent string) map[string]any {
+	filtered := map[string]any{}
+	for k, v := range config {
+		key := k
+		if parent != "" {
+			key = parent + "." + k
+		}
+		if table, ok := v.(map[string]any); ok {
+			if table = filterConfigSources(table, sources, key); len(table) > 0 {
+				filtered[k] = table
+			}
+			continue
+		}
+		if _, ok := sources[key]; ok {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+func configWizard() (InitConfig, error) {
+	c := InitConfig{AppName: appConfig.AppName, TokenSecret: web.GenerateTokenSecret()}
+	var err error
+
+	c.Database, err = cli.Select{Prompt: "Database:", Options: []string{"postgres", "sqlite"}, Defaults: []bool{true, false}}.GetOne()
+	if err != nil {
+		return c, err
+	}
+	if c.Database == "sqlite" {
+		if c.SQLite.FilePath, err = (cli.Input{Prompt: "SQLite database file", Default: appConfig.AppName + ".db", Validate: notEmpty}).Get(); err != nil {
+			return c, err
+		}
+		if c.SQLite.LockFile, err = (cli.Input{Prompt: "SQLite lock file (empty for none)", Default: c.SQLite.FilePath + ".lock"}).Get(); err != nil {
+			return c, err
+		}
+	} else {
+		if c.Postgres.Host, err = (cli.Input{Prompt: "Postgres host", Default: "localhost", Validate: notEmpty}).Get(); err != nil {
+			return c, err
+		}
+		if c.Postgres.Port, err = (cli.Input{Prompt: "Postgres port", Default: "5432", Validate: validatePort}).Get(); err != nil {
+			return c, err
+		}
+		if c.Postgres.User, err = (cli.Input{Prompt: "Postgres user", Default: appConfig.AppName, Validate: notEmpty}).Get(); err != nil {
+			return c, err
+		}
+		password, err := (cli.Input{Prompt: "Postgres password"}).Get()
+		if err != nil {
+			return c, err
+		}
+		c.Postgres.Password = h.CreateSecretString(password)
+		if c.Postgres.DB, err = (cli.Input{Prompt: "Postgres database", Default: appConfig.AppName, Validate: notEmpty}).Get(); err != nil {
+			return c, err
+		}
+	}
+
+	if c.ApiBind, err = (cli.Input{Prompt: "Rest api bind address", Default: "127.0.0.1:8080", Validate: validateBind}).Get(); err != nil {
+		return c, err
+	}
+	if c.AppURI, err = (cli.Input{Prompt: "Public application url", Default: "http://" + c.ApiBind, Validate: validateAppURI}).Get(); err != nil {
+		return c, err
+	}
+
+	auth, err := cli.Select{
+		Prompt:   "Authentication methods (space to select):",
+		Options:  []string{"local (email and password)", "oauth"},
+		Defaults: []bool{true, false},
+		Multiple: true,
+		Validate: func(sel []bool) error {
+			if !sel[0] && !sel[1] {
+				return errors.New("at least one authentication method is required")
+			}
+			return nil
+		},
+	}.Get()
+	if err != nil {
+		return c, err
+	}
+	c.LocalAuth, c.OAuthEnabled = auth[0], auth[1]
+
+	kind, err := cli.Select{
+		Prompt:   "Api root, serves all requests outside /api and /auth:",
+		Options:  []string{string(web.FsLocal), string(web.FsStatic), string(web.FsProxy), string(web.FsEmbed)},
+		Defaults: []bool{true, false, false, false},
+	}.GetOne()
+	if err != nil {
+		return c, err
+	}
+	c.ApiRootKind = web.FileSystemKind(kind)
+	switch c.ApiRootKind {
+	case web.FsStatic:
+		c.ApiRootTarget, err = (cli.Input{Prompt: "Static files folder", Default: "./public", Validate: notEmpty}).Get()
+	case web.FsProxy:
+		c.ApiRootTarget, err = (cli.Input{Prompt: "Proxy target url", Default: "http://127.0.0.1:3000", Validate: validateAppURI}).Get()
+	case web.FsEmbed:
+		c.ApiRootTarget, err = (cli.Input{Prompt: "Embedded folder", Default: "public", Validate: notEmpty}).Get()
+	}
+	if err != nil {
+		return c, err
+	}
+
+	withEmail, err := (cli.Confirm{Prompt: "Configure smtp email sender?"}).AskBool()
+	if err != nil || !withEmail {
+		return c, err
+	}
+	ec := &email.EmailConfig{Sender: email.SMTP}
+	if ec.Host, err = (cli.Input{Prompt: "SMTP host", Validate: notEmpty}).Get(); err != nil {
+		return c, err
+	}
+	port, err := (cli.Input{Prompt: "SMTP port", Default: "587", Validate: validatePort}).Get()
+	if err != nil {
+		return c, err
+	}
+	ec.Port, _ = strconv.Atoi(port)
+	if ec.Username, err = (cli.Input{Prompt: "SMTP username"}).Get(); err != nil {
+		return c, err
+	}
+	password, err := (cli.Input{Prompt: "SMTP password"}).Get()
+	if err != nil {
+		return c, err
+	}
+	ec.Password = h.CreateSecretString(password)
+	if ec.From, err = (cli.Input{Prompt: "Sender address", Default: ec.Username, Validate: notEmpty}).Get(); err != nil {
+		return c, err
+	}
+	c.Email = ec
+	return c, nil
+}
+
+// offer to test database and smtp connection, failures are only reported
+func testConfigConnections(c InitConfig) {
+	if (cli.Confirm{Prompt: "Test database connection?", Default: true}).AskBoolDefaultOnErr() {
+		reportConfigTest("database", func() error {
+			bc := &baseconfig.BaseConfig{}
+			if err := bc.AddMissingFromDefaults(); err != nil {
+				return err
+			}
+			bc.DatabaseMaxReconnectAttempts = 1
+			ctx, cancel := context.WithTimeout(context.Background(), CONFIG_INIT_TEST_TIMEOUT)
+			defer cancel()
+			var database db.DB
+			var err error
+			if c.Database == "sqlite" {
+				database, err = db.SQLiteInit(ctx, c.SQLite, bc)
+			} else {
+				database, err = db.PostgresInit(ctx, c.Postgres, bc)
+			}
+			if err != nil {
+				return err
+			}
+			return database.Close(ctx)
+		})
+	}
+	if c.Email != nil && (cli.Confirm{Prompt: "Test smtp connection?", Default: true}).AskBoolDefaultOnErr() {
+		reportConfigTest("smtp", func() error {
+			return c.Email.TestConnection(CONFIG_INIT_TEST_TIMEOUT)
+		})
+	}
+}
+
+func reportConfigTest(name string, test func() error) {
+	progress := cli.Progress{}.Start(fmt.Sprintf("testing %s connection", name))
+	err := test()
+	result := fmt.Sprintf("%s connection successful", name)
+	if err != nil {
+		result = fmt.Sprintf("%s connection failed: %s", name, err.Error())
+	}
+	progress <- cli.TaskOperation{Log: result, Final: true}
+}
+
+func notEmpty(input string) error {
+	if strings.TrimSpace(input) == "" {
+		return errors.New("value required")
+	}
+	return nil
+}
+
+func validatePort(input string) error {
+	port, err := strconv.Atoi(input)
+	if err != nil || port < 1 || port > 65535 {
+		return errors.New("port must be a number between 1 and 65535")
+	}
+	return nil
+}
+
+func validateBind(input string) error {
+	_, port, err := net.SplitHostPort(input)
+	if err != nil {
+		return errors.New("bind address must be <host>:<port>, e.g. 127.0.0.1:8080")
+	}
+	return validatePort(port)
+}
+
+func validateAppURI(input string) error {
+	uri, err := url.ParseRequestURI(input)
+	if err != nil || (uri.Scheme != "http" && uri.Scheme != "https") || uri.Host == "" {
+		return errors.New("must be http or https url, e.g. https://app.example.com")
+	}
+	return nil
+}
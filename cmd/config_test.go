@@ -0,0 +1,73 @@
+package cmd_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.cc/apibase/base"
+	"gopkg.cc/apibase/cmd"
+	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/email"
+	h "gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/web"
+)
+
+func TestInitConfigWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		config cmd.InitConfig
+	}{
+		{"sqlite", cmd.InitConfig{
+			Database:    "sqlite",
+			SQLite:      db.SQLiteConfig{FilePath: "app.db", LockFile: "app.db.lock"},
+			ApiBind:     "127.0.0.1:8080",
+			AppURI:      "https://app.example.com/login",
+			LocalAuth:   true,
+			ApiRootKind: web.FsLocal,
+		}},
+		{"postgres with email", cmd.InitConfig{
+			Database:      "postgres",
+			Postgres:      db.PostgresConfig{Host: "localhost", Port: "5432", User: "app", Password: h.CreateSecretString(`p"a\ss`), DB: "app"},
+			ApiBind:       ":8080",
+			AppURI:        "http://localhost:8080",
+			OAuthEnabled:  true,
+			ApiRootKind:   web.FsProxy,
+			ApiRootTarget: "http://127.0.0.1:3000",
+			Email:         &email.EmailConfig{Host: "smtp.example.com", Port: 587, Username: "app", Password: h.CreateSecretString("secret"), From: "app@example.com"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.AppName = "app"
+			tt.config.TokenSecret = web.GenerateTokenSecret()
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := tt.config.Write(path, false); err != nil {
+				t.Fatalf("Write() error: %v", err)
+			}
+			if err := tt.config.Write(path, false); err == nil {
+				t.Error("Write() overwrote existing file")
+			}
+
+			loaded := base.ApiBase[struct{}]{}
+			if _, err := toml.DecodeFile(path, &loaded); err != nil {
+				t.Fatalf("written config is invalid toml: %v", err)
+			}
+			if _, err := web.DecodeTokenSecret(loaded.ApiConfig.TokenSecret.GetSecret()); err != nil {
+				t.Errorf("token_secret rejected: %v", err)
+			}
+			if loaded.ApiConfig.ApiBind != tt.config.ApiBind || loaded.ApiConfig.AppURI != tt.config.AppURI || loaded.ApiConfig.ApiRoot.Kind != tt.config.ApiRootKind {
+				t.Errorf("apiconfig = %+v", loaded.ApiConfig)
+			}
+			if loaded.ApiConfig.LocalAuth != tt.config.LocalAuth || loaded.ApiConfig.OAuthEnabled != tt.config.OAuthEnabled {
+				t.Errorf("auth methods = %t, %t", loaded.ApiConfig.LocalAuth, loaded.ApiConfig.OAuthEnabled)
+			}
+			if loaded.SQLite.FilePath != tt.config.SQLite.FilePath || loaded.Postgres.Password.GetSecret() != tt.config.Postgres.Password.GetSecret() {
+				t.Errorf("database config = %+v, %+v", loaded.SQLite, loaded.Postgres)
+			}
+			if (tt.config.Email != nil) != (loaded.Email["default"].Host != "") {
+				t.Errorf("email config = %+v", loaded.Email)
+			}
+		})
+	}
+}
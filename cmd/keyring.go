@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+	"gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/web"
+)
+
+// default grace period for retired keys, same as default refresh token validity, so no session is invalidated
+const DEFAULT_KEY_RETIRE_GRACE = time.Hour * 24 * 30
+
+// cli command to manage the jwt signing keyring (apiconfig.keyring_file)
+func keyringCommand() *cobra.Command {
+	var keyringFile string
+	keyring := &cobra.Command{
+		Use:   "keyring",
+		Short: "manage jwt signing keys, running servers load changes on SIGHUP",
+	}
+	keyring.PersistentFlags().StringVarP(&keyringFile, "file", "f", "", "keyring file, defaults to keyring_file in [apiconfig] of config file")
+
+	keyring.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "list signing keys",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stopExec = true
+			path, err := keyringPath(keyringFile)
+			if err != nil {
+				return err
+			}
+			k, err := web.LoadKeyring(path)
+			if err != nil {
+				return err
+			}
+			printKeyring(k)
+			return nil
+		},
+	})
+
+	var activateIn, grace time.Duration
+	var retirePrevious bool
+	add := &cobra.Command{
+		Use:   "add",
+		Short: "add new signing key, which is used for signing once active",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stopExec = true
+			path, err := keyringPath(keyringFile)
+			if err != nil {
+				return err
+			}
+			k := &web.Keyring{}
+			if _, err := os.Stat(path); err == nil {
+				if k, err = web.LoadKeyring(path); err != nil {
+					return err
+				}
+			}
+			now := time.Now()
+			if retirePrevious {
+				if err := k.RetireActive(now, now.Add(activateIn+grace)); err != nil {
+					return err
+				}
+			}
+			key := k.Add(now, now.Add(activateIn))
+			if err := k.Save(path); err != nil {
+				return err
+			}
+			fmt.Printf("added key '%s', send SIGHUP to all running instances to load it\n", key.ID)
+			printKeyring(k)
+			return nil
+		},
+	}
+	add.Flags().DurationVar(&activateIn, "activate-in", 0, "delay until the key is used for signing, allows all instances to load the key first")
+	add.Flags().BoolVar(&retirePrevious, "retire-previous", false, "retire all active keys (including token_secret) after activation plus grace period, pending keys and keys retiring earlier are kept")
+	add.Flags().DurationVar(&grace, "grace", DEFAULT_KEY_RETIRE_GRACE, "grace period after which retired keys are no longer valid for verification")
+	keyring.AddCommand(add)
+
+	var retireGrace time.Duration
+	retire := &cobra.Command{
+		Use:   "retire <key id>",
+		Short: "retire key after grace period, use key id 'default' to retire token_secret",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stopExec = true
+			path, err := keyringPath(keyringFile)
+			if err != nil {
+				return err
+			}
+			k := &web.Keyring{}
+			if _, err := os.Stat(path); err == nil {
+				if k, err = web.LoadKeyring(path); err != nil {
+					return err
+				}
+			}
+			if err := k.Retire(args[0], time.Now().Add(retireGrace)); err != nil {
+				return err
+			}
+			if _, ok := k.SigningKey(time.Now().Add(retireGrace)); !ok {
+				return fmt.Errorf("retiring key '%s' would leave no signing key, add a new key first", args[0])
+			}
+			if err := k.Save(path); err != nil {
+				return err
+			}
+			fmt.Printf("key '%s' retires at %s, send SIGHUP to all running instances to apply\n", args[0], time.Now().Add(retireGrace).Format(time.RFC3339))
+			printKeyring(k)
+			return nil
+		},
+	}
+	retire.Flags().DurationVar(&retireGrace, "grace", DEFAULT_KEY_RETIRE_GRACE, "grace period after which the key is no longer valid for verification")
+	keyring.AddCommand(retire)
+
+	return keyring
+}
+
+// keyring file from flag or config file, env var APIBASE_APICONFIG_KEYRING_FILE overwrites config file
+func keyringPath(flag string) (string, error) {
+	if flag != "" {
+		return flag, nil
+	}
+	config := struct {
+		ApiConfig struct {
+			KeyringFile string `toml:"keyring_file"`
+		} `toml:"apiconfig"`
+	}{}
+	if _, err := os.Stat(appSettings.ConfigFile); err == nil {
+		if _, err := toml.DecodeFile(appSettings.ConfigFile, &config); err != nil {
+			return "", err
+		}
+	}
+	if err := helper.OverlayEnv(&config, "APIBASE", helper.ConfigSources{}); err != nil {
+		return "", err
+	}
+	if config.ApiConfig.KeyringFile == "" {
+		return "", fmt.Errorf("keyring_file isn't set in [apiconfig] of config file '%s', use --file", appSettings.ConfigFile)
+	}
+	return config.ApiConfig.KeyringFile, nil
+}
+
+func printKeyring(k *web.Keyring) {
+	now := time.Now()
+	fmt.Printf("%-20s %-12s %-25s %-25s %s\n", "ID", "STATUS", "CREATED", "ACTIVE FROM", "RETIRES")
+	for _, key := range k.Keys {
+		fmt.Printf("%-20s %-12s %-25s %-25s %s\n", key.ID, k.Status(key.ID, now), formatKeyTime(key.CreatedAt), formatKeyTime(key.NotBefore), formatKeyTime(key.RetireAt))
+	}
+}
+
+func formatKeyTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gofrs/uuid/v5"
 	"gopkg.cc/apibase/errx"
 	"gopkg.cc/apibase/log"
 	"gopkg.cc/apibase/table"
@@ -23,7 +24,7 @@ type TaskFunc func(currentTime time.Time, interval time.Duration, data string) e
 
 type Task struct {
 	ID       string        `json:"id"`
-	OrgID    int           `json:"org_id"`
+	OrgID    uuid.UUID     `json:"org_id"`
 	Start    time.Time     `json:"start"`
 	Interval time.Duration `json:"interval"`
 	TaskType string        `json:"task_type"`
@@ -60,9 +61,9 @@ func init() {
 	activeTasks.tasks = make(map[string]task)
 }
 
-func GetScheduledTasksForUser(api *web.ApiServer, userId int) ([]Task, error) {
+func GetScheduledTasksForUser(ctx context.Context, api *web.ApiServer, userId uuid.UUID) ([]Task, error) {
 	tasks := []Task{}
-	tasksFromDB, err := api.DB.GetScheduledTasks(userId)
+	tasksFromDB, err := api.DB.GetScheduledTasks(ctx, userId)
 	if errors.Is(err, errx.ErrSomeMinorOccurred) {
 		log.Log(log.LevelWarning, err.Error())
 		// TODO: decide what else to do with view permission errors
@@ -87,9 +88,9 @@ func GetScheduledTasksForUser(api *web.ApiServer, userId int) ([]Task, error) {
 
 // Get all tasks that were saved in the database,
 // cron.StartScheduledTasks should be called after adding all corresponding cron.TaskFunc to the returned Task array
-func GetScheduledTasksFromDB(api *web.ApiServer) ([]Task, error) {
+func GetScheduledTasksFromDB(ctx context.Context, api *web.ApiServer) ([]Task, error) {
 	tasks := []Task{}
-	tasksFromDB, err := api.DB.GetAllScheduledTasks()
+	tasksFromDB, err := api.DB.GetAllScheduledTasks(ctx)
 	if err != nil {
 		return tasks, err
 	}
@@ -139,12 +140,12 @@ func StartScheduledTasks(settings *web.ApiConfigSettings, tasks []Task) error {
 // Requires t.Run to be set to valid cron.TaskFunc.
 // interval must be at least one minute and has some special behaviour if it has one of these specific values, it will run at the time specified in start:
 // cron.Daily, cron.Weekly (at weekday of start), cron.Monthly (at day of month of start, day of start must not be later than the 28th), cron.Yearly (at start datetime)
-func ScheduleAndSaveToDB(api *web.ApiServer, t Task) error {
-	err := Schedule(api.Config.Settings, t)
+func ScheduleAndSaveToDB(ctx context.Context, api *web.ApiServer, t Task) error {
+	err := Schedule(api.Settings(), t)
 	if err != nil {
 		return err
 	}
-	err = api.DB.CreateScheduledTask(table.ScheduledTask{
+	err = api.DB.CreateScheduledTask(ctx, table.ScheduledTask{
 		TaskID:    t.ID,
 		OrgID:     t.OrgID,
 		StartDate: t.Start,
@@ -206,12 +207,12 @@ func Schedule(settings *web.ApiConfigSettings, t Task) error {
 
 // Update already scheduled task, thread safe.
 // Requires t.Run to be set to valid cron.TaskFunc
-func Update(api *web.ApiServer, settings *web.ApiConfigSettings, t Task) error {
+func Update(ctx context.Context, api *web.ApiServer, settings *web.ApiConfigSettings, t Task) error {
 	err := Remove(settings, t.ID)
 	if err != nil && errors.Is(err, ErrTaskRemove) {
 		log.Logf(log.LevelWarning, "task updated, which isn't scheduled: %s", err.Error())
 	}
-	err = api.DB.UpdateScheduledTask(table.ScheduledTask{
+	err = api.DB.UpdateScheduledTask(ctx, table.ScheduledTask{
 		TaskID:    t.ID,
 		OrgID:     t.OrgID,
 		StartDate: t.Start,
@@ -229,12 +230,12 @@ func Update(api *web.ApiServer, settings *web.ApiConfigSettings, t Task) error {
 	return nil
 }
 
-// Remove scheduled task and also from database, thread safe.
-func RemoveAlsoFromDB(api *web.ApiServer, id string) error {
-	err := Remove(api.Config.Settings, id)
-	dbErr := api.DB.DeleteScheduledTask(id)
+// Remove scheduled task and also from database (soft deleted, see db.Store.RestoreScheduledTask()), thread safe.
+func RemoveAlsoFromDB(ctx context.Context, api *web.ApiServer, id string) error {
+	err := Remove(api.Settings(), id)
+	dbErr := api.DB.DeleteScheduledTask(ctx, id)
 	if dbErr != nil {
-		return errx.WrapWithType(ErrTaskDatabaseDelete, err, "")
+		return errx.WrapWithType(ErrTaskDatabaseDelete, dbErr, "")
 	}
 	if err != nil {
 		return errx.Wrap(err, "The scheduled task was successfully removed from database, however")
@@ -278,6 +279,7 @@ func Shutdown(settings *web.ApiConfigSettings) error {
 			log.Logf(log.LevelWarning, "unable to shutdown task (id: %s), timeout (%s) exceeded", id, settings.TimeoutScheduledTaskShutdown.String())
 		}
 		cancel()
+		delete(activeTasks.tasks, id)
 	}
 	activeTasks.Unlock()
 
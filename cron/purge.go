@@ -0,0 +1,37 @@
+package cron
+
+import (
+	"context"
+	"time"
+
+	"gopkg.cc/apibase/log"
+	"gopkg.cc/apibase/web"
+)
+
+// id of the task scheduled by SchedulePurge, must not be used by other tasks
+const PurgeTaskID = "apibase-purge-deleted"
+
+// Schedule task that permanently deletes entries soft deleted longer than settings.PurgeDeletedAfter ago, runs every settings.PurgeInterval.
+// The task isn't saved in database, nothing is scheduled if PurgeDeletedAfter is 0
+func SchedulePurge(api *web.ApiServer) error {
+	settings := api.Settings()
+	if settings.PurgeDeletedAfter <= 0 {
+		return nil
+	}
+	return Schedule(settings, Task{
+		ID:       PurgeTaskID,
+		Start:    time.Now(),
+		Interval: settings.PurgeInterval,
+		TaskType: PurgeTaskID,
+		Run: func(currentTime time.Time, interval time.Duration, data string) error {
+			purged, err := api.DB.PurgeDeleted(context.Background(), currentTime.Add(-settings.PurgeDeletedAfter))
+			if err != nil {
+				return err
+			}
+			if purged > 0 {
+				log.Logf(log.LevelInfo, "purged %d entries deleted more than %s ago", purged, settings.PurgeDeletedAfter.String())
+			}
+			return nil
+		},
+	})
+}
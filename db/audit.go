@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	h "gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/table"
+)
+
+// Limit of GetAuditEvents() if AuditFilter.Limit isn't set or larger
+const AuditEventsMaxLimit = 1000
+
+// Who performed the actions of a request, audit events recorded with a context containing the actor are attributed to it
+type AuditActor struct {
+	UserID    uuid.UUID // uuid.Nil if no user is logged in
+	IP        string
+	UserAgent string
+}
+
+type auditActorKey struct{}
+
+// Context for Store methods, whose audit events are attributed to actor (see web.SetAuditActor() for requests)
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+func AuditActorFromContext(ctx context.Context) (AuditActor, bool) {
+	actor, ok := ctx.Value(auditActorKey{}).(AuditActor)
+	return actor, ok
+}
+
+// Filter of GetAuditEvents(), zero values don't filter
+type AuditFilter struct {
+	ActorID uuid.UUID
+	OrgIDs  []uuid.UUID // events of any of these organizations
+	Action  string
+	From    time.Time // inclusive
+	To      time.Time // exclusive
+	Limit   int       // newest events first, at most AuditEventsMaxLimit
+}
+
+func (f AuditFilter) limit() int {
+	if f.Limit <= 0 || f.Limit > AuditEventsMaxLimit {
+		return AuditEventsMaxLimit
+	}
+	return f.Limit
+}
+
+// set id, creation time and empty diff, actor, ip and user agent are taken from ctx if not set
+func auditEventDefaults(ctx context.Context, event table.AuditEvent) table.AuditEvent {
+	event.ID, event.CreatedAt = table.NewID(), time.Now().UTC()
+	if actor, ok := AuditActorFromContext(ctx); ok {
+		if event.ActorID == nil && actor.UserID != uuid.Nil {
+			event.ActorID = &actor.UserID
+		}
+		if event.IP == "" {
+			event.IP = actor.IP
+		}
+		if event.UserAgent == "" {
+			event.UserAgent = actor.UserAgent
+		}
+	}
+	if event.Diff == "" {
+		event.Diff = "{}"
+	}
+	return event
+}
+
+// Json object of the columns that differ between two table structs, e.g. {"org_admin": {"old": false, "new": true}}.
+// old is nil for created and new for deleted entries, columns with database defaults (e.g. id, created_at), secrets and fields tagged audit:"-" are left out
+func AuditDiff(old any, new any) string {
+	oldValues, newValues := auditValues(old), auditValues(new)
+	diff := map[string]map[string]any{}
+	for column, value := range newValues {
+		if oldValue, ok := oldValues[column]; !ok || !auditEqual(oldValue, value) {
+			diff[column] = map[string]any{"new": value}
+			if ok {
+				diff[column]["old"] = oldValue
+			}
+		}
+	}
+	for column, value := range oldValues {
+		if _, ok := newValues[column]; !ok {
+			diff[column] = map[string]any{"old": value}
+		}
+	}
+	encoded, err := json.Marshal(diff)
+	if err != nil {
+		return "{}"
+	}
+	return string(encoded)
+}
+
+// times are equal regardless of location, e.g. if read from database
+func auditEqual(a any, b any) bool {
+	if timeA, ok := a.(time.Time); ok {
+		timeB, ok := b.(time.Time)
+		return ok && timeA.Equal(timeB)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func auditValues(s any) map[string]any {
+	values := map[string]any{}
+	v := reflect.ValueOf(s)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return values
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		column, ok := field.Tag.Lookup("db")
+		if !ok || column == "-" || column == "deleted_at" || field.Tag.Get("default") == "true" || field.Tag.Get("audit") == "-" ||
+			field.Type == reflect.TypeFor[h.SecretString]() {
+			continue
+		}
+		values[column] = v.Field(i).Interface()
+	}
+	return values
+}
@@ -1,16 +1,66 @@
 package db
 
 import (
+	"time"
+
+	"gopkg.cc/apibase/errx"
 	h "gopkg.cc/apibase/helper"
 )
 
 type PostgresConfig struct {
-	Host     string         `toml:"host"`
+	Host     string         `toml:"host"` // host name, ip or directory of a unix socket, e.g. /var/run/postgresql
 	Port     string         `toml:"port"`
 	User     string         `toml:"user"`
 	Password h.SecretString `toml:"password"`
 	DB       string         `toml:"db"`
-	SSLMode  bool           `toml:"ssl_enabled"`
+
+	SSLMode     string `toml:"ssl_mode"`      // libpq sslmode: disable, allow, prefer (default), require, verify-ca or verify-full
+	SSLRootCert string `toml:"ssl_root_cert"` // CA certificate file the server is verified with, system CAs if not set
+	SSLCert     string `toml:"ssl_cert"`      // client certificate file, requires ssl_key
+	SSLKey      string `toml:"ssl_key"`
+	SSLEnabled  bool   `toml:"ssl_enabled"` // deprecated, ssl_mode "require" if ssl_mode isn't set
+
+	ApplicationName  string   `toml:"application_name"`
+	SearchPath       string   `toml:"search_path"`
+	StatementTimeout string   `toml:"statement_timeout"` // duration, e.g. "30s", queries running longer are canceled by postgres
+	Params           []string `toml:"params"`            // further libpq connection or runtime params as key=value, e.g. "connect_timeout=5"
+
+	// read replicas as host, host:port or unix socket directory, connected with the other options of the primary.
+	// read-only methods (e.g. GetUserByID()) are routed to healthy replicas, writes and transactions to the primary
+	Replicas []string `toml:"replicas"`
+	// reads of a request are served by replicas even after it wrote, which may not be replicated yet (see WithPrimaryAfterWrite())
+	ReplicaReadsAfterWrite bool `toml:"replica_reads_after_write"`
+
+	Pool *PostgresPoolConfig `toml:"pool"`
+}
+
+// Connection pool settings, see pgxpool.Config
+type PostgresPoolConfig struct {
+	MaxConns              int32  `toml:"max_conns"`
+	MinConns              int32  `toml:"min_conns"`
+	TomlMaxConnLifetime   string `toml:"max_conn_lifetime"`
+	TomlMaxConnIdleTime   string `toml:"max_conn_idle_time"`
+	TomlHealthCheckPeriod string `toml:"health_check_period"`
+
+	MaxConnLifetime   time.Duration `internal:"max_conn_lifetime"`
+	MaxConnIdleTime   time.Duration `internal:"max_conn_idle_time"`
+	HealthCheckPeriod time.Duration `internal:"health_check_period"`
+}
+
+func (pc *PostgresPoolConfig) AddMissingFromDefaults() error {
+	defaults := &PostgresPoolConfig{
+		MaxConns:          10,
+		MaxConnLifetime:   time.Hour,
+		MaxConnIdleTime:   time.Minute * 30,
+		HealthCheckPeriod: time.Minute,
+	}
+	if err := h.ParseTomlConfigAndDefaults(pc, defaults); err != nil {
+		return err
+	}
+	if pc.HealthCheckPeriod <= 0 {
+		return errx.NewWithTypef(ErrDatabaseConfig, "invalid health_check_period '%s', must be positive", pc.TomlHealthCheckPeriod)
+	}
+	return nil
 }
 
 type SQLiteConfig struct {
@@ -3,10 +3,9 @@ package db
 import (
 	"context"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"gopkg.cc/apibase/baseconfig"
 	"gopkg.cc/apibase/errx"
-	"gopkg.cc/apibase/log"
 	"gopkg.cc/apibase/sqlite"
 )
 
@@ -22,11 +21,15 @@ const (
 type DB struct {
 	Kind       DBKind
 	SQLite     *sqlite.SQLite
-	Postgres   *pgx.Conn
+	Postgres   *pgxpool.Pool
 	BaseConfig *baseconfig.BaseConfig
+
+	lockFile string       // SQLite lock file, removed on Close()
+	tracer   *queryTracer // query statistics, see Stats()
+	replicas *replicaSet  // Postgres read replicas, nil if none are configured
 }
 
-func ValidateDB(database DB) error {
+func ValidateDB(ctx context.Context, database DB) error {
 	if database.BaseConfig == nil {
 		return errx.NewWithType(ErrMissingBaseConfig, "")
 	}
@@ -35,13 +38,17 @@ func ValidateDB(database DB) error {
 		if database.SQLite == nil {
 			return errx.NewWithType(ErrDatabaseConfig, "no valid SQLite database adapter")
 		}
-		// TODO: this
-		return errx.NewWithType(errx.ErrNotImplemented, "sqlite driver for apibase not implemented yet")
+		ctx, cancel := context.WithTimeout(ctx, database.BaseConfig.TimeoutDatabaseConnect)
+		defer cancel()
+		err := database.SQLite.DB.PingContext(ctx)
+		if err != nil {
+			return errx.WrapWithType(ErrDatabaseConn, err, "unable to ping SQLite database")
+		}
 	case PostgreSQL:
 		if database.Postgres == nil {
 			return errx.NewWithType(ErrDatabaseConfig, "no valid PostgreSQL database adapter")
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), database.BaseConfig.TimeoutDatabaseConnect)
+		ctx, cancel := context.WithTimeout(ctx, database.BaseConfig.TimeoutDatabaseConnect)
 		defer cancel()
 		err := database.Postgres.Ping(ctx)
 		if err != nil {
@@ -52,25 +59,3 @@ func ValidateDB(database DB) error {
 	}
 	return nil
 }
-
-func MigrateDefaultTables(database DB) error {
-	// ctx, cancel := context.WithTimeout(context.Background(), database.BaseConfig.TimeoutDatabaseConnect)
-	// defer cancel()
-	switch database.Kind {
-	case SQLite:
-		// TODO: do this
-		return errx.NewWithType(errx.ErrNotImplemented, "sqlite tables not migrated")
-	case PostgreSQL:
-		// users := []table.User{}
-		// err := pgxscan.Select(ctx, database.Postgres, &users, "SELECT * FROM users")
-		// if err != nil {
-		// 	return errx.WrapWithType(ErrDatabaseMigration, err, "")
-		// }
-		// log.Logf(log.LevelDebug, "Users: %+v", users)
-		// TODO: read tables from db and verify they match the local struct
-		log.Log(log.LevelInfo, "Successfully migrated PostgreSQL Tables.")
-	default:
-		return errx.NewWithTypef(ErrDatabaseMigration, "no valid DB specified, db.DBKind(%d)", database.Kind)
-	}
-	return nil
-}
@@ -4,4 +4,6 @@ import "time"
 
 const (
 	SLEEP_DATABASE_RECONNECT = time.Second * 2
+	// initial wait before retrying a failed database operation, doubled on every retry up to SLEEP_DATABASE_RECONNECT
+	DATABASE_RETRY_BACKOFF = time.Millisecond * 100
 )
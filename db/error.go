@@ -5,7 +5,10 @@ import "gopkg.cc/apibase/errx"
 var (
 	ErrDatabaseConfig    = errx.NewType("database config invalid")
 	ErrDatabaseMigration = errx.NewType("database migration failed")
+	ErrDatabaseSchema    = errx.NewType("database schema doesn't match table structs")
 	ErrDatabaseConn      = errx.NewType("database connect failed")
+	ErrDatabaseClose     = errx.NewType("database close failed")
+	ErrDatabaseLocked    = errx.NewType("database is locked by another process")
 	ErrDatabaseQuery     = errx.NewType("database query error")
 	ErrDatabaseNotFound  = errx.NewType("database entry not found")
 	ErrDatabaseCommit    = errx.NewType("database tx commit failed")
@@ -13,8 +16,11 @@ var (
 	ErrDatabaseInsert    = errx.NewType("database insert into failed")
 	ErrDatabaseUpdate    = errx.NewType("database update failed")
 	ErrDatabaseDelete    = errx.NewType("database delete failed")
+	ErrDatabaseRestore   = errx.NewType("database restore of deleted entry failed")
+	ErrInvalidListQuery  = errx.NewType("list query has invalid filter, sort or cursor")
 	ErrUserAlreadyExists = errx.NewType("user already exists")
 	ErrNoRoles           = errx.NewType("missing required roles")
 	ErrOrgCreate         = errx.NewType("organization couldn't be created")
+	ErrOrgSlugExists     = errx.NewType("organization slug already exists")
 	ErrMissingBaseConfig = errx.NewType("baseconfig.BaseConfig is not defined for db.DB")
 )
@@ -0,0 +1,234 @@
+package db
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"gopkg.cc/apibase/errx"
+)
+
+const (
+	ListDefaultLimit = 50   // page size of ListQuery if Limit isn't set
+	ListMaxLimit     = 1000 // largest allowed page size of ListQuery
+)
+
+// Query of the List methods (e.g. ListUsers()), filters and sort keys must be allowed by the method,
+// otherwise ErrInvalidListQuery is returned
+type ListQuery struct {
+	Filters map[string]string // equal to value, by column name, e.g. {"task_type": "report"}
+	Sort    string            // column name, prefixed with "-" for descending order, the default of the method if empty
+	Cursor  string            // ListPage.NextCursor of the previous page, empty for the first page
+	Limit   int               // ListDefaultLimit if not set, at most ListMaxLimit
+}
+
+type ListPage[T any] struct {
+	Items      []T
+	NextCursor string // empty on the last page
+	Total      int64  // entries matching the filters, of all pages
+}
+
+// allowed filters and sort keys of a list method, entries are paginated by (sort column, id), so sort columns must not be null
+type listSpec struct {
+	table       string
+	filters     map[string]func(value string) (any, error) // column -> parse filter value
+	sorts       []string
+	defaultSort string
+}
+
+func filterString(value string) (any, error) { return value, nil }
+func filterBool(value string) (any, error)   { return strconv.ParseBool(value) }
+func filterUUID(value string) (any, error)   { return uuid.FromString(value) }
+
+// validated ListQuery
+type listQuery struct {
+	filters []listFilter // sorted by column for stable queries
+	sort    string
+	desc    bool
+	cursor  uuid.UUID // id of the last entry of the previous page, uuid.Nil for the first page
+	after   any       // sort value of the last entry of the previous page
+	limit   int
+}
+
+type listFilter struct {
+	column string
+	value  any
+}
+
+// the cursor contains the sort key, sort value and id of the last entry, so it stays valid once the entry is deleted
+type listCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    uuid.UUID       `json:"id"`
+}
+
+// entry is any entry of the listed table, e.g. its zero value, to decode the sort value of the cursor
+func (spec listSpec) parse(q ListQuery, entry any) (listQuery, error) {
+	parsed := listQuery{sort: spec.defaultSort, limit: q.Limit}
+	for column, value := range q.Filters {
+		parse, ok := spec.filters[column]
+		if !ok {
+			return parsed, errx.NewWithTypef(ErrInvalidListQuery, "%s can't be filtered by '%s'", spec.table, column)
+		}
+		v, err := parse(value)
+		if err != nil {
+			return parsed, errx.WrapWithTypef(ErrInvalidListQuery, err, "filter '%s'", column)
+		}
+		parsed.filters = append(parsed.filters, listFilter{column, v})
+	}
+	slices.SortFunc(parsed.filters, func(a, b listFilter) int { return strings.Compare(a.column, b.column) })
+
+	if q.Sort != "" {
+		parsed.sort, parsed.desc = strings.CutPrefix(q.Sort, "-")
+		if !slices.Contains(spec.sorts, parsed.sort) {
+			return parsed, errx.NewWithTypef(ErrInvalidListQuery, "%s can't be sorted by '%s'", spec.table, parsed.sort)
+		}
+	}
+	if q.Cursor != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+		if err != nil {
+			return parsed, errx.WrapWithType(ErrInvalidListQuery, err, "cursor")
+		}
+		cursor := listCursor{}
+		if err := json.Unmarshal(decoded, &cursor); err != nil {
+			return parsed, errx.WrapWithType(ErrInvalidListQuery, err, "cursor")
+		}
+		if cursor.Sort != q.Sort {
+			return parsed, errx.NewWithTypef(ErrInvalidListQuery, "cursor is of sort '%s' instead of '%s'", cursor.Sort, q.Sort)
+		}
+		after := reflect.New(reflect.TypeOf(columnValue(entry, parsed.sort)))
+		if err := json.Unmarshal(cursor.Value, after.Interface()); err != nil || cursor.ID == uuid.Nil {
+			return parsed, errx.NewWithType(ErrInvalidListQuery, "cursor has no valid sort value or id")
+		}
+		parsed.cursor, parsed.after = cursor.ID, after.Elem().Interface()
+	}
+	if parsed.limit <= 0 {
+		parsed.limit = ListDefaultLimit
+	}
+	parsed.limit = min(parsed.limit, ListMaxLimit)
+	return parsed, nil
+}
+
+// page of at most limit items, items may contain one more entry to detect further pages
+func newListPage[T any](items []T, q ListQuery, parsed listQuery, total int64) ListPage[T] {
+	page := ListPage[T]{Items: items, Total: total}
+	if len(items) > parsed.limit {
+		page.Items = items[:parsed.limit]
+		last := page.Items[parsed.limit-1]
+		value, _ := json.Marshal(columnValue(last, parsed.sort))
+		encoded, _ := json.Marshal(listCursor{Sort: q.Sort, Value: value, ID: columnValue(last, "id").(uuid.UUID)})
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(encoded)
+	}
+	return page
+}
+
+// page of entries matching conditions (with args as $1, $2, ...) and the filters of q
+func list[T any](ctx context.Context, db DB, spec listSpec, q ListQuery, conditions []string, args ...any) (ListPage[T], error) {
+	parsed, err := spec.parse(q, *new(T))
+	if err != nil {
+		return ListPage[T]{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	for _, filter := range parsed.filters {
+		args = append(args, filter.value)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", filter.column, len(args)))
+	}
+	where := strings.Join(conditions, " AND ")
+	conn := db.read(ctx) // count and page of the same replica
+
+	var total int64
+	if err := conn.scanOne(ctx, &total, fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", spec.table, where), args...); err != nil {
+		return ListPage[T]{}, errx.WrapWithType(ErrDatabaseQuery, err, "")
+	}
+
+	order, compare := "ASC", ">"
+	if parsed.desc {
+		order, compare = "DESC", "<"
+	}
+	sort, after, afterArg := parsed.sort, parsed.after, "$%d"
+	if t, ok := after.(time.Time); ok && db.Kind == SQLite {
+		// SQLite stores times as text of different formats (CURRENT_TIMESTAMP or go-sqlite3), which are compared as julian day
+		sort, after, afterArg = "julianday("+sort+")", t.UTC().Format("2006-01-02 15:04:05.000"), "julianday($%d)"
+	}
+	if parsed.cursor != uuid.Nil {
+		args = append(args, after, parsed.cursor)
+		where += fmt.Sprintf(" AND (%s, id) %s ("+afterArg+", $%d)", sort, compare, len(args)-1, len(args))
+	}
+	args = append(args, parsed.limit+1)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s ORDER BY %s %s, id %s LIMIT $%d", spec.table, where, sort, order, order, len(args))
+	items := []T{}
+	if err := conn.scanAll(ctx, &items, query, args...); err != nil {
+		return ListPage[T]{}, errx.WrapWithType(ErrDatabaseQuery, err, "")
+	}
+	return newListPage(items, q, parsed, total), nil
+}
+
+// page of the entries in all for which match is true, the MemoryStore equivalent of list()
+func listMemory[T any](all []T, match func(entry T) bool, spec listSpec, q ListQuery) (ListPage[T], error) {
+	parsed, err := spec.parse(q, *new(T))
+	if err != nil {
+		return ListPage[T]{}, err
+	}
+	items := []T{}
+	for _, entry := range all {
+		if match(entry) && !slices.ContainsFunc(parsed.filters, func(f listFilter) bool { return columnValue(entry, f.column) != f.value }) {
+			items = append(items, entry)
+		}
+	}
+	total := int64(len(items))
+
+	// order of (sort value, id) pairs
+	compare := func(aSort any, aID any, bSort any, bID any) int {
+		c := cmp.Or(compareValues(aSort, bSort), compareValues(aID, bID))
+		if parsed.desc {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(items, func(a, b T) int {
+		return compare(columnValue(a, parsed.sort), columnValue(a, "id"), columnValue(b, parsed.sort), columnValue(b, "id"))
+	})
+	if parsed.cursor != uuid.Nil {
+		items = slices.DeleteFunc(items, func(entry T) bool {
+			return compare(columnValue(entry, parsed.sort), columnValue(entry, "id"), parsed.after, parsed.cursor) <= 0
+		})
+	}
+	if len(items) > parsed.limit+1 {
+		items = items[:parsed.limit+1]
+	}
+	return newListPage(items, q, parsed, total), nil
+}
+
+// value of the struct field with the db tag column
+func columnValue(s any, column string) any {
+	v := reflect.ValueOf(s)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("db") == column {
+			return v.Field(i).Interface()
+		}
+	}
+	return nil
+}
+
+// order of column values like the database, uuids are compared by their bytes
+func compareValues(a any, b any) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case uuid.UUID:
+		return bytes.Compare(a.Bytes(), b.(uuid.UUID).Bytes())
+	}
+	return 0
+}
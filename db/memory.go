@@ -0,0 +1,714 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"gopkg.cc/apibase/errx"
+	h "gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/log"
+	"gopkg.cc/apibase/table"
+)
+
+// In-memory Store for unit tests, enforces the unique and foreign key constraints of the default tables.
+// Entries are lost once the store isn't referenced anymore
+type MemoryStore struct {
+	mtx           sync.Mutex
+	users         []table.User
+	orgs          []table.Organization
+	roles         []table.UserRole
+	refreshTokens []table.RefreshToken
+	tasks         []table.ScheduledTask
+	auditEvents   []table.AuditEvent
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (m *MemoryStore) CreateNewUserWithOrg(ctx context.Context, user table.User, roles ...table.UserRole) (table.User, error) {
+	if len(roles) > 0 {
+		// Don't create new org for user, instead assign user defined roles
+		return m.CreateUserIfNotExist(ctx, user, roles...)
+	}
+	return m.CreateNewUserWithTenant(ctx, user, table.Organization{})
+}
+
+func (m *MemoryStore) CreateNewUserWithTenant(ctx context.Context, user table.User, tenant table.Organization) (table.User, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if _, ok := m.userByEmail(user.Email); ok {
+		return user, errx.NewWithType(ErrUserAlreadyExists, "user can't be created")
+	}
+	if tenant.Name == "" {
+		tenant = defaultUserTenant(user)
+	}
+	if err := m.checkUser(user); err != nil {
+		return user, err
+	}
+	tenant, err := m.orgDefaults(tenant)
+	if err != nil {
+		return user, errx.WrapWithType(ErrOrgCreate, err, "for new user")
+	}
+	createdUser := m.createUser(user)
+	m.orgs = append(m.orgs, tenant)
+	m.createRole(ctx, table.UserRole{UserID: createdUser.ID, OrgID: tenant.ID, OrgView: true, OrgEdit: true, OrgAdmin: true})
+	return createdUser, nil
+}
+
+func (m *MemoryStore) CreateUserIfNotExist(ctx context.Context, user table.User, roles ...table.UserRole) (table.User, error) {
+	if len(roles) < 1 {
+		return user, errx.NewWithType(ErrNoRoles, "CreateUserIfNotExist must have at least one role for the new user")
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if _, ok := m.userByEmail(user.Email); ok {
+		return user, errx.NewWithType(ErrUserAlreadyExists, "user can't be created")
+	}
+	if err := m.checkUser(user); err != nil {
+		return user, err
+	}
+	for i, role := range roles {
+		if !m.orgExists(role.OrgID) || slices.ContainsFunc(roles[:i], func(r table.UserRole) bool { return r.OrgID == role.OrgID }) {
+			return user, errx.NewWithTypef(ErrDatabaseInsert, "role for org (id: %s) could not be created", role.OrgID)
+		}
+	}
+	createdUser := m.createUser(user)
+	for _, role := range roles {
+		role.UserID = createdUser.ID
+		m.createRole(ctx, role)
+	}
+	return createdUser, nil
+}
+
+// unique user is defined by user.Email, also creates the default viewer role for the specified organization
+func (m *MemoryStore) GetOrCreateUser(ctx context.Context, user table.User, role table.UserRole) (table.User, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if existing, ok := m.userByEmail(user.Email); ok {
+		return existing, nil
+	}
+	if err := m.checkUser(user); err != nil {
+		return user, err
+	}
+	if !m.orgExists(role.OrgID) {
+		return user, errx.NewWithTypef(ErrDatabaseInsert, "role for org (id: %s) could not be created", role.OrgID)
+	}
+	createdUser := m.createUser(user)
+	role.UserID = createdUser.ID
+	m.createRole(ctx, role)
+	log.Logf(log.LevelDebug, "User created: %s (%s)", user.Name, user.Email)
+	return createdUser, nil
+}
+
+func (m *MemoryStore) GetUserByID(ctx context.Context, id uuid.UUID) (table.User, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	i := m.userIndex(id)
+	if i < 0 {
+		return table.User{}, errx.NewWithTypef(ErrDatabaseNotFound, "no user found for id '%s'", id)
+	}
+	return m.users[i], nil
+}
+
+func (m *MemoryStore) GetUserByEmail(ctx context.Context, email string) (table.User, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	user, ok := m.userByEmail(email)
+	if !ok {
+		return user, errx.NewWithTypef(ErrDatabaseNotFound, "no user found for email '%s'", email)
+	}
+	return user, nil
+}
+
+func (m *MemoryStore) GetAllUsers(ctx context.Context) ([]table.User, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	users := []table.User{}
+	for _, user := range m.users {
+		if user.DeletedAt == nil {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (m *MemoryStore) ListUsers(ctx context.Context, q ListQuery) (ListPage[table.User], error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return listMemory(m.users, func(user table.User) bool { return user.DeletedAt == nil }, userListSpec, q)
+}
+
+// disabling a user also revokes all sessions of the user
+func (m *MemoryStore) SetUserDisabled(ctx context.Context, userID uuid.UUID, disabled bool) error {
+	return m.updateUser(userID, func(user *table.User) {
+		user.Disabled = disabled
+		if disabled {
+			m.revokeSessions(ctx, userID)
+		}
+	})
+}
+
+func (m *MemoryStore) SetUserSuperAdmin(ctx context.Context, userID uuid.UUID, superAdmin bool) error {
+	return m.updateUser(userID, func(user *table.User) {
+		user.SuperAdmin = superAdmin
+	})
+}
+
+// passwordHash must already be hashed, secrets version is increased and all sessions of the user are revoked
+func (m *MemoryStore) UpdateUserPassword(ctx context.Context, userID uuid.UUID, passwordHash h.SecretString) error {
+	return m.updateUser(userID, func(user *table.User) {
+		user.PasswordHash = passwordHash
+		user.SecretsVersion++
+		m.revokeSessions(ctx, userID)
+	})
+}
+
+func (m *MemoryStore) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]table.UserRole, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	roles := []table.UserRole{}
+	for _, role := range m.roles {
+		if role.UserID == userID && role.DeletedAt == nil {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) < 1 {
+		return roles, errx.NewWithTypef(ErrDatabaseNotFound, "no roles found for user (id: %s)", userID)
+	}
+	return roles, nil
+}
+
+func (m *MemoryStore) ListUserRoles(ctx context.Context, userID uuid.UUID, q ListQuery) (ListPage[table.UserRole], error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return listMemory(m.roles, func(role table.UserRole) bool { return role.UserID == userID && role.DeletedAt == nil }, userRoleListSpec, q)
+}
+
+func (m *MemoryStore) CreateUserRole(ctx context.Context, role table.UserRole) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if !m.userExists(role.UserID) || !m.orgExists(role.OrgID) ||
+		slices.ContainsFunc(m.roles, func(r table.UserRole) bool {
+			return r.UserID == role.UserID && r.OrgID == role.OrgID && r.DeletedAt == nil
+		}) {
+		return errx.NewWithTypef(ErrDatabaseInsert, "role for user (id: %s) could not be created", role.UserID)
+	}
+	m.createRole(ctx, role)
+	return nil
+}
+
+func (m *MemoryStore) CreateOrg(ctx context.Context, org table.Organization) (table.Organization, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	org, err := m.orgDefaults(org)
+	if err != nil {
+		return table.Organization{}, err
+	}
+	m.orgs = append(m.orgs, org)
+	return org, nil
+}
+
+func (m *MemoryStore) GetOrgByName(ctx context.Context, name string) (table.Organization, error) {
+	return m.getOrg(func(o table.Organization) bool { return o.Name == name }, "name '"+name+"'")
+}
+
+func (m *MemoryStore) GetOrgBySlug(ctx context.Context, slug string) (table.Organization, error) {
+	return m.getOrg(func(o table.Organization) bool { return o.Slug == slug }, "slug '"+slug+"'")
+}
+
+func (m *MemoryStore) GetOrgByExternalID(ctx context.Context, tenantType string, externalID string) (table.Organization, error) {
+	if externalID == "" {
+		return table.Organization{}, errx.NewWithType(ErrDatabaseNotFound, "no organization found for empty external id")
+	}
+	return m.getOrg(func(o table.Organization) bool { return o.TenantType == tenantType && o.ExternalID == externalID },
+		fmt.Sprintf("%s with external id '%s'", tenantType, externalID))
+}
+
+func (m *MemoryStore) ListOrgs(ctx context.Context, q ListQuery) (ListPage[table.Organization], error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return listMemory(m.orgs, func(org table.Organization) bool { return org.DeletedAt == nil }, orgListSpec, q)
+}
+
+func (m *MemoryStore) getOrg(match func(o table.Organization) bool, description string) (table.Organization, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	i := slices.IndexFunc(m.orgs, func(o table.Organization) bool { return match(o) && o.DeletedAt == nil })
+	if i < 0 {
+		return table.Organization{}, errx.NewWithTypef(ErrDatabaseNotFound, "no organization found for %s", description)
+	}
+	return m.orgs[i], nil
+}
+
+func (m *MemoryStore) CreateRefreshTokenEntry(ctx context.Context, token table.RefreshToken) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if !m.userExists(token.UserID) || m.sessionExists(token.SessionID) {
+		return errx.NewWithType(ErrDatabaseInsert, "refresh token entry for user could not be created")
+	}
+	now := time.Now()
+	token.ID, token.CreatedAt, token.UpdatedAt = table.NewID(), now, now
+	m.refreshTokens = append(m.refreshTokens, token)
+	return nil
+}
+
+func (m *MemoryStore) VerifyRefreshTokenSessionId(ctx context.Context, userID uuid.UUID, sessionId h.SecretString) (bool, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.refreshTokenIndex(userID, sessionId) >= 0, nil
+}
+
+func (m *MemoryStore) UpdateRefreshTokenEntry(ctx context.Context, userId uuid.UUID, sessionId h.SecretString, newSessionId h.SecretString, userAgent string, expiresAt time.Time) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	i := m.refreshTokenIndex(userId, sessionId)
+	if i < 0 {
+		return errx.Wrap(errx.NewWithType(ErrDatabaseNotFound, "no refresh token found"), "unable to get existing entry for token")
+	}
+	if newSessionId.GetSecret() != sessionId.GetSecret() && m.sessionExists(newSessionId) {
+		return errx.Wrap(errx.NewWithType(ErrDatabaseUpdate, "unable to update refresh token"), "unable to update refresh token entry")
+	}
+	token := &m.refreshTokens[i]
+	token.SessionID = newSessionId
+	token.ReissueCount++
+	token.UserAgent = userAgent
+	token.ExpiresAt = expiresAt
+	token.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *MemoryStore) DeleteRefreshToken(ctx context.Context, userID uuid.UUID, sessionId h.SecretString) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	i := m.refreshTokenIndex(userID, sessionId)
+	if i < 0 {
+		return errx.NewWithTypef(ErrDatabaseDelete, "refresh token rows affected != 1 (instead got %d)", 0)
+	}
+	m.refreshTokens = slices.Delete(m.refreshTokens, i, i+1)
+	return nil
+}
+
+// Revoke all sessions of the user, returns the number of revoked sessions
+func (m *MemoryStore) DeleteRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.revokeSessions(ctx, userID), nil
+}
+
+func (m *MemoryStore) GetScheduledTask(ctx context.Context, taskId string) (table.ScheduledTask, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	i := m.taskIndex(taskId)
+	if i < 0 {
+		return table.ScheduledTask{}, errx.NewWithTypef(ErrDatabaseNotFound, "no task found with id '%s'", taskId)
+	}
+	return m.tasks[i], nil
+}
+
+func (m *MemoryStore) GetScheduledTasks(ctx context.Context, userId uuid.UUID) ([]table.ScheduledTask, error) {
+	tasks := []table.ScheduledTask{}
+	roles, err := m.GetUserRoles(ctx, userId)
+	if err != nil {
+		return tasks, err
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	var noViewPermsForOrg []uuid.UUID
+	for _, role := range roles {
+		if !role.OrgView {
+			noViewPermsForOrg = append(noViewPermsForOrg, role.OrgID)
+			continue
+		}
+		for _, task := range m.tasks {
+			if task.OrgID == role.OrgID && task.DeletedAt == nil {
+				tasks = append(tasks, task)
+			}
+		}
+	}
+	if len(noViewPermsForOrg) > 0 {
+		return tasks, errx.NewWithTypef(errx.ErrSomeMinorOccurred, "User doesn't have view permission for organizations: %+v", noViewPermsForOrg)
+	}
+	return tasks, nil
+}
+
+func (m *MemoryStore) GetAllScheduledTasks(ctx context.Context) ([]table.ScheduledTask, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	tasks := []table.ScheduledTask{}
+	for _, task := range m.tasks {
+		if task.DeletedAt == nil {
+			tasks = append(tasks, task)
+		}
+	}
+	if len(tasks) < 1 {
+		return tasks, errx.NewWithType(ErrDatabaseNotFound, "no tasks found")
+	}
+	return tasks, nil
+}
+
+func (m *MemoryStore) ListScheduledTasks(ctx context.Context, orgID uuid.UUID, q ListQuery) (ListPage[table.ScheduledTask], error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return listMemory(m.tasks, func(task table.ScheduledTask) bool { return task.OrgID == orgID && task.DeletedAt == nil }, scheduledTaskListSpec, q)
+}
+
+func (m *MemoryStore) CreateScheduledTask(ctx context.Context, task table.ScheduledTask) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.taskIndex(task.TaskID) >= 0 || !m.orgExists(task.OrgID) {
+		return errx.NewWithType(ErrDatabaseInsert, "scheduled task entry could not be created")
+	}
+	now := time.Now()
+	task.ID, task.CreatedAt, task.UpdatedAt = table.NewID(), now, now
+	m.tasks = append(m.tasks, task)
+	m.auditEvents = append(m.auditEvents, auditEventDefaults(ctx, taskEvent(table.AuditTaskCreate, nil, &task)))
+	return nil
+}
+
+// like an UPDATE statement, updating a task that doesn't exist isn't an error
+func (m *MemoryStore) UpdateScheduledTask(ctx context.Context, task table.ScheduledTask) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	i := m.taskIndex(task.TaskID)
+	if i < 0 {
+		return nil
+	}
+	if !m.orgExists(task.OrgID) {
+		return errx.NewWithType(ErrDatabaseUpdate, "scheduled task could not be updated")
+	}
+	existing, old := &m.tasks[i], m.tasks[i]
+	existing.OrgID = task.OrgID
+	existing.StartDate = task.StartDate
+	existing.Interval = task.Interval
+	existing.TaskType = task.TaskType
+	existing.TaskData = task.TaskData
+	existing.UpdatedAt = time.Now()
+	m.auditEvents = append(m.auditEvents, auditEventDefaults(ctx, taskEvent(table.AuditTaskUpdate, &old, existing)))
+	return nil
+}
+
+// soft delete, the task can be restored with RestoreScheduledTask until it is purged
+func (m *MemoryStore) DeleteScheduledTask(ctx context.Context, taskId string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	i := m.taskIndex(taskId)
+	if i < 0 {
+		return errx.NewWithTypef(ErrDatabaseDelete, "no scheduled task found with id '%s'", taskId)
+	}
+	m.tasks[i].DeletedAt = timeRef(time.Now().UTC())
+	m.auditEvents = append(m.auditEvents, auditEventDefaults(ctx, taskEvent(table.AuditTaskDelete, &m.tasks[i], nil)))
+	return nil
+}
+
+// restore the last deleted task with taskId, the organization of the task must not be deleted
+func (m *MemoryStore) RestoreScheduledTask(ctx context.Context, taskId string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	last := -1
+	for i, task := range m.tasks {
+		if task.TaskID == taskId && task.DeletedAt != nil && m.orgExists(task.OrgID) &&
+			(last < 0 || task.DeletedAt.After(*m.tasks[last].DeletedAt)) {
+			last = i
+		}
+	}
+	if last < 0 {
+		return errx.NewWithTypef(ErrDatabaseNotFound, "no deleted task found with id '%s'", taskId)
+	}
+	if m.taskIndex(taskId) >= 0 {
+		return errx.NewWithTypef(ErrDatabaseRestore, "task with id '%s' already exists", taskId)
+	}
+	m.tasks[last].DeletedAt = nil
+	m.tasks[last].UpdatedAt = time.Now()
+	return nil
+}
+
+// soft delete user and the roles of the user and revoke all sessions, the user can be restored with RestoreUser until it is purged
+func (m *MemoryStore) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	i := m.userIndex(userID)
+	if i < 0 {
+		return errx.NewWithTypef(ErrDatabaseNotFound, "no user found for id '%s'", userID)
+	}
+	now := time.Now().UTC()
+	m.users[i].DeletedAt = timeRef(now)
+	for j, role := range m.roles {
+		if role.UserID == userID && role.DeletedAt == nil {
+			m.roles[j].DeletedAt = timeRef(now)
+		}
+	}
+	m.revokeSessions(ctx, userID)
+	return nil
+}
+
+// restore deleted user and the roles deleted together with the user, except roles of deleted organizations.
+// Fails with ErrUserAlreadyExists if another user with the same email was created in the meantime
+func (m *MemoryStore) RestoreUser(ctx context.Context, userID uuid.UUID) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	i := slices.IndexFunc(m.users, func(u table.User) bool { return u.ID == userID && u.DeletedAt != nil })
+	if i < 0 {
+		return errx.NewWithTypef(ErrDatabaseNotFound, "no deleted user found for id '%s'", userID)
+	}
+	user := &m.users[i]
+	if _, ok := m.userByEmail(user.Email); ok {
+		return errx.NewWithType(ErrUserAlreadyExists, "user can't be restored")
+	}
+	if err := m.checkUser(*user); err != nil {
+		return errx.WrapWithTypef(ErrDatabaseRestore, err, "unable to restore user (id: %s)", userID)
+	}
+	for j, role := range m.roles {
+		if role.UserID == userID && role.DeletedAt != nil && role.DeletedAt.Equal(*user.DeletedAt) && m.orgExists(role.OrgID) {
+			m.roles[j].DeletedAt = nil
+		}
+	}
+	user.DeletedAt = nil
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// all deleted users that weren't purged yet, last deleted first
+func (m *MemoryStore) GetDeletedUsers(ctx context.Context) ([]table.User, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	users := []table.User{}
+	for _, user := range m.users {
+		if user.DeletedAt != nil {
+			users = append(users, user)
+		}
+	}
+	slices.SortStableFunc(users, func(a, b table.User) int { return b.DeletedAt.Compare(*a.DeletedAt) })
+	return users, nil
+}
+
+// soft delete organization together with its roles and scheduled tasks, it can be restored with RestoreOrg until it is purged
+func (m *MemoryStore) DeleteOrg(ctx context.Context, orgID uuid.UUID) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	i := slices.IndexFunc(m.orgs, func(o table.Organization) bool { return o.ID == orgID && o.DeletedAt == nil })
+	if i < 0 {
+		return errx.NewWithTypef(ErrDatabaseNotFound, "no organization found for id '%s'", orgID)
+	}
+	now := time.Now().UTC()
+	m.orgs[i].DeletedAt = timeRef(now)
+	for j, role := range m.roles {
+		if role.OrgID == orgID && role.DeletedAt == nil {
+			m.roles[j].DeletedAt = timeRef(now)
+		}
+	}
+	for j, task := range m.tasks {
+		if task.OrgID == orgID && task.DeletedAt == nil {
+			m.tasks[j].DeletedAt = timeRef(now)
+		}
+	}
+	return nil
+}
+
+// restore deleted organization and the roles and scheduled tasks deleted together with it,
+// except roles of deleted users and tasks whose id was reused in the meantime
+func (m *MemoryStore) RestoreOrg(ctx context.Context, orgID uuid.UUID) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	i := slices.IndexFunc(m.orgs, func(o table.Organization) bool { return o.ID == orgID && o.DeletedAt != nil })
+	if i < 0 {
+		return errx.NewWithTypef(ErrDatabaseNotFound, "no deleted organization found for id '%s'", orgID)
+	}
+	org := &m.orgs[i]
+	if err := m.checkOrg(*org); err != nil {
+		return errx.WrapWithTypef(ErrDatabaseRestore, err, "organization '%s' already exists", org.Name)
+	}
+	for j, role := range m.roles {
+		if role.OrgID == orgID && role.DeletedAt != nil && role.DeletedAt.Equal(*org.DeletedAt) && m.userExists(role.UserID) {
+			m.roles[j].DeletedAt = nil
+		}
+	}
+	for j, task := range m.tasks {
+		if task.OrgID == orgID && task.DeletedAt != nil && task.DeletedAt.Equal(*org.DeletedAt) && m.taskIndex(task.TaskID) < 0 {
+			m.tasks[j].DeletedAt = nil
+		}
+	}
+	org.DeletedAt = nil
+	return nil
+}
+
+// all deleted organizations that weren't purged yet, last deleted first
+func (m *MemoryStore) GetDeletedOrgs(ctx context.Context) ([]table.Organization, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	orgs := []table.Organization{}
+	for _, org := range m.orgs {
+		if org.DeletedAt != nil {
+			orgs = append(orgs, org)
+		}
+	}
+	slices.SortStableFunc(orgs, func(a, b table.Organization) int { return b.DeletedAt.Compare(*a.DeletedAt) })
+	return orgs, nil
+}
+
+// Permanently delete all entries that were soft deleted before deletedBefore, returns the number of purged rows
+func (m *MemoryStore) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	purge := func(deletedAt *time.Time) bool { return deletedAt != nil && deletedAt.Before(deletedBefore) }
+	purgedUsers := map[uuid.UUID]bool{}
+	for _, user := range m.users {
+		purgedUsers[user.ID] = purge(user.DeletedAt)
+	}
+	purgedOrgs := map[uuid.UUID]bool{}
+	for _, org := range m.orgs {
+		purgedOrgs[org.ID] = purge(org.DeletedAt)
+	}
+	before := len(m.tasks) + len(m.roles) + len(m.refreshTokens) + len(m.users) + len(m.orgs)
+	m.tasks = slices.DeleteFunc(m.tasks, func(t table.ScheduledTask) bool { return purge(t.DeletedAt) || purgedOrgs[t.OrgID] })
+	m.roles = slices.DeleteFunc(m.roles, func(r table.UserRole) bool {
+		return purge(r.DeletedAt) || purgedUsers[r.UserID] || purgedOrgs[r.OrgID]
+	})
+	m.refreshTokens = slices.DeleteFunc(m.refreshTokens, func(t table.RefreshToken) bool { return purgedUsers[t.UserID] })
+	m.users = slices.DeleteFunc(m.users, func(u table.User) bool { return purgedUsers[u.ID] })
+	m.orgs = slices.DeleteFunc(m.orgs, func(o table.Organization) bool { return purgedOrgs[o.ID] })
+	return int64(before - len(m.tasks) - len(m.roles) - len(m.refreshTokens) - len(m.users) - len(m.orgs)), nil
+}
+
+// all following methods require m.mtx to be locked
+
+func (m *MemoryStore) CreateAuditEvent(ctx context.Context, event table.AuditEvent) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.auditEvents = append(m.auditEvents, auditEventDefaults(ctx, event))
+	return nil
+}
+
+// audit events matching filter, newest first
+func (m *MemoryStore) GetAuditEvents(ctx context.Context, filter AuditFilter) ([]table.AuditEvent, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	events := []table.AuditEvent{}
+	for _, event := range slices.Backward(m.auditEvents) {
+		if (filter.ActorID != uuid.Nil && (event.ActorID == nil || *event.ActorID != filter.ActorID)) ||
+			(len(filter.OrgIDs) > 0 && (event.OrgID == nil || !slices.Contains(filter.OrgIDs, *event.OrgID))) ||
+			(filter.Action != "" && event.Action != filter.Action) ||
+			(!filter.From.IsZero() && event.CreatedAt.Before(filter.From)) ||
+			(!filter.To.IsZero() && !event.CreatedAt.Before(filter.To)) {
+			continue
+		}
+		events = append(events, event)
+		if len(events) >= filter.limit() {
+			break
+		}
+	}
+	return events, nil
+}
+
+func (m *MemoryStore) userByEmail(email string) (table.User, bool) {
+	i := slices.IndexFunc(m.users, func(u table.User) bool { return u.Email == email && u.DeletedAt == nil })
+	if i < 0 {
+		return table.User{}, false
+	}
+	return m.users[i], true
+}
+
+func (m *MemoryStore) userIndex(id uuid.UUID) int {
+	return slices.IndexFunc(m.users, func(u table.User) bool { return u.ID == id && u.DeletedAt == nil })
+}
+
+func (m *MemoryStore) userExists(id uuid.UUID) bool {
+	return m.userIndex(id) >= 0
+}
+
+func (m *MemoryStore) orgExists(id uuid.UUID) bool {
+	return slices.ContainsFunc(m.orgs, func(o table.Organization) bool { return o.ID == id && o.DeletedAt == nil })
+}
+
+func (m *MemoryStore) sessionExists(sessionId h.SecretString) bool {
+	return slices.ContainsFunc(m.refreshTokens, func(t table.RefreshToken) bool { return t.SessionID.GetSecret() == sessionId.GetSecret() })
+}
+
+func (m *MemoryStore) refreshTokenIndex(userID uuid.UUID, sessionId h.SecretString) int {
+	return slices.IndexFunc(m.refreshTokens, func(t table.RefreshToken) bool {
+		return t.UserID == userID && t.SessionID.GetSecret() == sessionId.GetSecret()
+	})
+}
+
+func (m *MemoryStore) taskIndex(taskId string) int {
+	return slices.IndexFunc(m.tasks, func(t table.ScheduledTask) bool { return t.TaskID == taskId && t.DeletedAt == nil })
+}
+
+// unique name and email of users that aren't deleted
+func (m *MemoryStore) checkUser(user table.User) error {
+	if slices.ContainsFunc(m.users, func(u table.User) bool { return (u.Name == user.Name || u.Email == user.Email) && u.DeletedAt == nil }) {
+		return errx.NewWithTypef(ErrDatabaseInsert, "user (email: %s) could not be created", user.Email)
+	}
+	return nil
+}
+
+// tenantDefaults() of new organization, a taken generated slug gets a suffix like DB.CreateOrg()
+func (m *MemoryStore) orgDefaults(org table.Organization) (table.Organization, error) {
+	generatedSlug := org.Slug == ""
+	org = tenantDefaults(org, table.NewID())
+	if generatedSlug && m.slugTaken(org.Slug) {
+		org.Slug = slugWithSuffix(org.Slug, org.ID)
+	}
+	if m.slugTaken(org.Slug) {
+		return org, errx.NewWithTypef(ErrOrgSlugExists, "organization '%s' could not be created with slug '%s'", org.Name, org.Slug)
+	}
+	return org, m.checkOrg(org)
+}
+
+func (m *MemoryStore) slugTaken(slug string) bool {
+	return slices.ContainsFunc(m.orgs, func(o table.Organization) bool { return o.Slug == slug && o.DeletedAt == nil })
+}
+
+// unique name, slug and external id per tenant type of organizations that aren't deleted
+func (m *MemoryStore) checkOrg(org table.Organization) error {
+	if slices.ContainsFunc(m.orgs, func(o table.Organization) bool {
+		return (o.Name == org.Name || o.Slug == org.Slug || (org.ExternalID != "" && o.TenantType == org.TenantType && o.ExternalID == org.ExternalID)) && o.DeletedAt == nil
+	}) {
+		return errx.NewWithTypef(ErrDatabaseInsert, "organization '%s' could not be created", org.Name)
+	}
+	return nil
+}
+
+func (m *MemoryStore) createUser(user table.User) table.User {
+	now := time.Now()
+	user.ID, user.Disabled, user.CreatedAt, user.UpdatedAt = table.NewID(), false, now, now
+	m.users = append(m.users, user)
+	return user
+}
+
+func (m *MemoryStore) updateUser(userID uuid.UUID, update func(user *table.User)) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	i := m.userIndex(userID)
+	if i < 0 {
+		return errx.NewWithTypef(ErrDatabaseNotFound, "no user found for id '%s'", userID)
+	}
+	update(&m.users[i])
+	m.users[i].UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *MemoryStore) revokeSessions(ctx context.Context, userID uuid.UUID) int64 {
+	before := len(m.refreshTokens)
+	m.refreshTokens = slices.DeleteFunc(m.refreshTokens, func(t table.RefreshToken) bool { return t.UserID == userID })
+	revoked := int64(before - len(m.refreshTokens))
+	if revoked > 0 {
+		m.auditEvents = append(m.auditEvents, auditEventDefaults(ctx, sessionsRevokeEvent(userID, revoked)))
+	}
+	return revoked
+}
+
+func (m *MemoryStore) createRole(ctx context.Context, role table.UserRole) {
+	role.ID = table.NewID()
+	m.roles = append(m.roles, role)
+	m.auditEvents = append(m.auditEvents, auditEventDefaults(ctx, roleGrantEvent(role)))
+}
+
+// every entry gets its own copy, entries are returned by value
+func timeRef(t time.Time) *time.Time {
+	return &t
+}
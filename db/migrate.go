@@ -0,0 +1,317 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"embed"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/log"
+)
+
+// default apibase tables, see MIGRATION_SOURCE_APIBASE
+//
+//go:embed migrations
+var defaultMigrations embed.FS
+
+// name of the migration source of the default apibase tables in schema_migrations
+const MIGRATION_SOURCE_APIBASE = "apibase"
+
+// key of the PostgreSQL advisory lock held while migrating, so concurrently starting instances migrate one after another
+const MIGRATION_ADVISORY_LOCK int64 = 0x61706962617365 // "apibase"
+
+// Directory of versioned migrations, one set of files per database kind. Files are named <version>_<name>.up.sql and
+// <version>_<name>.down.sql (e.g. 0001_create_items.up.sql), the down migration is optional but required to revert it.
+// Applied versions are tracked in table schema_migrations by source name
+type MigrationSource struct {
+	Name     string
+	Postgres fs.FS // nil if the source doesn't support PostgreSQL
+	SQLite   fs.FS // nil if the source doesn't support SQLite
+}
+
+type Migration struct {
+	Source  string
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time // nil if pending
+}
+
+type appliedMigration struct {
+	Source    string    `db:"source"`
+	Version   int64     `db:"version"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+var (
+	migrationSourcesMtx sync.Mutex
+	migrationSources    = []MigrationSource{defaultMigrationSource()}
+)
+
+func defaultMigrationSource() MigrationSource {
+	postgres, _ := fs.Sub(defaultMigrations, "migrations/postgres")
+	sqlite, _ := fs.Sub(defaultMigrations, "migrations/sqlite")
+	return MigrationSource{Name: MIGRATION_SOURCE_APIBASE, Postgres: postgres, SQLite: sqlite}
+}
+
+// Register own migrations of the application, which are applied after the default apibase tables in order of registration.
+// Must be called before the database is migrated (e.g. in base.RunOptions.RegisterHooks), use os.DirFS() for a directory on disk or embed.FS
+func RegisterMigrations(sources ...MigrationSource) error {
+	migrationSourcesMtx.Lock()
+	defer migrationSourcesMtx.Unlock()
+	for _, source := range sources {
+		if source.Name == "" {
+			return errx.NewWithType(ErrDatabaseMigration, "migration source name must not be empty")
+		}
+		if slices.ContainsFunc(migrationSources, func(s MigrationSource) bool { return s.Name == source.Name }) {
+			return errx.NewWithTypef(ErrDatabaseMigration, "migration source '%s' is already registered", source.Name)
+		}
+		migrationSources = append(migrationSources, source)
+	}
+	return nil
+}
+
+// Read all migrations of registered sources for the database kind, in order of application
+func Migrations(kind DBKind) ([]Migration, error) {
+	migrationSourcesMtx.Lock()
+	sources := slices.Clone(migrationSources)
+	migrationSourcesMtx.Unlock()
+	all := []Migration{}
+	for _, source := range sources {
+		fsys := source.Postgres
+		if kind == SQLite {
+			fsys = source.SQLite
+		}
+		if fsys == nil {
+			continue
+		}
+		migrations, err := readMigrations(source.Name, fsys)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, migrations...)
+	}
+	return all, nil
+}
+
+func readMigrations(source string, fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errx.WrapWithTypef(ErrDatabaseMigration, err, "unable to read migrations of source '%s'", source)
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, ".sql") {
+			continue
+		}
+		base, direction := strings.TrimSuffix(fileName, ".sql"), ""
+		if strings.HasSuffix(base, ".up") {
+			base, direction = strings.TrimSuffix(base, ".up"), "up"
+		} else if strings.HasSuffix(base, ".down") {
+			base, direction = strings.TrimSuffix(base, ".down"), "down"
+		} else {
+			return nil, errx.NewWithTypef(ErrDatabaseMigration, "migration '%s' of source '%s' must end with .up.sql or .down.sql", fileName, source)
+		}
+		versionString, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(versionString, 10, 64)
+		if err != nil || version < 1 {
+			return nil, errx.NewWithTypef(ErrDatabaseMigration, "migration '%s' of source '%s' must start with a positive version number", fileName, source)
+		}
+		content, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, errx.WrapWithTypef(ErrDatabaseMigration, err, "unable to read migration '%s' of source '%s'", fileName, source)
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Source: source, Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, errx.NewWithTypef(ErrDatabaseMigration, "version %d of source '%s' is used by '%s' and '%s'", version, source, m.Name, name)
+		}
+		if direction == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, errx.NewWithTypef(ErrDatabaseMigration, "migration %d_%s of source '%s' has no up migration", m.Version, m.Name, source)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return migrations, nil
+}
+
+// Apply all pending migrations of the default apibase tables and registered sources (see RegisterMigrations()),
+// every migration is applied in its own transaction
+func MigrateUp(ctx context.Context, database DB) error {
+	ctx, cancel := context.WithTimeout(ctx, database.BaseConfig.TimeoutDatabaseLargeQuery)
+	defer cancel()
+	return withMigrationLock(ctx, database, func() error {
+		status, err := migrationStatus(ctx, database)
+		if err != nil {
+			return err
+		}
+		applied := 0
+		for _, m := range status {
+			if m.AppliedAt != nil {
+				continue
+			}
+			if err := applyMigration(ctx, database, m.Migration, true); err != nil {
+				return err
+			}
+			applied++
+		}
+		log.Logf(log.LevelInfo, "Successfully migrated database, %d migrations applied.", applied)
+		return nil
+	})
+}
+
+// Revert the last steps applied migrations, registered sources are reverted before the default apibase tables
+func MigrateDown(ctx context.Context, database DB, steps int) error {
+	ctx, cancel := context.WithTimeout(ctx, database.BaseConfig.TimeoutDatabaseLargeQuery)
+	defer cancel()
+	return withMigrationLock(ctx, database, func() error {
+		status, err := migrationStatus(ctx, database)
+		if err != nil {
+			return err
+		}
+		reverted := 0
+		for i := len(status) - 1; i >= 0 && reverted < steps; i-- {
+			if status[i].AppliedAt == nil {
+				continue
+			}
+			if err := applyMigration(ctx, database, status[i].Migration, false); err != nil {
+				return err
+			}
+			reverted++
+		}
+		log.Logf(log.LevelInfo, "Successfully reverted %d migrations.", reverted)
+		return nil
+	})
+}
+
+// Get all migrations in order of application and whether they are applied
+func MigrationsStatus(ctx context.Context, database DB) ([]MigrationStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, database.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	if err := createMigrationsTable(ctx, database); err != nil {
+		return nil, err
+	}
+	return migrationStatus(ctx, database)
+}
+
+func migrationStatus(ctx context.Context, database DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations(database.Kind)
+	if err != nil {
+		return nil, err
+	}
+	applied := []appliedMigration{}
+	if err := database.conn().scanAll(ctx, &applied, "SELECT source, version, applied_at FROM schema_migrations"); err != nil {
+		return nil, errx.WrapWithType(ErrDatabaseQuery, err, "unable to read applied migrations")
+	}
+	status := []MigrationStatus{}
+	for _, m := range migrations {
+		s := MigrationStatus{Migration: m}
+		for _, a := range applied {
+			if a.Source == m.Source && a.Version == m.Version {
+				s.AppliedAt = &a.AppliedAt
+				break
+			}
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+func applyMigration(ctx context.Context, database DB, m Migration, up bool) error {
+	query, direction, done := m.up, "apply", "applied"
+	if !up {
+		query, direction, done = m.down, "revert", "reverted"
+		if query == "" {
+			return errx.NewWithTypef(ErrDatabaseMigration, "unable to revert migration %d_%s of source '%s': no down migration", m.Version, m.Name, m.Source)
+		}
+	}
+	tx, err := database.begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+	if _, err := tx.exec(ctx, query); err != nil {
+		return errx.WrapWithTypef(ErrDatabaseMigration, err, "unable to %s migration %d_%s of source '%s'", direction, m.Version, m.Name, m.Source)
+	}
+	if up {
+		_, err = tx.exec(ctx, "INSERT INTO schema_migrations (source, version, name) VALUES ($1, $2, $3)", m.Source, m.Version, m.Name)
+	} else {
+		_, err = tx.exec(ctx, "DELETE FROM schema_migrations WHERE source = $1 AND version = $2", m.Source, m.Version)
+	}
+	if err != nil {
+		return errx.WrapWithTypef(ErrDatabaseMigration, err, "unable to track migration %d_%s of source '%s'", m.Version, m.Name, m.Source)
+	}
+	if err := tx.commit(ctx); err != nil {
+		return errx.WrapWithType(ErrDatabaseCommit, err, "")
+	}
+	log.Logf(log.LevelInfo, "migration %d_%s of source '%s' %s", m.Version, m.Name, m.Source, done)
+	return nil
+}
+
+// run fn while holding the PostgreSQL advisory lock. SQLite transactions take the write lock on begin, so a migration applied
+// concurrently by another process fails on tracking it and is rolled back, the lock file prevents this entirely (see SQLiteConfig.LockFile)
+func withMigrationLock(ctx context.Context, database DB, fn func() error) error {
+	if err := createMigrationsTable(ctx, database); err != nil {
+		return err
+	}
+	if database.Kind != PostgreSQL {
+		return fn()
+	}
+	// advisory locks are held by the session, so lock and unlock must use the same connection of the pool
+	conn, err := database.Postgres.Acquire(ctx)
+	if err != nil {
+		return errx.WrapWithType(ErrDatabaseConn, err, "unable to acquire connection for migration lock")
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", MIGRATION_ADVISORY_LOCK); err != nil {
+		return errx.WrapWithType(ErrDatabaseMigration, err, "unable to acquire migration lock")
+	}
+	defer func() {
+		// unlock with own context, the migration context may be expired already
+		unlockCtx, cancel := context.WithTimeout(context.Background(), database.BaseConfig.TimeoutDatabaseQuery)
+		defer cancel()
+		if _, err := conn.Exec(unlockCtx, "SELECT pg_advisory_unlock($1)", MIGRATION_ADVISORY_LOCK); err != nil {
+			log.Logf(log.LevelError, "unable to release migration lock: %s", err.Error())
+		}
+	}()
+	return fn()
+}
+
+func createMigrationsTable(ctx context.Context, database DB) error {
+	query := `CREATE TABLE IF NOT EXISTS schema_migrations (
+    source VARCHAR(255) NOT NULL,
+    version BIGINT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (source, version)
+)`
+	if database.Kind == SQLite {
+		query = strings.NewReplacer("TIMESTAMPTZ", "TIMESTAMP", "NOW()", "CURRENT_TIMESTAMP").Replace(query)
+	}
+	if _, err := database.conn().exec(ctx, query); err != nil {
+		return errx.WrapWithType(ErrDatabaseMigration, err, "unable to create table schema_migrations")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package db_test
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"gopkg.cc/apibase/baseconfig"
+	"gopkg.cc/apibase/db"
+)
+
+var testMigrations = fstest.MapFS{
+	"0001_items.up.sql":       {Data: []byte("CREATE TABLE items (id INTEGER PRIMARY KEY, user_id TEXT REFERENCES users(id));")},
+	"0001_items.down.sql":     {Data: []byte("DROP TABLE items;")},
+	"0002_item_name.up.sql":   {Data: []byte("ALTER TABLE items ADD COLUMN name TEXT;")},
+	"0002_item_name.down.sql": {Data: []byte("ALTER TABLE items DROP COLUMN name;")},
+	"README.md":               {Data: []byte("ignored")},
+}
+
+// registered once per test binary, the registry is global
+var _ = db.RegisterMigrations(db.MigrationSource{Name: "test", SQLite: testMigrations})
+
+func TestMigrations(t *testing.T) {
+	bc := baseconfig.BaseConfig{}
+	if err := bc.AddMissingFromDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	database, err := db.SQLiteInit(context.Background(), db.SQLiteConfig{FilePath: filepath.Join(t.TempDir(), "test.db")}, &bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close(context.Background())
+
+	applied := func() (versions []string) {
+		status, err := db.MigrationsStatus(context.Background(), database)
+		if err != nil {
+			t.Fatalf("MigrationsStatus() error: %v", err)
+		}
+		for _, m := range status {
+			if m.AppliedAt != nil {
+				versions = append(versions, m.Source+"/"+m.Name)
+			}
+		}
+		return versions
+	}
+	tests := []struct {
+		name    string
+		migrate func() error
+		want    []string
+	}{
+		{"up", func() error { return db.MigrateUp(context.Background(), database) }, []string{"apibase/default_tables", "apibase/uuid_keys", "apibase/soft_delete", "apibase/tenants", "apibase/audit_events", "apibase/list_indexes", "test/items", "test/item_name"}},
+		{"up again", func() error { return db.MigrateUp(context.Background(), database) }, []string{"apibase/default_tables", "apibase/uuid_keys", "apibase/soft_delete", "apibase/tenants", "apibase/audit_events", "apibase/list_indexes", "test/items", "test/item_name"}},
+		{"down", func() error { return db.MigrateDown(context.Background(), database, 2) }, []string{"apibase/default_tables", "apibase/uuid_keys", "apibase/soft_delete", "apibase/tenants", "apibase/audit_events", "apibase/list_indexes"}},
+		{"up after down", func() error { return db.MigrateUp(context.Background(), database) }, []string{"apibase/default_tables", "apibase/uuid_keys", "apibase/soft_delete", "apibase/tenants", "apibase/audit_events", "apibase/list_indexes", "test/items", "test/item_name"}},
+		{"down all", func() error { return db.MigrateDown(context.Background(), database, math.MaxInt) }, nil},
+	}
+	for _, tt := range tests {
+		if err := tt.migrate(); err != nil {
+			t.Fatalf("%s: error: %v", tt.name, err)
+		}
+		if got := applied(); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: applied = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if _, err := database.GetAllUsers(context.Background()); err == nil {
+		t.Error("default tables exist after reverting all migrations")
+	}
+}
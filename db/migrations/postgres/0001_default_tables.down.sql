@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS scheduled_tasks;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS organizations;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,56 @@
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) UNIQUE NOT NULL,
+    auth_provider TEXT NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    password_hash TEXT NOT NULL,
+    secrets_version INTEGER NOT NULL,
+    totp_secret TEXT NOT NULL DEFAULT '',
+    super_admin BOOLEAN DEFAULT FALSE,
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) UNIQUE NOT NULL,
+    description TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) NOT NULL,
+    session_id TEXT UNIQUE NOT NULL,
+    reissue_count INTEGER NOT NULL,
+    user_agent TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) NOT NULL,
+    org_id INTEGER REFERENCES organizations(id) NOT NULL,
+    org_view BOOLEAN DEFAULT FALSE,
+    org_edit BOOLEAN DEFAULT FALSE,
+    org_admin BOOLEAN DEFAULT FALSE,
+    UNIQUE (user_id, org_id)
+);
+
+CREATE TABLE IF NOT EXISTS scheduled_tasks (
+    id SERIAL PRIMARY KEY,
+    task_id VARCHAR(255) UNIQUE NOT NULL,
+    org_id INTEGER REFERENCES organizations(id) NOT NULL,
+    start_date TIMESTAMPTZ NOT NULL,
+    interval BIGINT NOT NULL,
+    task_type VARCHAR(255) NOT NULL,
+    task_data JSONB DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- tables created from the former table/*.sql files are kept by IF NOT EXISTS, columns added since then are added here
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,61 @@
+-- Revert UUID keys to integers numbered in order of the UUIDs, which is the order of creation
+
+ALTER TABLE users ADD COLUMN new_id SERIAL;
+UPDATE users u SET new_id = n.rn FROM (SELECT id, row_number() OVER (ORDER BY id) AS rn FROM users) n WHERE n.id = u.id;
+ALTER TABLE organizations ADD COLUMN new_id SERIAL;
+UPDATE organizations o SET new_id = n.rn FROM (SELECT id, row_number() OVER (ORDER BY id) AS rn FROM organizations) n WHERE n.id = o.id;
+ALTER TABLE refresh_tokens ADD COLUMN new_id SERIAL;
+UPDATE refresh_tokens t SET new_id = n.rn FROM (SELECT id, row_number() OVER (ORDER BY id) AS rn FROM refresh_tokens) n WHERE n.id = t.id;
+ALTER TABLE user_roles ADD COLUMN new_id SERIAL;
+UPDATE user_roles r SET new_id = n.rn FROM (SELECT id, row_number() OVER (ORDER BY id) AS rn FROM user_roles) n WHERE n.id = r.id;
+ALTER TABLE scheduled_tasks ADD COLUMN new_id SERIAL;
+UPDATE scheduled_tasks s SET new_id = n.rn FROM (SELECT id, row_number() OVER (ORDER BY id) AS rn FROM scheduled_tasks) n WHERE n.id = s.id;
+
+ALTER TABLE refresh_tokens ADD COLUMN new_user_id INTEGER;
+UPDATE refresh_tokens t SET new_user_id = u.new_id FROM users u WHERE u.id = t.user_id;
+ALTER TABLE user_roles ADD COLUMN new_user_id INTEGER, ADD COLUMN new_org_id INTEGER;
+UPDATE user_roles r SET new_user_id = u.new_id FROM users u WHERE u.id = r.user_id;
+UPDATE user_roles r SET new_org_id = o.new_id FROM organizations o WHERE o.id = r.org_id;
+ALTER TABLE scheduled_tasks ADD COLUMN new_org_id INTEGER;
+UPDATE scheduled_tasks s SET new_org_id = o.new_id FROM organizations o WHERE o.id = s.org_id;
+
+ALTER TABLE refresh_tokens DROP COLUMN user_id;
+ALTER TABLE user_roles DROP COLUMN user_id, DROP COLUMN org_id;
+ALTER TABLE scheduled_tasks DROP COLUMN org_id;
+ALTER TABLE users DROP COLUMN id;
+ALTER TABLE organizations DROP COLUMN id;
+ALTER TABLE refresh_tokens DROP COLUMN id;
+ALTER TABLE user_roles DROP COLUMN id;
+ALTER TABLE scheduled_tasks DROP COLUMN id;
+
+-- continue the sequences after the renumbered rows
+SELECT setval(pg_get_serial_sequence('users', 'new_id'), coalesce(max(new_id), 0) + 1, false) FROM users;
+SELECT setval(pg_get_serial_sequence('organizations', 'new_id'), coalesce(max(new_id), 0) + 1, false) FROM organizations;
+SELECT setval(pg_get_serial_sequence('refresh_tokens', 'new_id'), coalesce(max(new_id), 0) + 1, false) FROM refresh_tokens;
+SELECT setval(pg_get_serial_sequence('user_roles', 'new_id'), coalesce(max(new_id), 0) + 1, false) FROM user_roles;
+SELECT setval(pg_get_serial_sequence('scheduled_tasks', 'new_id'), coalesce(max(new_id), 0) + 1, false) FROM scheduled_tasks;
+
+ALTER TABLE users RENAME COLUMN new_id TO id;
+ALTER TABLE users ADD PRIMARY KEY (id);
+ALTER TABLE organizations RENAME COLUMN new_id TO id;
+ALTER TABLE organizations ADD PRIMARY KEY (id);
+
+ALTER TABLE refresh_tokens RENAME COLUMN new_id TO id;
+ALTER TABLE refresh_tokens RENAME COLUMN new_user_id TO user_id;
+ALTER TABLE refresh_tokens ADD PRIMARY KEY (id),
+    ALTER COLUMN user_id SET NOT NULL, ADD FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE user_roles RENAME COLUMN new_id TO id;
+ALTER TABLE user_roles RENAME COLUMN new_user_id TO user_id;
+ALTER TABLE user_roles RENAME COLUMN new_org_id TO org_id;
+ALTER TABLE user_roles ADD PRIMARY KEY (id),
+    ALTER COLUMN user_id SET NOT NULL, ADD FOREIGN KEY (user_id) REFERENCES users(id),
+    ALTER COLUMN org_id SET NOT NULL, ADD FOREIGN KEY (org_id) REFERENCES organizations(id),
+    ADD UNIQUE (user_id, org_id);
+
+ALTER TABLE scheduled_tasks RENAME COLUMN new_id TO id;
+ALTER TABLE scheduled_tasks RENAME COLUMN new_org_id TO org_id;
+ALTER TABLE scheduled_tasks ADD PRIMARY KEY (id),
+    ALTER COLUMN org_id SET NOT NULL, ADD FOREIGN KEY (org_id) REFERENCES organizations(id);
+
+DROP FUNCTION IF EXISTS apibase_uuid_v7(TIMESTAMPTZ);
@@ -0,0 +1,65 @@
+-- Replace integer keys by time ordered UUIDs (version 7). Existing rows get a UUID of their creation time,
+-- tables of the application referencing these tables must be migrated by the application, otherwise
+-- dropping the integer keys fails.
+
+CREATE OR REPLACE FUNCTION apibase_uuid_v7(ts TIMESTAMPTZ DEFAULT clock_timestamp()) RETURNS UUID AS $$
+    SELECT encode(
+        set_bit(set_bit(
+            overlay(uuid_send(gen_random_uuid())
+                PLACING substring(int8send(floor(extract(epoch FROM ts) * 1000)::BIGINT) FROM 3)
+                FROM 1 FOR 6),
+            52, 1), 53, 1),
+        'hex')::UUID
+$$ LANGUAGE SQL VOLATILE;
+
+ALTER TABLE users ADD COLUMN new_id UUID;
+UPDATE users SET new_id = apibase_uuid_v7(created_at);
+ALTER TABLE organizations ADD COLUMN new_id UUID;
+UPDATE organizations SET new_id = apibase_uuid_v7();
+ALTER TABLE refresh_tokens ADD COLUMN new_id UUID;
+UPDATE refresh_tokens SET new_id = apibase_uuid_v7(created_at);
+ALTER TABLE user_roles ADD COLUMN new_id UUID;
+UPDATE user_roles SET new_id = apibase_uuid_v7();
+ALTER TABLE scheduled_tasks ADD COLUMN new_id UUID;
+UPDATE scheduled_tasks SET new_id = apibase_uuid_v7(created_at);
+
+ALTER TABLE refresh_tokens ADD COLUMN new_user_id UUID;
+UPDATE refresh_tokens t SET new_user_id = u.new_id FROM users u WHERE u.id = t.user_id;
+ALTER TABLE user_roles ADD COLUMN new_user_id UUID, ADD COLUMN new_org_id UUID;
+UPDATE user_roles r SET new_user_id = u.new_id FROM users u WHERE u.id = r.user_id;
+UPDATE user_roles r SET new_org_id = o.new_id FROM organizations o WHERE o.id = r.org_id;
+ALTER TABLE scheduled_tasks ADD COLUMN new_org_id UUID;
+UPDATE scheduled_tasks s SET new_org_id = o.new_id FROM organizations o WHERE o.id = s.org_id;
+
+-- dropping the columns also drops their constraints and sequences
+ALTER TABLE refresh_tokens DROP COLUMN user_id;
+ALTER TABLE user_roles DROP COLUMN user_id, DROP COLUMN org_id;
+ALTER TABLE scheduled_tasks DROP COLUMN org_id;
+ALTER TABLE users DROP COLUMN id;
+ALTER TABLE organizations DROP COLUMN id;
+ALTER TABLE refresh_tokens DROP COLUMN id;
+ALTER TABLE user_roles DROP COLUMN id;
+ALTER TABLE scheduled_tasks DROP COLUMN id;
+
+ALTER TABLE users RENAME COLUMN new_id TO id;
+ALTER TABLE users ALTER COLUMN id SET NOT NULL, ALTER COLUMN id SET DEFAULT apibase_uuid_v7(), ADD PRIMARY KEY (id);
+ALTER TABLE organizations RENAME COLUMN new_id TO id;
+ALTER TABLE organizations ALTER COLUMN id SET NOT NULL, ALTER COLUMN id SET DEFAULT apibase_uuid_v7(), ADD PRIMARY KEY (id);
+
+ALTER TABLE refresh_tokens RENAME COLUMN new_id TO id;
+ALTER TABLE refresh_tokens RENAME COLUMN new_user_id TO user_id;
+ALTER TABLE refresh_tokens ALTER COLUMN id SET NOT NULL, ALTER COLUMN id SET DEFAULT apibase_uuid_v7(), ADD PRIMARY KEY (id),
+    ALTER COLUMN user_id SET NOT NULL, ADD FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE user_roles RENAME COLUMN new_id TO id;
+ALTER TABLE user_roles RENAME COLUMN new_user_id TO user_id;
+ALTER TABLE user_roles RENAME COLUMN new_org_id TO org_id;
+ALTER TABLE user_roles ALTER COLUMN id SET NOT NULL, ALTER COLUMN id SET DEFAULT apibase_uuid_v7(), ADD PRIMARY KEY (id),
+    ALTER COLUMN user_id SET NOT NULL, ADD FOREIGN KEY (user_id) REFERENCES users(id),
+    ALTER COLUMN org_id SET NOT NULL, ADD FOREIGN KEY (org_id) REFERENCES organizations(id),
+    ADD UNIQUE (user_id, org_id);
+
+ALTER TABLE scheduled_tasks RENAME COLUMN new_id TO id;
+ALTER TABLE scheduled_tasks RENAME COLUMN new_org_id TO org_id;
+ALTER TABLE scheduled_tasks ALTER COLUMN id SET NOT NULL, ALTER COLUMN id SET DEFAULT apibase_uuid_v7(), ADD PRIMARY KEY (id),
+    ALTER COLUMN org_id SET NOT NULL, ADD FOREIGN KEY (org_id) REFERENCES organizations(id);
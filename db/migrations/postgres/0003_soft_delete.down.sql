@@ -0,0 +1,20 @@
+-- Soft deleted rows are deleted, otherwise the unique constraints can't be restored
+
+DELETE FROM scheduled_tasks WHERE deleted_at IS NOT NULL OR org_id IN (SELECT id FROM organizations WHERE deleted_at IS NOT NULL);
+DELETE FROM user_roles WHERE deleted_at IS NOT NULL
+    OR user_id IN (SELECT id FROM users WHERE deleted_at IS NOT NULL)
+    OR org_id IN (SELECT id FROM organizations WHERE deleted_at IS NOT NULL);
+DELETE FROM refresh_tokens WHERE user_id IN (SELECT id FROM users WHERE deleted_at IS NOT NULL);
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+DELETE FROM organizations WHERE deleted_at IS NOT NULL;
+
+DROP INDEX users_name_key, users_email_key, organizations_name_key, user_roles_user_id_org_id_key, scheduled_tasks_task_id_key;
+ALTER TABLE users ADD UNIQUE (name), ADD UNIQUE (email);
+ALTER TABLE organizations ADD UNIQUE (name);
+ALTER TABLE user_roles ADD UNIQUE (user_id, org_id);
+ALTER TABLE scheduled_tasks ADD UNIQUE (task_id);
+
+ALTER TABLE users DROP COLUMN deleted_at;
+ALTER TABLE organizations DROP COLUMN deleted_at;
+ALTER TABLE user_roles DROP COLUMN deleted_at;
+ALTER TABLE scheduled_tasks DROP COLUMN deleted_at;
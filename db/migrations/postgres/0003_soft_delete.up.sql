@@ -0,0 +1,16 @@
+-- Soft delete: deleted rows keep their data until purged, unique constraints only apply to rows that aren't deleted
+
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE organizations ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE user_roles ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE scheduled_tasks ADD COLUMN deleted_at TIMESTAMPTZ;
+
+ALTER TABLE users DROP CONSTRAINT users_name_key, DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX users_name_key ON users (name) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX users_email_key ON users (email) WHERE deleted_at IS NULL;
+ALTER TABLE organizations DROP CONSTRAINT organizations_name_key;
+CREATE UNIQUE INDEX organizations_name_key ON organizations (name) WHERE deleted_at IS NULL;
+ALTER TABLE user_roles DROP CONSTRAINT user_roles_user_id_org_id_key;
+CREATE UNIQUE INDEX user_roles_user_id_org_id_key ON user_roles (user_id, org_id) WHERE deleted_at IS NULL;
+ALTER TABLE scheduled_tasks DROP CONSTRAINT scheduled_tasks_task_id_key;
+CREATE UNIQUE INDEX scheduled_tasks_task_id_key ON scheduled_tasks (task_id) WHERE deleted_at IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX organizations_slug_key, organizations_external_id_key;
+ALTER TABLE organizations DROP COLUMN tenant_type, DROP COLUMN slug, DROP COLUMN external_id, DROP COLUMN metadata;
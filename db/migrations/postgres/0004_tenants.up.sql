@@ -0,0 +1,17 @@
+-- Organizations are typed tenants with a unique slug and an optional external id, which is unique per tenant type.
+-- Existing organizations created on signup are of type 'user', their slug is the lower case name if it is url safe and unique, otherwise the id
+
+ALTER TABLE organizations
+    ADD COLUMN tenant_type VARCHAR(255) NOT NULL DEFAULT 'org',
+    ADD COLUMN slug VARCHAR(255),
+    ADD COLUMN external_id VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN metadata JSONB NOT NULL DEFAULT '{}';
+
+UPDATE organizations SET tenant_type = 'user' WHERE name LIKE 'userorg-%';
+UPDATE organizations o SET slug = CASE
+    WHEN lower(o.name) ~ '[^a-z0-9-]' OR (SELECT count(*) FROM organizations d WHERE lower(d.name) = lower(o.name)) > 1 THEN o.id::TEXT
+    ELSE lower(o.name) END;
+ALTER TABLE organizations ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX organizations_slug_key ON organizations (slug) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX organizations_external_id_key ON organizations (tenant_type, external_id) WHERE external_id <> '' AND deleted_at IS NULL;
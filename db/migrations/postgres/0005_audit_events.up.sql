@@ -0,0 +1,17 @@
+-- Audit log of security relevant actions, events don't reference users or organizations, so they are kept once these are purged
+
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY NOT NULL DEFAULT apibase_uuid_v7(),
+    actor_id UUID,
+    org_id UUID,
+    action VARCHAR(255) NOT NULL,
+    target VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(255) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    diff JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_events_created_at_idx ON audit_events (created_at);
+CREATE INDEX audit_events_actor_id_idx ON audit_events (actor_id, created_at);
+CREATE INDEX audit_events_org_id_idx ON audit_events (org_id, created_at);
@@ -0,0 +1,2 @@
+DROP INDEX scheduled_tasks_org_id_created_at_idx;
+DROP INDEX users_created_at_idx;
@@ -0,0 +1,91 @@
+-- Replace integer keys by time ordered UUIDs (version 7) stored as text. SQLite can't alter columns,
+-- so every table is rebuilt. Existing rows get a UUID of their creation time, tables of the application
+-- referencing these tables must be migrated by the application.
+
+ALTER TABLE users ADD COLUMN new_id TEXT;
+UPDATE users SET new_id = lower(substr(printf('%012x', CAST((julianday(coalesce(created_at, 'now')) - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday(coalesce(created_at, 'now')) - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)));
+ALTER TABLE organizations ADD COLUMN new_id TEXT;
+UPDATE organizations SET new_id = lower(substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)));
+ALTER TABLE refresh_tokens ADD COLUMN new_id TEXT;
+UPDATE refresh_tokens SET new_id = lower(substr(printf('%012x', CAST((julianday(coalesce(created_at, 'now')) - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday(coalesce(created_at, 'now')) - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)));
+ALTER TABLE user_roles ADD COLUMN new_id TEXT;
+UPDATE user_roles SET new_id = lower(substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)));
+ALTER TABLE scheduled_tasks ADD COLUMN new_id TEXT;
+UPDATE scheduled_tasks SET new_id = lower(substr(printf('%012x', CAST((julianday(coalesce(created_at, 'now')) - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday(coalesce(created_at, 'now')) - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)));
+
+CREATE TABLE users_new (
+    id TEXT PRIMARY KEY NOT NULL DEFAULT (lower(substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)))),
+    name VARCHAR(255) UNIQUE NOT NULL,
+    auth_provider TEXT NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    password_hash TEXT NOT NULL,
+    secrets_version INTEGER NOT NULL,
+    totp_secret TEXT NOT NULL DEFAULT '',
+    super_admin BOOLEAN DEFAULT FALSE,
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE organizations_new (
+    id TEXT PRIMARY KEY NOT NULL DEFAULT (lower(substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)))),
+    name VARCHAR(255) UNIQUE NOT NULL,
+    description TEXT NOT NULL
+);
+
+CREATE TABLE refresh_tokens_new (
+    id TEXT PRIMARY KEY NOT NULL DEFAULT (lower(substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)))),
+    user_id TEXT NOT NULL REFERENCES users_new(id),
+    session_id TEXT UNIQUE NOT NULL,
+    reissue_count INTEGER NOT NULL,
+    user_agent TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE user_roles_new (
+    id TEXT PRIMARY KEY NOT NULL DEFAULT (lower(substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)))),
+    user_id TEXT NOT NULL REFERENCES users_new(id),
+    org_id TEXT NOT NULL REFERENCES organizations_new(id),
+    org_view BOOLEAN DEFAULT FALSE,
+    org_edit BOOLEAN DEFAULT FALSE,
+    org_admin BOOLEAN DEFAULT FALSE,
+    UNIQUE (user_id, org_id)
+);
+
+CREATE TABLE scheduled_tasks_new (
+    id TEXT PRIMARY KEY NOT NULL DEFAULT (lower(substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)))),
+    task_id VARCHAR(255) UNIQUE NOT NULL,
+    org_id TEXT NOT NULL REFERENCES organizations_new(id),
+    start_date TIMESTAMP NOT NULL,
+    interval BIGINT NOT NULL,
+    task_type VARCHAR(255) NOT NULL,
+    task_data TEXT DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO users_new (id, name, auth_provider, email, email_verified, password_hash, secrets_version, totp_secret, super_admin, disabled, created_at, updated_at)
+    SELECT new_id, name, auth_provider, email, email_verified, password_hash, secrets_version, totp_secret, super_admin, disabled, created_at, updated_at FROM users;
+INSERT INTO organizations_new (id, name, description)
+    SELECT new_id, name, description FROM organizations;
+INSERT INTO refresh_tokens_new (id, user_id, session_id, reissue_count, user_agent, expires_at, created_at, updated_at)
+    SELECT t.new_id, u.new_id, t.session_id, t.reissue_count, t.user_agent, t.expires_at, t.created_at, t.updated_at FROM refresh_tokens t JOIN users u ON u.id = t.user_id;
+INSERT INTO user_roles_new (id, user_id, org_id, org_view, org_edit, org_admin)
+    SELECT r.new_id, u.new_id, o.new_id, r.org_view, r.org_edit, r.org_admin FROM user_roles r JOIN users u ON u.id = r.user_id JOIN organizations o ON o.id = r.org_id;
+INSERT INTO scheduled_tasks_new (id, task_id, org_id, start_date, interval, task_type, task_data, created_at, updated_at)
+    SELECT s.new_id, s.task_id, o.new_id, s.start_date, s.interval, s.task_type, s.task_data, s.created_at, s.updated_at FROM scheduled_tasks s JOIN organizations o ON o.id = s.org_id;
+
+-- referencing tables first, renaming the new tables also renames their references
+DROP TABLE scheduled_tasks;
+DROP TABLE user_roles;
+DROP TABLE refresh_tokens;
+DROP TABLE organizations;
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+ALTER TABLE organizations_new RENAME TO organizations;
+ALTER TABLE refresh_tokens_new RENAME TO refresh_tokens;
+ALTER TABLE user_roles_new RENAME TO user_roles;
+ALTER TABLE scheduled_tasks_new RENAME TO scheduled_tasks;
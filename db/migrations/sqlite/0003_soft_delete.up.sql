@@ -0,0 +1,83 @@
+-- Soft delete: deleted rows keep their data until purged, unique constraints only apply to rows that aren't deleted.
+-- SQLite can't drop unique constraints, so every table is rebuilt
+
+CREATE TABLE users_new (
+    id TEXT PRIMARY KEY NOT NULL DEFAULT (lower(substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)))),
+    name VARCHAR(255) NOT NULL,
+    auth_provider TEXT NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    password_hash TEXT NOT NULL,
+    secrets_version INTEGER NOT NULL,
+    totp_secret TEXT NOT NULL DEFAULT '',
+    super_admin BOOLEAN DEFAULT FALSE,
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE TABLE organizations_new (
+    id TEXT PRIMARY KEY NOT NULL DEFAULT (lower(substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)))),
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    deleted_at TIMESTAMP
+);
+
+CREATE TABLE refresh_tokens_new (
+    id TEXT PRIMARY KEY NOT NULL DEFAULT (lower(substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)))),
+    user_id TEXT NOT NULL REFERENCES users_new(id),
+    session_id TEXT UNIQUE NOT NULL,
+    reissue_count INTEGER NOT NULL,
+    user_agent TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE user_roles_new (
+    id TEXT PRIMARY KEY NOT NULL DEFAULT (lower(substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)))),
+    user_id TEXT NOT NULL REFERENCES users_new(id),
+    org_id TEXT NOT NULL REFERENCES organizations_new(id),
+    org_view BOOLEAN DEFAULT FALSE,
+    org_edit BOOLEAN DEFAULT FALSE,
+    org_admin BOOLEAN DEFAULT FALSE,
+    deleted_at TIMESTAMP
+);
+
+CREATE TABLE scheduled_tasks_new (
+    id TEXT PRIMARY KEY NOT NULL DEFAULT (lower(substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 1, 8) || '-' || substr(printf('%012x', CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)), 9, 4) || '-7' || substr(hex(randomblob(2)), 2, 3) || '-' || substr('89ab', 1 + abs(random() % 4), 1) || substr(hex(randomblob(2)), 2, 3) || '-' || hex(randomblob(6)))),
+    task_id VARCHAR(255) NOT NULL,
+    org_id TEXT NOT NULL REFERENCES organizations_new(id),
+    start_date TIMESTAMP NOT NULL,
+    interval BIGINT NOT NULL,
+    task_type VARCHAR(255) NOT NULL,
+    task_data TEXT DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+INSERT INTO users_new (id, name, auth_provider, email, email_verified, password_hash, secrets_version, totp_secret, super_admin, disabled, created_at, updated_at, deleted_at) SELECT id, name, auth_provider, email, email_verified, password_hash, secrets_version, totp_secret, super_admin, disabled, created_at, updated_at, NULL FROM users;
+INSERT INTO organizations_new (id, name, description, deleted_at) SELECT id, name, description, NULL FROM organizations;
+INSERT INTO refresh_tokens_new (id, user_id, session_id, reissue_count, user_agent, expires_at, created_at, updated_at) SELECT id, user_id, session_id, reissue_count, user_agent, expires_at, created_at, updated_at FROM refresh_tokens;
+INSERT INTO user_roles_new (id, user_id, org_id, org_view, org_edit, org_admin, deleted_at) SELECT id, user_id, org_id, org_view, org_edit, org_admin, NULL FROM user_roles;
+INSERT INTO scheduled_tasks_new (id, task_id, org_id, start_date, interval, task_type, task_data, created_at, updated_at, deleted_at) SELECT id, task_id, org_id, start_date, interval, task_type, task_data, created_at, updated_at, NULL FROM scheduled_tasks;
+
+-- referencing tables first, renaming the new tables also renames their references
+DROP TABLE scheduled_tasks;
+DROP TABLE user_roles;
+DROP TABLE refresh_tokens;
+DROP TABLE organizations;
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+ALTER TABLE organizations_new RENAME TO organizations;
+ALTER TABLE refresh_tokens_new RENAME TO refresh_tokens;
+ALTER TABLE user_roles_new RENAME TO user_roles;
+ALTER TABLE scheduled_tasks_new RENAME TO scheduled_tasks;
+
+CREATE UNIQUE INDEX users_name_key ON users (name) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX users_email_key ON users (email) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX organizations_name_key ON organizations (name) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX user_roles_user_id_org_id_key ON user_roles (user_id, org_id) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX scheduled_tasks_task_id_key ON scheduled_tasks (task_id) WHERE deleted_at IS NULL;
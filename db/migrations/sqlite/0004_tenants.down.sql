@@ -0,0 +1,6 @@
+DROP INDEX organizations_slug_key;
+DROP INDEX organizations_external_id_key;
+ALTER TABLE organizations DROP COLUMN tenant_type;
+ALTER TABLE organizations DROP COLUMN slug;
+ALTER TABLE organizations DROP COLUMN external_id;
+ALTER TABLE organizations DROP COLUMN metadata;
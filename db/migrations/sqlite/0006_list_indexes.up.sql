@@ -0,0 +1,3 @@
+-- keyset pagination of the List* methods, see db.ListQuery
+CREATE INDEX users_created_at_idx ON users (created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX scheduled_tasks_org_id_created_at_idx ON scheduled_tasks (org_id, created_at, id) WHERE deleted_at IS NULL;
@@ -2,65 +2,240 @@ package db
 
 import (
 	"context"
-	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	pgxuuid "github.com/jackc/pgx-gofrs-uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/lib/pq"
 
 	"gopkg.cc/apibase/baseconfig"
 	"gopkg.cc/apibase/errx"
+	h "gopkg.cc/apibase/helper"
 	"gopkg.cc/apibase/log"
 )
 
-// Initialize database connection, require shutdown and next channels for clean shutdown, these can be created using base.ApiBase[T].GetCloseStageChannels()
-func PostgresInit(pgc PostgresConfig, bc *baseconfig.BaseConfig, shutdown chan struct{}, next chan struct{}) (DB, error) {
-	db := DB{Kind: PostgreSQL, BaseConfig: bc}
-	abort := make(chan struct{})
+// Initialize database connection pool, requires DB.Close() for clean shutdown, which is done automatically if base.ApiBase[T].PostgresInit() is used.
+// Connecting is aborted once ctx is done
+func PostgresInit(ctx context.Context, pgc PostgresConfig, bc *baseconfig.BaseConfig) (DB, error) {
+	db := DB{Kind: PostgreSQL, BaseConfig: bc, tracer: newQueryTracer(bc)}
+	if pgc.Pool == nil {
+		pgc.Pool = &PostgresPoolConfig{}
+	}
+	if err := pgc.Pool.AddMissingFromDefaults(); err != nil {
+		return db, errx.WrapWithType(ErrDatabaseConfig, err, "")
+	}
+	config, err := pgc.poolConfig(db.tracer)
+	if err != nil {
+		return db, err
+	}
+	replicaConfigs, err := pgc.ReplicaConfigs()
+	if err != nil {
+		return db, err
+	}
+	pgc.Password.OnChange(func(string) {
+		log.Log(log.LevelNotice, "postgres password changed, the new password is used for new connections")
+	})
 
-	go func() { // pgx shutdown
-		select {
-		case <-shutdown:
-		case <-abort:
-			return
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), bc.TimeoutDatabaseShutdown)
-		defer cancel()
-		if db.Postgres != nil {
-			err := db.Postgres.Close(ctx)
+	for attempt := 1; attempt <= int(bc.DatabaseMaxReconnectAttempts); attempt++ {
+		db.Postgres, err = pgxpool.NewWithConfig(ctx, config)
+		if err == nil {
+			connectCtx, cancel := context.WithTimeout(ctx, bc.TimeoutDatabaseConnect)
+			err = db.Postgres.Ping(connectCtx)
+			cancel()
 			if err != nil {
-				log.Logf(log.LevelError, "unable to close postgres database connection: %s", err.Error())
-			} else {
-				log.Log(log.LevelNotice, "postgres database connection closed successful.")
+				db.Postgres.Close()
+				db.Postgres = nil
 			}
 		}
-		select {
-		case <-next:
-			log.Log(log.LevelError, "postgres next channel already closed, make sure channel staging is done correctly")
-		default:
-			close(next)
-		}
-	}()
-
-	connString := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", pgc.User, pgc.Password.GetSecret(), pgc.Host, pgc.Port, pgc.DB) // ?ssl=%s , ssl)
-	var err error
-	for attempt := 1; attempt <= int(bc.DatabaseMaxReconnectAttempts); attempt++ {
-		ctx, cancel := context.WithTimeout(context.Background(), bc.TimeoutDatabaseConnect)
-		db.Postgres, err = pgx.Connect(ctx, connString)
 		if err != nil {
 			log.Logf(log.LevelInfo, "Connecting to database failed, attempt %d/%d", attempt, bc.DatabaseMaxReconnectAttempts)
-			time.Sleep(SLEEP_DATABASE_RECONNECT)
-			cancel()
+			select {
+			case <-time.After(SLEEP_DATABASE_RECONNECT):
+			case <-ctx.Done():
+				return db, errx.WrapWithType(ErrDatabaseConn, ctx.Err(), "connecting aborted")
+			}
 			continue
 		}
 
-		pgxuuid.Register(db.Postgres.TypeMap())
-
-		log.Logf(log.LevelInfo, "Postgres connection to database '%s' established.", pgc.DB)
-		cancel()
+		log.Logf(log.LevelInfo, "Postgres connection pool to database '%s' established (max connections: %d).", pgc.DB, config.MaxConns)
+		if len(replicaConfigs) > 0 {
+			if db.replicas, err = newReplicaSet(ctx, replicaConfigs, pgc.ReplicaReadsAfterWrite, bc, db.tracer); err != nil {
+				db.Postgres.Close()
+				db.Postgres = nil
+				return db, err
+			}
+		}
 		return db, nil
 	}
-	close(abort)
 	return db, errx.WrapWithType(ErrDatabaseConn, err, "")
 }
+
+// pool config of pgc, pgc.Pool must be set
+func (pgc PostgresConfig) poolConfig(tracer *queryTracer) (*pgxpool.Config, error) {
+	connString, err := pgc.ConnString()
+	if err != nil {
+		return nil, err
+	}
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, errx.WrapWithType(ErrDatabaseConfig, err, "invalid postgres config")
+	}
+	config.MaxConns = pgc.Pool.MaxConns
+	config.MinConns = pgc.Pool.MinConns
+	config.MaxConnLifetime = pgc.Pool.MaxConnLifetime
+	config.MaxConnIdleTime = pgc.Pool.MaxConnIdleTime
+	if pgc.Pool.HealthCheckPeriod > 0 {
+		// otherwise the default of pgxpool is kept, e.g. for a config without PostgresPoolConfig.AddMissingFromDefaults()
+		config.HealthCheckPeriod = pgc.Pool.HealthCheckPeriod
+	}
+	config.ConnConfig.Tracer = tracer
+	config.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		// password is read for every new connection, since it may be rotated
+		cc.Password = pgc.Password.GetSecret()
+		return nil
+	}
+	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		pgxuuid.Register(conn.TypeMap())
+		return nil
+	}
+	return config, nil
+}
+
+var postgresSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Connection url without password (it is set for every new connection), e.g. to check the config without connecting.
+// Options of PostgresConfig take precedence over the same keys in Params
+func (pgc PostgresConfig) ConnString() (string, error) {
+	params := url.Values{}
+	for _, param := range pgc.Params {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return "", errx.NewWithTypef(ErrDatabaseConfig, "postgres param '%s' must be key=value", param)
+		}
+		params.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+
+	sslMode := pgc.SSLMode
+	if sslMode == "" && pgc.SSLEnabled {
+		sslMode = "require"
+	}
+	if sslMode != "" && !slices.Contains(postgresSSLModes, sslMode) {
+		return "", errx.NewWithTypef(ErrDatabaseConfig, "postgres ssl_mode '%s' must be one of %s", sslMode, strings.Join(postgresSSLModes, ", "))
+	}
+	if (pgc.SSLCert == "") != (pgc.SSLKey == "") {
+		return "", errx.NewWithType(ErrDatabaseConfig, "postgres ssl_cert and ssl_key must be set together")
+	}
+	options := map[string]string{
+		"sslmode":          sslMode,
+		"sslrootcert":      pgc.SSLRootCert,
+		"sslcert":          pgc.SSLCert,
+		"sslkey":           pgc.SSLKey,
+		"application_name": pgc.ApplicationName,
+		"search_path":      pgc.SearchPath,
+	}
+	if pgc.StatementTimeout != "" {
+		timeout, err := h.StringToDuration(pgc.StatementTimeout)
+		if err != nil {
+			return "", errx.WrapWithType(ErrDatabaseConfig, err, "postgres statement_timeout")
+		}
+		options["statement_timeout"] = strconv.FormatInt(timeout.Milliseconds(), 10)
+	}
+	for key, value := range options {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+
+	connURL := url.URL{Scheme: "postgres", User: url.User(pgc.User), Path: "/" + pgc.DB}
+	switch {
+	case strings.HasPrefix(pgc.Host, "/"): // unix socket directory
+		params.Set("host", pgc.Host)
+		if pgc.Port != "" {
+			params.Set("port", pgc.Port)
+		}
+	case pgc.Port != "":
+		connURL.Host = net.JoinHostPort(pgc.Host, pgc.Port)
+	default:
+		connURL.Host = pgc.Host
+	}
+	connURL.RawQuery = params.Encode()
+	return connURL.String(), nil
+}
+
+// Connection pool statistics, only available for PostgreSQL
+type PoolStats struct {
+	MaxConns             int32         `json:"max_conns"`
+	TotalConns           int32         `json:"total_conns"`
+	AcquiredConns        int32         `json:"acquired_conns"`
+	IdleConns            int32         `json:"idle_conns"`
+	AcquireCount         int64         `json:"acquire_count"`
+	AcquireDuration      time.Duration `json:"acquire_duration"`
+	EmptyAcquireCount    int64         `json:"empty_acquire_count"` // acquires that waited for a connection
+	CanceledAcquireCount int64         `json:"canceled_acquire_count"`
+}
+
+// Get current connection pool statistics, false if database isn't a PostgreSQL connection pool
+func (db DB) PoolStats() (PoolStats, bool) {
+	if db.Kind != PostgreSQL || db.Postgres == nil {
+		return PoolStats{}, false
+	}
+	return poolStats(db.Postgres), true
+}
+
+func poolStats(pool *pgxpool.Pool) PoolStats {
+	stat := pool.Stat()
+	return PoolStats{
+		MaxConns:             stat.MaxConns(),
+		TotalConns:           stat.TotalConns(),
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		AcquireCount:         stat.AcquireCount(),
+		AcquireDuration:      stat.AcquireDuration(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+	}
+}
+
+// Close database connection
+func (db DB) Close(ctx context.Context) error {
+	switch db.Kind {
+	case PostgreSQL:
+		if db.Postgres == nil {
+			return nil
+		}
+		// Close() waits for acquired connections to be released
+		closed := make(chan struct{})
+		go func() {
+			if db.replicas != nil {
+				db.replicas.close()
+			}
+			db.Postgres.Close()
+			close(closed)
+		}()
+		select {
+		case <-closed:
+		case <-ctx.Done():
+			return errx.WrapWithType(ErrDatabaseClose, ctx.Err(), "unable to close postgres connection pool, connections still in use")
+		}
+		log.Log(log.LevelNotice, "postgres database connection pool closed successful.")
+	case SQLite:
+		if db.SQLite == nil {
+			return nil
+		}
+		err := db.SQLite.Close()
+		if err != nil {
+			return errx.WrapWithType(ErrDatabaseClose, err, "unable to close sqlite database")
+		}
+		if err := db.removeLockFile(); err != nil {
+			return err
+		}
+		log.Log(log.LevelNotice, "sqlite database closed successful.")
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gopkg.cc/apibase/db"
+)
+
+func TestPostgresConnString(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  db.PostgresConfig
+		host    string
+		port    uint16
+		tls     bool
+		params  map[string]string
+		wantErr error
+	}{
+		{"tcp", db.PostgresConfig{Host: "db.example.com", Port: "6432", User: "app@prod", DB: "app", SSLMode: "disable"}, "db.example.com", 6432, false, map[string]string{}, nil},
+		{"ipv6 with tls", db.PostgresConfig{Host: "::1", Port: "5432", User: "app", DB: "app", SSLMode: "require"}, "::1", 5432, true, map[string]string{}, nil},
+		{"deprecated ssl_enabled", db.PostgresConfig{Host: "localhost", User: "app", DB: "app", SSLEnabled: true}, "localhost", 5432, true, map[string]string{}, nil},
+		{"unix socket", db.PostgresConfig{Host: "/var/run/postgresql", Port: "5433", User: "app", DB: "app"}, "/var/run/postgresql", 5433, false, map[string]string{}, nil},
+		{"runtime params", db.PostgresConfig{
+			Host: "localhost", User: "app", DB: "app", SSLMode: "disable",
+			ApplicationName: "apibase test", SearchPath: "app,public", StatementTimeout: "1m30s",
+			Params: []string{"timezone=UTC", "application_name=overwritten"},
+		}, "localhost", 5432, false, map[string]string{"application_name": "apibase test", "search_path": "app,public", "statement_timeout": "90000", "timezone": "UTC"}, nil},
+		{"invalid ssl mode", db.PostgresConfig{Host: "localhost", SSLMode: "on"}, "", 0, false, nil, db.ErrDatabaseConfig},
+		{"cert without key", db.PostgresConfig{Host: "localhost", SSLCert: "client.crt"}, "", 0, false, nil, db.ErrDatabaseConfig},
+		{"invalid statement timeout", db.PostgresConfig{Host: "localhost", StatementTimeout: "soon"}, "", 0, false, nil, db.ErrDatabaseConfig},
+		{"invalid param", db.PostgresConfig{Host: "localhost", Params: []string{"connect_timeout"}}, "", 0, false, nil, db.ErrDatabaseConfig},
+	}
+	for _, tt := range tests {
+		connString, err := tt.config.ConnString()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: ConnString() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		config, err := pgx.ParseConfig(connString)
+		if err != nil {
+			t.Errorf("%s: ParseConfig(%s) error: %v", tt.name, connString, err)
+			continue
+		}
+		if config.Host != tt.host || config.Port != tt.port || (config.TLSConfig != nil) != tt.tls || config.User != tt.config.User || config.Database != tt.config.DB {
+			t.Errorf("%s: %s = host %s, port %d, tls %t, user %s, db %s", tt.name, connString, config.Host, config.Port, config.TLSConfig != nil, config.User, config.Database)
+		}
+		for key, want := range tt.params {
+			if got := config.RuntimeParams[key]; got != want {
+				t.Errorf("%s: runtime param %s = '%s', want '%s'", tt.name, key, got, want)
+			}
+		}
+	}
+}
+
+func TestPostgresReplicaConfigs(t *testing.T) {
+	primary := db.PostgresConfig{Host: "primary", Port: "5433", User: "app", DB: "app", SSLMode: "require"}
+	tests := []struct {
+		name     string
+		replicas []string
+		hosts    []string
+		ports    []string
+		wantErr  error
+	}{
+		{"none", nil, []string{}, []string{}, nil},
+		{"host and host:port", []string{"replica-1", "replica-2:6432"}, []string{"replica-1", "replica-2"}, []string{"5433", "6432"}, nil},
+		{"ipv6", []string{"[::1]:5434", "[::2]", "::3"}, []string{"::1", "::2", "::3"}, []string{"5434", "5433", "5433"}, nil},
+		{"unix socket", []string{"/var/run/postgresql"}, []string{"/var/run/postgresql"}, []string{"5433"}, nil},
+		{"empty host", []string{":5432"}, nil, nil, db.ErrDatabaseConfig},
+	}
+	for _, tt := range tests {
+		config := primary
+		config.Replicas = tt.replicas
+		configs, err := config.ReplicaConfigs()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: ReplicaConfigs() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if len(configs) != len(tt.hosts) {
+			t.Errorf("%s: ReplicaConfigs() = %d configs, want %d", tt.name, len(configs), len(tt.hosts))
+			continue
+		}
+		for i, c := range configs {
+			if c.Host != tt.hosts[i] || c.Port != tt.ports[i] || c.User != primary.User || c.SSLMode != primary.SSLMode || len(c.Replicas) != 0 {
+				t.Errorf("%s: replica %d = %+v, want host %s, port %s and options of the primary", tt.name, i, c, tt.hosts[i], tt.ports[i])
+			}
+			if _, err := c.ConnString(); err != nil {
+				t.Errorf("%s: ConnString() of replica %d error: %v", tt.name, i, err)
+			}
+		}
+	}
+}
+
+func TestPostgresPoolConfig(t *testing.T) {
+	tests := []struct {
+		healthCheckPeriod string
+		want              time.Duration
+		wantErr           error
+	}{
+		{"", time.Minute, nil},
+		{"30s", time.Second * 30, nil},
+		{"0s", 0, db.ErrDatabaseConfig},
+		{"-1m", 0, db.ErrDatabaseConfig},
+	}
+	for _, tt := range tests {
+		pool := &db.PostgresPoolConfig{TomlHealthCheckPeriod: tt.healthCheckPeriod}
+		err := pool.AddMissingFromDefaults()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("AddMissingFromDefaults() with health_check_period '%s' error = %v, want %v", tt.healthCheckPeriod, err, tt.wantErr)
+		}
+		if err == nil && pool.HealthCheckPeriod != tt.want {
+			t.Errorf("HealthCheckPeriod of '%s' = %s, want %s", tt.healthCheckPeriod, pool.HealthCheckPeriod, tt.want)
+		}
+	}
+
+	pool := &db.PostgresPoolConfig{MinConns: 2, TomlMaxConnIdleTime: "5m"}
+	if err := pool.AddMissingFromDefaults(); err != nil {
+		t.Fatalf("AddMissingFromDefaults() error: %v", err)
+	}
+	if pool.MaxConns != 10 || pool.MinConns != 2 || pool.MaxConnLifetime != time.Hour || pool.MaxConnIdleTime != time.Minute*5 {
+		t.Errorf("AddMissingFromDefaults() = %+v, want defaults for unset values", pool)
+	}
+}
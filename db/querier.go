@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gopkg.cc/apibase/errx"
+)
+
+// returned by querier.scanOne() if the query didn't return any row
+var errNoRows = errors.New("no rows in result set")
+
+// Common query interface of PostgreSQL and SQLite connections and transactions.
+// Queries use Postgres placeholders ($1, $2, ...), which are rewritten for SQLite
+type querier interface {
+	exec(ctx context.Context, query string, args ...any) (rowsAffected int64, err error)
+	scanOne(ctx context.Context, dst any, query string, args ...any) error
+	scanAll(ctx context.Context, dst any, query string, args ...any) error
+}
+
+type transaction interface {
+	querier
+	commit(ctx context.Context) error
+	rollback()
+}
+
+// querier for the database connection, used for single queries without transaction. Failed queries are retried (see retryQuerier),
+// use runTx() for multiple queries
+func (db DB) conn() querier {
+	if db.Kind == SQLite {
+		return retryQuerier{db, sqliteQuerier{db.SQLite.DB, db.tracer}}
+	}
+	return retryQuerier{db, pgQuerier{db.Postgres}}
+}
+
+// begin transaction, rollback() must be deferred and is a no-op after commit(). Transactions aren't retried, use runTx() instead
+func (db DB) begin(ctx context.Context, opts TxOptions) (transaction, error) {
+	if db.Kind == SQLite {
+		tx, err := db.SQLite.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, errx.WrapWithType(ErrDatabaseQuery, err, "unable to start db transaction")
+		}
+		return &sqliteTx{sqliteQuerier{tx, db.tracer}, tx}, nil
+	}
+	tx, err := db.Postgres.BeginTx(ctx, opts.pgx())
+	if err != nil {
+		return nil, errx.WrapWithType(ErrDatabaseQuery, err, "unable to start db transaction")
+	}
+	return &pgTx{pgQuerier{tx}, tx}, nil
+}
+
+type pgQueryExecer interface {
+	pgxscan.Querier
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+type pgQuerier struct {
+	q pgQueryExecer
+}
+
+func (p pgQuerier) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	res, err := p.q.Exec(ctx, query, args...)
+	return res.RowsAffected(), err
+}
+
+func (p pgQuerier) scanOne(ctx context.Context, dst any, query string, args ...any) error {
+	err := pgxscan.Get(ctx, p.q, dst, query, args...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errNoRows
+	}
+	return err
+}
+
+func (p pgQuerier) scanAll(ctx context.Context, dst any, query string, args ...any) error {
+	return pgxscan.Select(ctx, p.q, dst, query, args...)
+}
+
+type pgTx struct {
+	pgQuerier
+	tx pgx.Tx
+}
+
+func (t *pgTx) commit(ctx context.Context) error {
+	return t.tx.Commit(ctx)
+}
+
+func (t *pgTx) rollback() {
+	_ = t.tx.Rollback(context.Background())
+}
+
+type sqlQueryExecer interface {
+	sqlscan.Querier
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// queries are recorded by tracer, pgx calls the tracer of its connection config for Postgres
+type sqliteQuerier struct {
+	q      sqlQueryExecer
+	tracer *queryTracer
+}
+
+var postgresPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+// rewrite $N placeholders to the numbered SQLite placeholders ?N
+func sqliteQuery(query string) string {
+	return postgresPlaceholder.ReplaceAllString(query, "?$1")
+}
+
+func (s sqliteQuerier) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	start := time.Now()
+	res, err := s.q.ExecContext(ctx, sqliteQuery(query), args...)
+	s.tracer.record(query, time.Since(start), err)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s sqliteQuerier) scanOne(ctx context.Context, dst any, query string, args ...any) error {
+	start := time.Now()
+	err := sqlscan.Get(ctx, s.q, dst, sqliteQuery(query), args...)
+	s.tracer.record(query, time.Since(start), err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errNoRows
+	}
+	return err
+}
+
+func (s sqliteQuerier) scanAll(ctx context.Context, dst any, query string, args ...any) error {
+	start := time.Now()
+	err := sqlscan.Select(ctx, s.q, dst, sqliteQuery(query), args...)
+	s.tracer.record(query, time.Since(start), err)
+	return err
+}
+
+type sqliteTx struct {
+	sqliteQuerier
+	tx *sql.Tx
+}
+
+func (t *sqliteTx) commit(ctx context.Context) error {
+	return t.tx.Commit()
+}
+
+func (t *sqliteTx) rollback() {
+	_ = t.tx.Rollback()
+}
@@ -0,0 +1,251 @@
+package db
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gopkg.cc/apibase/baseconfig"
+	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/log"
+)
+
+// Config of every entry of Replicas, with the other connection options and pool settings of pgc
+func (pgc PostgresConfig) ReplicaConfigs() ([]PostgresConfig, error) {
+	configs := []PostgresConfig{}
+	for _, replica := range pgc.Replicas {
+		config := pgc
+		config.Replicas = nil
+		config.Host = strings.TrimSpace(replica)
+		if !strings.HasPrefix(config.Host, "/") { // unix socket directory
+			if host, port, err := net.SplitHostPort(config.Host); err == nil {
+				config.Host, config.Port = host, port
+			} else {
+				config.Host = strings.TrimSuffix(strings.TrimPrefix(config.Host, "["), "]")
+			}
+		}
+		if config.Host == "" {
+			return nil, errx.NewWithTypef(ErrDatabaseConfig, "postgres replica '%s' has no host", replica)
+		}
+		if _, err := config.ConnString(); err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// Read replicas of a PostgreSQL DB. Replicas are pinged every PostgresPoolConfig.HealthCheckPeriod
+// and are marked unhealthy if a ping or read fails with connection loss, until the next successful ping
+type replicaSet struct {
+	replicas        []*replica
+	next            atomic.Uint32 // round robin
+	readsAfterWrite bool          // see PostgresConfig.ReplicaReadsAfterWrite
+	stop            chan struct{}
+	stopped         chan struct{}
+	closeOnce       sync.Once
+}
+
+type replica struct {
+	host    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// Connect to the replicas and start health checks, replicas that can't be reached are used once they are healthy
+func newReplicaSet(ctx context.Context, configs []PostgresConfig, readsAfterWrite bool, bc *baseconfig.BaseConfig, tracer *queryTracer) (*replicaSet, error) {
+	rs := &replicaSet{readsAfterWrite: readsAfterWrite, stop: make(chan struct{}), stopped: make(chan struct{})}
+	var healthCheckPeriod time.Duration
+	for _, pgc := range configs {
+		config, err := pgc.poolConfig(tracer)
+		if err != nil {
+			rs.closePools()
+			return nil, err
+		}
+		healthCheckPeriod = config.HealthCheckPeriod
+		pool, err := pgxpool.NewWithConfig(ctx, config)
+		if err != nil {
+			rs.closePools()
+			return nil, errx.WrapWithTypef(ErrDatabaseConn, err, "postgres replica '%s'", pgc.Host)
+		}
+		r := &replica{host: config.ConnConfig.Host, pool: pool}
+		r.healthy.Store(true)
+		rs.replicas = append(rs.replicas, r)
+	}
+	rs.checkHealth(ctx, bc.TimeoutDatabaseConnect)
+	log.Logf(log.LevelInfo, "Postgres connection pools to %d read replicas established.", len(rs.replicas))
+	go rs.run(healthCheckPeriod, bc.TimeoutDatabaseConnect)
+	return rs, nil
+}
+
+func (rs *replicaSet) run(period time.Duration, timeout time.Duration) {
+	defer close(rs.stopped)
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rs.checkHealth(context.Background(), timeout)
+		case <-rs.stop:
+			return
+		}
+	}
+}
+
+func (rs *replicaSet) checkHealth(ctx context.Context, timeout time.Duration) {
+	for _, r := range rs.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		r.setHealth(r.pool.Ping(pingCtx))
+		cancel()
+	}
+}
+
+// healthy if err is nil, changes are logged
+func (r *replica) setHealth(err error) {
+	healthy := err == nil
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		log.Logf(log.LevelNotice, "postgres replica '%s' is healthy again, reads are routed to it", r.host)
+	} else {
+		log.Logf(log.LevelWarning, "postgres replica '%s' is unhealthy, reads fall back to other replicas or the primary: %s", r.host, err.Error())
+	}
+}
+
+// next healthy replica, nil if there is none
+func (rs *replicaSet) pick() *replica {
+	start := rs.next.Add(1)
+	for i := range uint32(len(rs.replicas)) {
+		r := rs.replicas[(start+i)%uint32(len(rs.replicas))]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// stop health checks and close the pools, waits for acquired connections to be released
+func (rs *replicaSet) close() {
+	rs.closeOnce.Do(func() {
+		close(rs.stop)
+		<-rs.stopped
+		rs.closePools()
+	})
+}
+
+func (rs *replicaSet) closePools() {
+	for _, r := range rs.replicas {
+		r.pool.Close()
+	}
+}
+
+// querier for reads, which may lag behind the primary by the replication delay. Served by a healthy replica if replicas are configured
+// and ctx isn't pinned to the primary (see WithPrimary() and WithPrimaryAfterWrite()), otherwise by the primary like conn()
+func (db DB) read(ctx context.Context) querier {
+	if db.replicas == nil || primaryPinned(ctx) {
+		return db.conn()
+	}
+	r := db.replicas.pick()
+	if r == nil {
+		return db.conn()
+	}
+	return replicaQuerier{db, r}
+}
+
+// reads of a replica, falling back to the primary if they fail with a retryable error, writes always go to the primary
+type replicaQuerier struct {
+	db      DB
+	replica *replica
+}
+
+func (r replicaQuerier) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	return r.db.conn().exec(ctx, query, args...)
+}
+
+func (r replicaQuerier) scanOne(ctx context.Context, dst any, query string, args ...any) error {
+	if isWrite(query) {
+		return r.db.conn().scanOne(ctx, dst, query, args...)
+	}
+	err := pgQuerier{r.replica.pool}.scanOne(ctx, dst, query, args...)
+	if r.fallback(err) {
+		return r.db.conn().scanOne(ctx, dst, query, args...)
+	}
+	return err
+}
+
+func (r replicaQuerier) scanAll(ctx context.Context, dst any, query string, args ...any) error {
+	if isWrite(query) {
+		return r.db.conn().scanAll(ctx, dst, query, args...)
+	}
+	err := pgQuerier{r.replica.pool}.scanAll(ctx, dst, query, args...)
+	if r.fallback(err) {
+		return r.db.conn().scanAll(ctx, dst, query, args...)
+	}
+	return err
+}
+
+// e.g. connection loss or a query canceled because of a conflict with recovery, the replica is unhealthy on connection loss
+func (r replicaQuerier) fallback(err error) bool {
+	class := classifyError(err)
+	if class == errClassConnection {
+		r.replica.setHealth(err)
+	}
+	return class != errClassOther
+}
+
+type primaryPinKey struct{}
+
+// Context whose reads are all served by the primary, e.g. to read the writes of a previous request
+func WithPrimary(ctx context.Context) context.Context {
+	pinned := &atomic.Bool{}
+	pinned.Store(true)
+	return context.WithValue(ctx, primaryPinKey{}, pinned)
+}
+
+// Context whose reads are served by the primary once a write was done with it (or a context derived from it),
+// so the writes are read back even if they aren't replicated yet. Set for every request by web.PinPrimaryAfterWrite()
+func WithPrimaryAfterWrite(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(primaryPinKey{}).(*atomic.Bool); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, primaryPinKey{}, &atomic.Bool{})
+}
+
+func primaryPinned(ctx context.Context) bool {
+	pinned, ok := ctx.Value(primaryPinKey{}).(*atomic.Bool)
+	return ok && pinned.Load()
+}
+
+// pin ctx to the primary before a write, unless PostgresConfig.ReplicaReadsAfterWrite is set
+func (db DB) pinPrimary(ctx context.Context) {
+	if db.replicas == nil || db.replicas.readsAfterWrite {
+		return
+	}
+	if pinned, ok := ctx.Value(primaryPinKey{}).(*atomic.Bool); ok {
+		pinned.Store(true)
+	}
+}
+
+// Health and pool statistics of a read replica
+type ReplicaStats struct {
+	Host    string    `json:"host"`
+	Healthy bool      `json:"healthy"`
+	Pool    PoolStats `json:"pool"`
+}
+
+// statistics of all replicas, nil if there are none
+func (db DB) replicaStats() []ReplicaStats {
+	if db.replicas == nil {
+		return nil
+	}
+	stats := []ReplicaStats{}
+	for _, r := range db.replicas.replicas {
+		stats = append(stats, ReplicaStats{Host: r.host, Healthy: r.healthy.Load(), Pool: poolStats(r.pool)})
+	}
+	return stats
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/log"
+)
+
+type errorClass uint
+
+const (
+	errClassOther errorClass = iota
+	errClassConnection
+	errClassSerialization // includes SQLite busy/locked errors
+	errClassDeadlock
+)
+
+func (c errorClass) String() string {
+	switch c {
+	case errClassConnection:
+		return "connection loss"
+	case errClassSerialization:
+		return "serialization failure"
+	case errClassDeadlock:
+		return "deadlock"
+	}
+	return "other"
+}
+
+// classify database error, only errors of any class but errClassOther may succeed if retried
+func classifyError(err error) errorClass {
+	if err == nil {
+		return errClassOther
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "40001":
+			return errClassSerialization
+		case pgErr.Code == "40P01":
+			return errClassDeadlock
+		case strings.HasPrefix(pgErr.Code, "08"), pgErr.Code == "57P01", pgErr.Code == "57P02", pgErr.Code == "57P03":
+			// connection exception, admin shutdown, crash shutdown, cannot connect now
+			return errClassConnection
+		}
+		return errClassOther
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		if sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked {
+			return errClassSerialization
+		}
+		return errClassOther
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return errClassOther
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connectErr) || errors.As(err, &netErr) || pgconn.SafeToRetry(err) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return errClassConnection
+	}
+	return errClassOther
+}
+
+// the statement wasn't executed by the database, so it can be retried even if it isn't idempotent
+func notExecuted(err error) bool {
+	var connectErr *pgconn.ConnectError
+	return pgconn.SafeToRetry(err) || errors.As(err, &connectErr) || classifyError(err) == errClassSerialization
+}
+
+// Run fn until it succeeds, fails with an error that isn't retryable (see retryable) or BaseConfig.DatabaseMaxReconnectAttempts
+// retries are done. Retries wait with exponential backoff starting at DATABASE_RETRY_BACKOFF, up to SLEEP_DATABASE_RECONNECT.
+// If retries run out because of connection loss, ErrDatabaseConn is returned
+func (db DB) retry(ctx context.Context, retryable func(err error) bool, fn func() error) error {
+	backoff := DATABASE_RETRY_BACKOFF
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !retryable(err) {
+			return err
+		}
+		class := classifyError(err)
+		if attempt >= int(db.BaseConfig.DatabaseMaxReconnectAttempts) {
+			if class == errClassConnection {
+				return errx.WrapWithTypef(ErrDatabaseConn, err, "giving up after %d retries", attempt)
+			}
+			return err
+		}
+		// jitter, so concurrent requests don't retry at the same time
+		wait := backoff/2 + rand.N(backoff/2+1)
+		log.Logf(log.LevelInfo, "database %s, retrying in %s (%d/%d): %s", class, wait, attempt+1, db.BaseConfig.DatabaseMaxReconnectAttempts, err.Error())
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			if class == errClassConnection {
+				return errx.WrapWithType(ErrDatabaseConn, err, "giving up, context done")
+			}
+			return err
+		}
+		backoff = min(backoff*2, SLEEP_DATABASE_RECONNECT)
+	}
+}
+
+// reads are idempotent and retried for any retryable error
+func retryRead(err error) bool {
+	return classifyError(err) != errClassOther
+}
+
+// Run fn in a transaction, which is committed if fn returns nil. The whole transaction is retried on connection loss,
+// serialization failure or deadlock, so fn must not have side effects outside of tx and must set its results on every run.
+// A failed commit is only retried if the transaction is known to be rolled back
+func (db DB) runTx(ctx context.Context, fn func(tx querier) error) error {
+	return db.runTxOptions(ctx, TxOptions{}, fn)
+}
+
+func (db DB) runTxOptions(ctx context.Context, opts TxOptions, fn func(tx querier) error) error {
+	if !opts.ReadOnly {
+		db.pinPrimary(ctx)
+	}
+	committing := false
+	return db.retry(ctx, func(err error) bool {
+		if committing {
+			return notExecuted(err)
+		}
+		return classifyError(err) != errClassOther
+	}, func() error {
+		committing = false
+		tx, err := db.begin(ctx, opts)
+		if err != nil {
+			return err
+		}
+		defer tx.rollback()
+		if err := fn(tx); err != nil {
+			return err
+		}
+		committing = true
+		if err := tx.commit(ctx); err != nil {
+			return errx.WrapWithType(ErrDatabaseCommit, err, "")
+		}
+		return nil
+	})
+}
+
+// querier for single statements outside of a transaction, reads are retried and statements are only retried if not executed
+type retryQuerier struct {
+	db DB
+	q  querier
+}
+
+func (r retryQuerier) exec(ctx context.Context, query string, args ...any) (rowsAffected int64, err error) {
+	r.db.pinPrimary(ctx)
+	err = r.db.retry(ctx, notExecuted, func() error {
+		rowsAffected, err = r.q.exec(ctx, query, args...)
+		return err
+	})
+	return rowsAffected, err
+}
+
+func (r retryQuerier) scanOne(ctx context.Context, dst any, query string, args ...any) error {
+	if isWrite(query) {
+		// e.g. INSERT ... RETURNING
+		r.db.pinPrimary(ctx)
+		return r.db.retry(ctx, notExecuted, func() error { return r.q.scanOne(ctx, dst, query, args...) })
+	}
+	return r.db.retry(ctx, retryRead, func() error { return r.q.scanOne(ctx, dst, query, args...) })
+}
+
+func (r retryQuerier) scanAll(ctx context.Context, dst any, query string, args ...any) error {
+	if isWrite(query) {
+		r.db.pinPrimary(ctx)
+		return r.db.retry(ctx, notExecuted, func() error { return r.q.scanAll(ctx, dst, query, args...) })
+	}
+	return r.db.retry(ctx, retryRead, func() error { return r.q.scanAll(ctx, dst, query, args...) })
+}
+
+func isWrite(query string) bool {
+	return !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "SELECT")
+}
@@ -0,0 +1,85 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.cc/apibase/baseconfig"
+	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/table"
+)
+
+func TestRetryLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	bc := baseconfig.BaseConfig{}
+	if err := bc.AddMissingFromDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	database, err := db.SQLiteInit(context.Background(), db.SQLiteConfig{FilePath: path}, &bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close(context.Background())
+	if err := db.MigrateUp(context.Background(), database); err != nil {
+		t.Fatal(err)
+	}
+	// fail immediately instead of waiting for the lock in the driver, the busy timeout is set per connection
+	database.SQLite.DB.SetMaxOpenConns(1)
+	if _, err := database.SQLite.DB.Exec("PRAGMA busy_timeout = 0"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := database.CreateNewUserWithOrg(context.Background(), table.User{Name: "alice", AuthProvider: "local", Email: "alice@example.com", SecretsVersion: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// second process holding the write lock, operations fail with SQLITE_BUSY until it is released
+	other, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	lock := func() (release func()) {
+		conn, err := other.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.ExecContext(context.Background(), "BEGIN EXCLUSIVE"); err != nil {
+			t.Fatal(err)
+		}
+		return func() {
+			_, _ = conn.ExecContext(context.Background(), "COMMIT")
+			conn.Close()
+		}
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"exec", func() error { return database.SetUserSuperAdmin(context.Background(), user.ID, true) }},
+		{"read", func() error { _, err := database.GetUserByID(context.Background(), user.ID); return err }},
+		{"transaction", func() error { return database.SetUserDisabled(context.Background(), user.ID, true) }},
+	}
+	for _, tt := range tests {
+		release := lock()
+		go func() {
+			time.Sleep(150 * time.Millisecond)
+			release()
+		}()
+		if err := tt.run(); err != nil {
+			t.Errorf("%s: error after lock was released: %v", tt.name, err)
+		}
+	}
+
+	release := lock()
+	err = database.SetUserSuperAdmin(context.Background(), user.ID, false)
+	release()
+	if err == nil || errors.Is(err, db.ErrDatabaseConn) {
+		t.Errorf("error while locked = %v, want busy error after retries", err)
+	}
+}
@@ -1,16 +1,87 @@
 package db
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
 	"gopkg.cc/apibase/baseconfig"
 	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/log"
 	"gopkg.cc/apibase/sqlite"
 )
 
+// connection options appended to SQLiteConfig.FilePath: foreign keys are enforced, transactions take the write lock
+// on begin and concurrent writers wait for the lock instead of failing
+const SQLITE_CONNECTION_OPTIONS = "_foreign_keys=on&_busy_timeout=5000&_txlock=immediate"
+
 func InitSQLite(config SQLiteConfig, bc baseconfig.BaseConfig) (*sqlite.SQLite, error) {
-	// TODO: validate path and use LockFile
-	sqlite, err := sqlite.OpenWithConfig(config.FilePath, sqlite.SQLiteConfig{SQLITE_DATETIME_FORMAT: bc.SQLiteDatetimeFormat})
+	if config.FilePath == "" {
+		return nil, errx.NewWithType(ErrDatabaseConfig, "sqlite file_path must not be empty")
+	}
+	dsn := config.FilePath + "?" + SQLITE_CONNECTION_OPTIONS
+	if strings.Contains(config.FilePath, "?") {
+		dsn = config.FilePath + "&" + SQLITE_CONNECTION_OPTIONS
+	}
+	sqlite, err := sqlite.OpenWithConfig(dsn, sqlite.SQLiteConfig{SQLITE_DATETIME_FORMAT: bc.SQLiteDatetimeFormat})
 	if err != nil {
 		return sqlite, errx.WrapWithType(ErrDatabaseConn, err, "unable to open sqlite database")
 	}
 	return sqlite, nil
 }
+
+// Open sqlite database, requires DB.Close() for clean shutdown, which is done automatically if base.ApiBase[T].SQLiteInit() is used.
+// If config.LockFile is set, it is created exclusively and removed on DB.Close(), so only one process uses the database at a time
+func SQLiteInit(ctx context.Context, config SQLiteConfig, bc *baseconfig.BaseConfig) (DB, error) {
+	db := DB{Kind: SQLite, BaseConfig: bc, tracer: newQueryTracer(bc)}
+	if config.LockFile != "" {
+		if err := createLockFile(config.LockFile); err != nil {
+			return db, err
+		}
+		db.lockFile = config.LockFile
+	}
+	var err error
+	db.SQLite, err = InitSQLite(config, *bc)
+	if err != nil {
+		db.removeLockFile()
+		return db, err
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, bc.TimeoutDatabaseConnect)
+	defer cancel()
+	if err := db.SQLite.DB.PingContext(pingCtx); err != nil {
+		db.SQLite.Close()
+		db.removeLockFile()
+		return db, errx.WrapWithTypef(ErrDatabaseConn, err, "unable to open sqlite database '%s'", config.FilePath)
+	}
+	log.Logf(log.LevelInfo, "SQLite database '%s' opened.", config.FilePath)
+	return db, nil
+}
+
+// lock file contains the pid of the process holding the lock
+func createLockFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		pid, _ := os.ReadFile(path)
+		return errx.NewWithTypef(ErrDatabaseLocked, "lock file '%s' exists (pid: %s), remove it if no other process uses the database", path, strings.TrimSpace(string(pid)))
+	}
+	if err != nil {
+		return errx.WrapWithTypef(ErrDatabaseLocked, err, "unable to create lock file '%s'", path)
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintf(file, "%d\n", os.Getpid()); err != nil {
+		return errx.WrapWithTypef(ErrDatabaseLocked, err, "unable to write lock file '%s'", path)
+	}
+	return nil
+}
+
+func (db DB) removeLockFile() error {
+	if db.lockFile == "" {
+		return nil
+	}
+	if err := os.Remove(db.lockFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errx.WrapWithTypef(ErrDatabaseClose, err, "unable to remove lock file '%s'", db.lockFile)
+	}
+	return nil
+}
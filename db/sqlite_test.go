@@ -0,0 +1,131 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.cc/apibase/baseconfig"
+	"gopkg.cc/apibase/db"
+	h "gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/table"
+)
+
+func TestSQLite(t *testing.T) {
+	dir := t.TempDir()
+	config := db.SQLiteConfig{FilePath: filepath.Join(dir, "test.db"), LockFile: filepath.Join(dir, "test.lock")}
+	bc := baseconfig.BaseConfig{}
+	if err := bc.AddMissingFromDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	database, err := db.SQLiteInit(context.Background(), config, &bc)
+	if err != nil {
+		t.Fatalf("SQLiteInit() error: %v", err)
+	}
+	if _, err := db.SQLiteInit(context.Background(), config, &bc); !errors.Is(err, db.ErrDatabaseLocked) {
+		t.Errorf("second SQLiteInit() error = %v, want ErrDatabaseLocked", err)
+	}
+	if err := db.ValidateDB(context.Background(), database); err != nil {
+		t.Fatalf("ValidateDB() error: %v", err)
+	}
+	for range 2 { // migration must be repeatable
+		if err := db.MigrateUp(context.Background(), database); err != nil {
+			t.Fatalf("MigrateUp() error: %v", err)
+		}
+	}
+
+	user, err := database.CreateNewUserWithOrg(context.Background(), table.User{Name: "alice", AuthProvider: "local", Email: "alice@example.com", SecretsVersion: 1})
+	if err != nil {
+		t.Fatalf("CreateNewUserWithOrg() error: %v", err)
+	}
+	if _, err := database.CreateNewUserWithOrg(context.Background(), table.User{Name: "alice2", Email: "alice@example.com"}); !errors.Is(err, db.ErrUserAlreadyExists) {
+		t.Errorf("duplicate CreateNewUserWithOrg() error = %v, want ErrUserAlreadyExists", err)
+	}
+	byEmail, err := database.GetUserByEmail(context.Background(), "alice@example.com")
+	if err != nil || byEmail.ID != user.ID || byEmail.CreatedAt.IsZero() {
+		t.Errorf("GetUserByEmail() = %+v, %v", byEmail, err)
+	}
+	if _, err := database.GetUserByID(context.Background(), table.NewID()); !errors.Is(err, db.ErrDatabaseNotFound) {
+		t.Errorf("GetUserByID() of missing user error = %v, want ErrDatabaseNotFound", err)
+	}
+	roles, err := database.GetUserRoles(context.Background(), user.ID)
+	if err != nil || len(roles) != 1 || !roles[0].OrgAdmin {
+		t.Fatalf("GetUserRoles() = %+v, %v", roles, err)
+	}
+
+	session, newSession := h.CreateSecretString("session-1"), h.CreateSecretString("session-2")
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	if err := database.CreateRefreshTokenEntry(context.Background(), table.RefreshToken{UserID: user.ID, SessionID: session, ExpiresAt: expires}); err != nil {
+		t.Fatalf("CreateRefreshTokenEntry() error: %v", err)
+	}
+	if err := database.UpdateRefreshTokenEntry(context.Background(), user.ID, session, newSession, "test", expires); err != nil {
+		t.Fatalf("UpdateRefreshTokenEntry() error: %v", err)
+	}
+	for _, tt := range []struct {
+		session h.SecretString
+		want    bool
+	}{{session, false}, {newSession, true}} {
+		if ok, err := database.VerifyRefreshTokenSessionId(context.Background(), user.ID, tt.session); ok != tt.want || err != nil {
+			t.Errorf("VerifyRefreshTokenSessionId(%s) = %v, %v, want %v", tt.session.GetSecret(), ok, err, tt.want)
+		}
+	}
+	if err := database.DeleteRefreshToken(context.Background(), user.ID, newSession); err != nil {
+		t.Errorf("DeleteRefreshToken() error: %v", err)
+	}
+
+	task := table.ScheduledTask{TaskID: "task-1", OrgID: roles[0].OrgID, StartDate: expires, Interval: table.Duration(time.Minute), TaskType: "test", TaskData: "{}"}
+	if err := database.CreateScheduledTask(context.Background(), task); err != nil {
+		t.Fatalf("CreateScheduledTask() error: %v", err)
+	}
+	task.Interval = table.Duration(time.Hour)
+	if err := database.UpdateScheduledTask(context.Background(), task); err != nil {
+		t.Fatalf("UpdateScheduledTask() error: %v", err)
+	}
+	tasks, err := database.GetScheduledTasks(context.Background(), user.ID)
+	if err != nil || len(tasks) != 1 || tasks[0].Interval != task.Interval || !tasks[0].StartDate.Equal(expires) {
+		t.Errorf("GetScheduledTasks() = %+v, %v", tasks, err)
+	}
+	if err := database.DeleteScheduledTask(context.Background(), task.TaskID); err != nil {
+		t.Errorf("DeleteScheduledTask() error: %v", err)
+	}
+	if err := database.CreateScheduledTask(context.Background(), table.ScheduledTask{TaskID: "task-2", OrgID: table.NewID(), StartDate: expires}); err == nil {
+		t.Error("CreateScheduledTask() for missing org succeeded, foreign keys not enforced")
+	}
+
+	if err := database.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if _, err := os.Stat(config.LockFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock file not removed on Close(): %v", err)
+	}
+}
+
+func TestQueryStats(t *testing.T) {
+	ctx := context.Background()
+	database := newStores(t)["sqlite"].(db.DB)
+	for range 3 {
+		if _, err := database.GetUserByEmail(ctx, "missing@example.com"); !errors.Is(err, db.ErrDatabaseNotFound) {
+			t.Fatalf("GetUserByEmail() error = %v, want ErrDatabaseNotFound", err)
+		}
+	}
+	stats := database.Stats()
+	i := slices.IndexFunc(stats.Queries, func(s db.QueryStat) bool { return strings.HasPrefix(s.Query, "SELECT * FROM users WHERE email") })
+	if i < 0 {
+		t.Fatalf("Stats() = %+v, want query of GetUserByEmail()", stats.Queries)
+	}
+	stat, calls := stats.Queries[i], int64(0)
+	for _, n := range stat.Histogram {
+		calls += n
+	}
+	if stat.Method != "GetUserByEmail" || stat.Calls != 3 || stat.Errors != 0 || calls != 3 || len(stat.Histogram) != len(db.QueryLatencyBuckets)+1 {
+		t.Errorf("Stats() of GetUserByEmail() = %+v, want 3 calls without errors", stat)
+	}
+	if stats.Pool != nil {
+		t.Errorf("Stats() pool of SQLite = %+v, want nil", stats.Pool)
+	}
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	h "gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/table"
+)
+
+// Storage of the default apibase tables used by package web, cron and the auth packages.
+// DB implements Store for PostgreSQL and SQLite, MemoryStore keeps all entries in memory (e.g. for unit tests).
+// Errors are of the same type for every implementation, e.g. ErrDatabaseNotFound if an entry doesn't exist.
+// The List methods return pages of entries, use them instead of the Get methods returning all entries for large tables (see ListQuery)
+type Store interface {
+	// Users
+	CreateNewUserWithOrg(ctx context.Context, user table.User, roles ...table.UserRole) (table.User, error)
+	CreateNewUserWithTenant(ctx context.Context, user table.User, tenant table.Organization) (table.User, error)
+	CreateUserIfNotExist(ctx context.Context, user table.User, roles ...table.UserRole) (table.User, error)
+	GetOrCreateUser(ctx context.Context, user table.User, role table.UserRole) (table.User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (table.User, error)
+	GetUserByEmail(ctx context.Context, email string) (table.User, error)
+	GetAllUsers(ctx context.Context) ([]table.User, error)
+	ListUsers(ctx context.Context, q ListQuery) (ListPage[table.User], error)
+	SetUserDisabled(ctx context.Context, userID uuid.UUID, disabled bool) error
+	SetUserSuperAdmin(ctx context.Context, userID uuid.UUID, superAdmin bool) error
+	UpdateUserPassword(ctx context.Context, userID uuid.UUID, passwordHash h.SecretString) error
+	DeleteUser(ctx context.Context, userID uuid.UUID) error
+	RestoreUser(ctx context.Context, userID uuid.UUID) error
+	GetDeletedUsers(ctx context.Context) ([]table.User, error)
+
+	// Roles and Organizations (tenants)
+	GetUserRoles(ctx context.Context, userID uuid.UUID) ([]table.UserRole, error)
+	ListUserRoles(ctx context.Context, userID uuid.UUID, q ListQuery) (ListPage[table.UserRole], error)
+	CreateUserRole(ctx context.Context, role table.UserRole) error
+	CreateOrg(ctx context.Context, org table.Organization) (table.Organization, error)
+	GetOrgByName(ctx context.Context, name string) (table.Organization, error)
+	GetOrgBySlug(ctx context.Context, slug string) (table.Organization, error)
+	GetOrgByExternalID(ctx context.Context, tenantType string, externalID string) (table.Organization, error)
+	ListOrgs(ctx context.Context, q ListQuery) (ListPage[table.Organization], error)
+	DeleteOrg(ctx context.Context, orgID uuid.UUID) error
+	RestoreOrg(ctx context.Context, orgID uuid.UUID) error
+	GetDeletedOrgs(ctx context.Context) ([]table.Organization, error)
+
+	// Refresh Tokens
+	CreateRefreshTokenEntry(ctx context.Context, token table.RefreshToken) error
+	VerifyRefreshTokenSessionId(ctx context.Context, userID uuid.UUID, sessionId h.SecretString) (bool, error)
+	UpdateRefreshTokenEntry(ctx context.Context, userId uuid.UUID, sessionId h.SecretString, newSessionId h.SecretString, userAgent string, expiresAt time.Time) error
+	DeleteRefreshToken(ctx context.Context, userID uuid.UUID, sessionId h.SecretString) error
+	DeleteRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error)
+
+	// Scheduled Tasks
+	GetScheduledTask(ctx context.Context, taskId string) (table.ScheduledTask, error)
+	GetScheduledTasks(ctx context.Context, userId uuid.UUID) ([]table.ScheduledTask, error)
+	GetAllScheduledTasks(ctx context.Context) ([]table.ScheduledTask, error)
+	ListScheduledTasks(ctx context.Context, orgID uuid.UUID, q ListQuery) (ListPage[table.ScheduledTask], error)
+	CreateScheduledTask(ctx context.Context, task table.ScheduledTask) error
+	UpdateScheduledTask(ctx context.Context, task table.ScheduledTask) error
+	DeleteScheduledTask(ctx context.Context, taskId string) error
+	RestoreScheduledTask(ctx context.Context, taskId string) error
+
+	// Soft deleted entries are hidden from all other methods until they are restored or purged
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error)
+
+	// Audit log, role grants, task changes and revoked sessions are recorded by the methods above
+	CreateAuditEvent(ctx context.Context, event table.AuditEvent) error
+	GetAuditEvents(ctx context.Context, filter AuditFilter) ([]table.AuditEvent, error)
+}
+
+var (
+	_ Store = DB{}
+	_ Store = (*MemoryStore)(nil)
+)
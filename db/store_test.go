@@ -0,0 +1,297 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"gopkg.cc/apibase/baseconfig"
+	"gopkg.cc/apibase/db"
+	h "gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/table"
+)
+
+// migrated SQLite database and MemoryStore, every Store implementation must behave the same, including error types
+func newStores(t *testing.T) map[string]db.Store {
+	ctx := context.Background()
+	bc := baseconfig.BaseConfig{}
+	if err := bc.AddMissingFromDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	database, err := db.SQLiteInit(ctx, db.SQLiteConfig{FilePath: filepath.Join(t.TempDir(), "test.db")}, &bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close(ctx) })
+	if err := db.MigrateUp(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]db.Store{"sqlite": database, "memory": db.NewMemoryStore()}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range newStores(t) {
+		user, err := store.CreateNewUserWithOrg(ctx, table.User{Name: "alice", AuthProvider: "local", Email: "alice@example.com", SecretsVersion: 1})
+		if err != nil {
+			t.Fatalf("%s: CreateNewUserWithOrg() error: %v", name, err)
+		}
+		if _, err := store.CreateNewUserWithOrg(ctx, table.User{Name: "alice2", AuthProvider: "local", Email: "alice@example.com", SecretsVersion: 1}); !errors.Is(err, db.ErrUserAlreadyExists) {
+			t.Errorf("%s: CreateNewUserWithOrg() of existing email error = %v, want ErrUserAlreadyExists", name, err)
+		}
+		if _, err := store.GetUserByEmail(ctx, "bob@example.com"); !errors.Is(err, db.ErrDatabaseNotFound) {
+			t.Errorf("%s: GetUserByEmail() of missing user error = %v, want ErrDatabaseNotFound", name, err)
+		}
+		roles, err := store.GetUserRoles(ctx, user.ID)
+		if err != nil || len(roles) != 1 || !roles[0].OrgAdmin {
+			t.Errorf("%s: GetUserRoles() = %+v, %v, want one admin role", name, roles, err)
+		}
+		if org, err := store.GetOrgByName(ctx, "userorg-alice"); err != nil || org.TenantType != table.TenantTypeUser || org.Slug != "userorg-alice" {
+			t.Errorf("%s: GetOrgByName() = %+v, %v, want user tenant with slug 'userorg-alice'", name, org, err)
+		}
+
+		tenant := table.Organization{Name: "Acme Inc.", TenantType: "customer", ExternalID: "cus_1", Metadata: `{"plan":"pro"}`}
+		if _, err := store.CreateNewUserWithTenant(ctx, table.User{Name: "carol", AuthProvider: "local", Email: "carol@example.com", SecretsVersion: 1}, tenant); err != nil {
+			t.Fatalf("%s: CreateNewUserWithTenant() error: %v", name, err)
+		}
+		if org, err := store.GetOrgBySlug(ctx, "acme-inc"); err != nil || org.ExternalID != "cus_1" || org.Metadata != tenant.Metadata {
+			t.Errorf("%s: GetOrgBySlug() = %+v, %v, want tenant with external id 'cus_1'", name, org, err)
+		}
+		if _, err := store.GetOrgByExternalID(ctx, "customer", "cus_1"); err != nil {
+			t.Errorf("%s: GetOrgByExternalID() error: %v", name, err)
+		}
+		if _, err := store.GetOrgByExternalID(ctx, table.TenantTypeOrg, "cus_1"); !errors.Is(err, db.ErrDatabaseNotFound) {
+			t.Errorf("%s: GetOrgByExternalID() of other tenant type error = %v, want ErrDatabaseNotFound", name, err)
+		}
+		if _, err := store.CreateOrg(ctx, table.Organization{Name: "Acme 2", TenantType: "customer", ExternalID: "cus_1"}); !errors.Is(err, db.ErrDatabaseInsert) {
+			t.Errorf("%s: CreateOrg() with existing external id error = %v, want ErrDatabaseInsert", name, err)
+		}
+		if org, err := store.CreateOrg(ctx, table.Organization{Name: "Acme 2", TenantType: table.TenantTypeOrg, ExternalID: "cus_1"}); err != nil || org.Slug != "acme-2" {
+			t.Errorf("%s: CreateOrg() with external id of other tenant type = %+v, %v, want slug 'acme-2'", name, org, err)
+		}
+		if org, err := store.CreateOrg(ctx, table.Organization{Name: "acme-inc"}); err != nil || !strings.HasPrefix(org.Slug, "acme-inc-") || len(org.Slug) != len("acme-inc-")+8 {
+			t.Errorf("%s: CreateOrg() with taken generated slug = %+v, %v, want slug 'acme-inc-<suffix>'", name, org, err)
+		}
+		if _, err := store.CreateOrg(ctx, table.Organization{Name: "Acme 3", Slug: "acme-inc"}); !errors.Is(err, db.ErrOrgSlugExists) {
+			t.Errorf("%s: CreateOrg() with taken slug error = %v, want ErrOrgSlugExists", name, err)
+		}
+
+		sessionId, newSessionId := h.CreateSecretString("session"), h.CreateSecretString("new session")
+		if err := store.CreateRefreshTokenEntry(ctx, table.RefreshToken{UserID: user.ID, SessionID: sessionId, UserAgent: "test", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatalf("%s: CreateRefreshTokenEntry() error: %v", name, err)
+		}
+		if err := store.UpdateRefreshTokenEntry(ctx, user.ID, sessionId, newSessionId, "test", time.Now().Add(time.Hour)); err != nil {
+			t.Errorf("%s: UpdateRefreshTokenEntry() error: %v", name, err)
+		}
+		if valid, err := store.VerifyRefreshTokenSessionId(ctx, user.ID, sessionId); valid || err != nil {
+			t.Errorf("%s: VerifyRefreshTokenSessionId() of replaced session = %t, %v, want false", name, valid, err)
+		}
+		if valid, err := store.VerifyRefreshTokenSessionId(ctx, user.ID, newSessionId); !valid || err != nil {
+			t.Errorf("%s: VerifyRefreshTokenSessionId() = %t, %v, want true", name, valid, err)
+		}
+		if err := store.UpdateUserPassword(ctx, user.ID, h.CreateSecretString("hash")); err != nil {
+			t.Errorf("%s: UpdateUserPassword() error: %v", name, err)
+		}
+		if err := store.DeleteRefreshToken(ctx, user.ID, newSessionId); !errors.Is(err, db.ErrDatabaseDelete) {
+			t.Errorf("%s: DeleteRefreshToken() of revoked session error = %v, want ErrDatabaseDelete", name, err)
+		}
+		if updated, err := store.GetUserByID(ctx, user.ID); err != nil || updated.SecretsVersion != 2 {
+			t.Errorf("%s: GetUserByID() secrets version = %d, %v, want 2", name, updated.SecretsVersion, err)
+		}
+
+		task := table.ScheduledTask{TaskID: "task", OrgID: roles[0].OrgID, StartDate: time.Now(), Interval: table.Duration(time.Hour), TaskType: "test", TaskData: "{}"}
+		if err := store.CreateScheduledTask(ctx, task); err != nil {
+			t.Fatalf("%s: CreateScheduledTask() error: %v", name, err)
+		}
+		if tasks, err := store.GetScheduledTasks(ctx, user.ID); err != nil || len(tasks) != 1 {
+			t.Errorf("%s: GetScheduledTasks() = %d tasks, %v, want 1", name, len(tasks), err)
+		}
+		if err := store.DeleteScheduledTask(ctx, "task"); err != nil {
+			t.Errorf("%s: DeleteScheduledTask() error: %v", name, err)
+		}
+		if _, err := store.GetAllScheduledTasks(ctx); !errors.Is(err, db.ErrDatabaseNotFound) {
+			t.Errorf("%s: GetAllScheduledTasks() without tasks error = %v, want ErrDatabaseNotFound", name, err)
+		}
+
+		// soft delete, the email of a deleted user can be reused
+		if err := store.DeleteUser(ctx, user.ID); err != nil {
+			t.Fatalf("%s: DeleteUser() error: %v", name, err)
+		}
+		if _, err := store.GetUserByEmail(ctx, "alice@example.com"); !errors.Is(err, db.ErrDatabaseNotFound) {
+			t.Errorf("%s: GetUserByEmail() of deleted user error = %v, want ErrDatabaseNotFound", name, err)
+		}
+		other, err := store.CreateNewUserWithOrg(ctx, table.User{Name: "alice3", AuthProvider: "local", Email: "alice@example.com", SecretsVersion: 1})
+		if err != nil {
+			t.Fatalf("%s: CreateNewUserWithOrg() with email of deleted user error: %v", name, err)
+		}
+		if err := store.RestoreUser(ctx, user.ID); !errors.Is(err, db.ErrUserAlreadyExists) {
+			t.Errorf("%s: RestoreUser() of reused email error = %v, want ErrUserAlreadyExists", name, err)
+		}
+		if err := store.DeleteUser(ctx, other.ID); err != nil {
+			t.Errorf("%s: DeleteUser() error: %v", name, err)
+		}
+		if err := store.RestoreUser(ctx, user.ID); err != nil {
+			t.Errorf("%s: RestoreUser() error: %v", name, err)
+		}
+		if restored, err := store.GetUserRoles(ctx, user.ID); err != nil || len(restored) != 1 {
+			t.Errorf("%s: GetUserRoles() of restored user = %+v, %v, want one role", name, restored, err)
+		}
+
+		task.TaskID = "org task"
+		if err := store.CreateScheduledTask(ctx, task); err != nil {
+			t.Fatalf("%s: CreateScheduledTask() error: %v", name, err)
+		}
+		if err := store.DeleteOrg(ctx, task.OrgID); err != nil {
+			t.Fatalf("%s: DeleteOrg() error: %v", name, err)
+		}
+		if _, err := store.GetScheduledTask(ctx, "org task"); !errors.Is(err, db.ErrDatabaseNotFound) {
+			t.Errorf("%s: GetScheduledTask() of deleted org error = %v, want ErrDatabaseNotFound", name, err)
+		}
+		if err := store.RestoreScheduledTask(ctx, "task"); !errors.Is(err, db.ErrDatabaseNotFound) {
+			t.Errorf("%s: RestoreScheduledTask() of deleted org error = %v, want ErrDatabaseNotFound", name, err)
+		}
+		if err := store.RestoreOrg(ctx, task.OrgID); err != nil {
+			t.Errorf("%s: RestoreOrg() error: %v", name, err)
+		}
+		if _, err := store.GetScheduledTask(ctx, "org task"); err != nil {
+			t.Errorf("%s: GetScheduledTask() of restored org error: %v", name, err)
+		}
+		if _, err := store.GetUserRoles(ctx, user.ID); err != nil {
+			t.Errorf("%s: GetUserRoles() of restored org error: %v", name, err)
+		}
+		if err := store.RestoreScheduledTask(ctx, "task"); err != nil {
+			t.Errorf("%s: RestoreScheduledTask() error: %v", name, err)
+		}
+
+		// the deleted user and its role
+		if purged, err := store.PurgeDeleted(ctx, time.Now().Add(time.Second)); purged != 2 || err != nil {
+			t.Errorf("%s: PurgeDeleted() = %d, %v, want 2", name, purged, err)
+		}
+		if deleted, err := store.GetDeletedUsers(ctx); len(deleted) != 0 || err != nil {
+			t.Errorf("%s: GetDeletedUsers() after purge = %d users, %v, want 0", name, len(deleted), err)
+		}
+
+		// role grants, task changes and revoked sessions are recorded with the actor of ctx
+		actorCtx := db.WithAuditActor(ctx, db.AuditActor{UserID: other.ID, IP: "192.0.2.1"})
+		if _, err := store.CreateNewUserWithOrg(actorCtx, table.User{Name: "dave", AuthProvider: "local", Email: "dave@example.com", SecretsVersion: 1}); err != nil {
+			t.Fatalf("%s: CreateNewUserWithOrg() error: %v", name, err)
+		}
+		task.TaskID = "audited task"
+		if err := store.CreateScheduledTask(actorCtx, task); err != nil {
+			t.Fatalf("%s: CreateScheduledTask() error: %v", name, err)
+		}
+		task.TaskData = `{"changed":true}`
+		if err := store.UpdateScheduledTask(actorCtx, task); err != nil {
+			t.Errorf("%s: UpdateScheduledTask() error: %v", name, err)
+		}
+		events, err := store.GetAuditEvents(ctx, db.AuditFilter{ActorID: other.ID})
+		if err != nil || len(events) != 3 || events[0].Action != table.AuditTaskUpdate || events[2].Action != table.AuditRoleGrant || events[2].IP != "192.0.2.1" {
+			t.Errorf("%s: GetAuditEvents() of actor = %+v, %v, want task update, task create and role grant", name, events, err)
+		} else if events[0].Diff != `{"task_data":{"new":"{\"changed\":true}","old":"{}"}}` {
+			t.Errorf("%s: GetAuditEvents() diff of task update = %s", name, events[0].Diff)
+		}
+		if events, err := store.GetAuditEvents(ctx, db.AuditFilter{OrgIDs: []uuid.UUID{task.OrgID}, Action: table.AuditTaskUpdate, From: time.Now().Add(-time.Minute)}); err != nil || len(events) != 1 {
+			t.Errorf("%s: GetAuditEvents() of org and action = %d events, %v, want 1", name, len(events), err)
+		}
+		if events, err := store.GetAuditEvents(ctx, db.AuditFilter{Action: table.AuditSessionsRevoke, To: time.Now().Add(-time.Hour)}); err != nil || len(events) != 0 {
+			t.Errorf("%s: GetAuditEvents() before time range = %d events, %v, want 0", name, len(events), err)
+		}
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range newStores(t) {
+		org, err := store.CreateOrg(ctx, table.Organization{Name: "tasks"})
+		if err != nil {
+			t.Fatalf("%s: CreateOrg() error: %v", name, err)
+		}
+		for _, id := range []string{"c", "a", "e", "b", "d"} {
+			taskType := "report"
+			if id == "e" {
+				taskType = "cleanup"
+			}
+			task := table.ScheduledTask{TaskID: id, OrgID: org.ID, StartDate: time.Now(), TaskType: taskType, TaskData: "{}"}
+			if err := store.CreateScheduledTask(ctx, task); err != nil {
+				t.Fatalf("%s: CreateScheduledTask() error: %v", name, err)
+			}
+		}
+
+		tests := []struct {
+			name  string
+			query db.ListQuery
+			want  []string
+			total int64
+		}{
+			{"default sort", db.ListQuery{Limit: 2}, []string{"c", "a", "e", "b", "d"}, 5},
+			{"sort", db.ListQuery{Sort: "task_id", Limit: 2}, []string{"a", "b", "c", "d", "e"}, 5},
+			{"sort descending", db.ListQuery{Sort: "-task_id", Limit: 3}, []string{"e", "d", "c", "b", "a"}, 5},
+			{"filter", db.ListQuery{Filters: map[string]string{"task_type": "report"}, Sort: "task_id", Limit: 3}, []string{"a", "b", "c", "d"}, 4},
+			{"single page", db.ListQuery{Filters: map[string]string{"task_type": "cleanup"}}, []string{"e"}, 1},
+		}
+		for _, tt := range tests {
+			got, pages := []string{}, 0
+			for q := tt.query; ; pages++ {
+				page, err := store.ListScheduledTasks(ctx, org.ID, q)
+				if err != nil || page.Total != tt.total || len(page.Items) > q.Limit && q.Limit > 0 {
+					t.Fatalf("%s: ListScheduledTasks() %s = %+v, %v, want total %d", name, tt.name, page, err, tt.total)
+				}
+				for _, task := range page.Items {
+					got = append(got, task.TaskID)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%s: ListScheduledTasks() %s = %v in %d pages, want %v", name, tt.name, got, pages+1, tt.want)
+			}
+		}
+
+		first, err := store.ListScheduledTasks(ctx, org.ID, db.ListQuery{Limit: 1})
+		if err != nil {
+			t.Fatalf("%s: ListScheduledTasks() error: %v", name, err)
+		}
+		invalid := []db.ListQuery{
+			{Filters: map[string]string{"task_data": "{}"}},
+			{Sort: "interval"},
+			{Cursor: "not a cursor"},
+			{Cursor: first.NextCursor, Sort: "task_id"},
+		}
+		for _, q := range invalid {
+			if _, err := store.ListScheduledTasks(ctx, org.ID, q); !errors.Is(err, db.ErrInvalidListQuery) {
+				t.Errorf("%s: ListScheduledTasks(%+v) error = %v, want ErrInvalidListQuery", name, q, err)
+			}
+		}
+
+		if page, err := store.ListOrgs(ctx, db.ListQuery{Filters: map[string]string{"tenant_type": table.TenantTypeOrg}}); err != nil || page.Total != 1 || page.Items[0].ID != org.ID {
+			t.Errorf("%s: ListOrgs() = %+v, %v, want org 'tasks'", name, page, err)
+		}
+
+		// cursor stays valid once its entry is purged
+		for _, q := range []db.ListQuery{{Limit: 2}, {Sort: "-task_id", Limit: 2}} {
+			page, err := store.ListScheduledTasks(ctx, org.ID, q)
+			if err != nil || len(page.Items) != 2 {
+				t.Fatalf("%s: ListScheduledTasks(%+v) = %+v, %v", name, q, page, err)
+			}
+			purged := page.Items[1]
+			if err := store.DeleteScheduledTask(ctx, purged.TaskID); err != nil {
+				t.Fatalf("%s: DeleteScheduledTask() error: %v", name, err)
+			}
+			if _, err := store.PurgeDeleted(ctx, time.Now().Add(time.Hour)); err != nil {
+				t.Fatalf("%s: PurgeDeleted() error: %v", name, err)
+			}
+			q.Cursor = page.NextCursor
+			next, err := store.ListScheduledTasks(ctx, org.ID, q)
+			if err != nil || len(next.Items) != 2 || next.Items[0].TaskID == purged.TaskID {
+				t.Errorf("%s: ListScheduledTasks(%+v) after purging '%s' = %+v, %v, want the next 2 tasks", name, q, purged.TaskID, next.Items, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gopkg.cc/apibase/baseconfig"
+	"gopkg.cc/apibase/log"
+)
+
+// Upper bounds of the latency histogram buckets of QueryStat
+var QueryLatencyBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second,
+}
+
+// statements with more distinct queries are counted as one, e.g. if own queries are built dynamically
+const queryStatsMaxStatements = 1000
+
+// Statistics of one SQL statement since the database was opened
+type QueryStat struct {
+	Query     string        `json:"query"`
+	Method    string        `json:"method"` // db.DB method of the last call, e.g. "GetUserByID"
+	Calls     int64         `json:"calls"`
+	Errors    int64         `json:"errors"` // no rows found isn't an error
+	Total     time.Duration `json:"total"`
+	Max       time.Duration `json:"max"`
+	Histogram []int64       `json:"histogram"` // calls per bucket of QueryLatencyBuckets, the last entry counts slower calls
+}
+
+// Connection pool and query statistics of DB, queries of read replicas are included
+type Stats struct {
+	Pool     *PoolStats     `json:"pool"`               // nil for SQLite
+	Replicas []ReplicaStats `json:"replicas,omitempty"` // Postgres read replicas
+	Queries  []QueryStat    `json:"queries"`            // sorted by total duration, slowest first
+}
+
+// Statistics of all queries, which are also logged if slower than BaseConfig.DatabaseSlowQuery.
+// Implements pgx.QueryTracer, SQLite queries are recorded by sqliteQuerier
+type queryTracer struct {
+	bc    *baseconfig.BaseConfig
+	mtx   sync.Mutex
+	stats map[string]*QueryStat
+}
+
+func newQueryTracer(bc *baseconfig.BaseConfig) *queryTracer {
+	return &queryTracer{bc: bc, stats: map[string]*QueryStat{}}
+}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	query string
+	start time.Time
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{data.SQL, time.Now()})
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	if start, ok := ctx.Value(queryStartKey{}).(queryStart); ok {
+		t.record(start.query, time.Since(start.start), data.Err)
+	}
+}
+
+// nil tracer doesn't record, e.g. for DB{} created without PostgresInit() or SQLiteInit()
+func (t *queryTracer) record(query string, duration time.Duration, err error) {
+	if t == nil {
+		return
+	}
+	method := callerMethod()
+	if duration >= t.bc.DatabaseSlowQuery {
+		log.Logf(log.LevelWarning, "slow database query in %s (%s): %s", method, duration, strings.Join(strings.Fields(query), " "))
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	stat, ok := t.stats[query]
+	if !ok && len(t.stats) >= queryStatsMaxStatements {
+		query = "other"
+		stat, ok = t.stats[query]
+	}
+	if !ok {
+		stat = &QueryStat{Query: query, Histogram: make([]int64, len(QueryLatencyBuckets)+1)}
+		t.stats[query] = stat
+	}
+	stat.Method = method
+	stat.Calls++
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) && !errors.Is(err, sql.ErrNoRows) {
+		stat.Errors++
+	}
+	stat.Total += duration
+	stat.Max = max(stat.Max, duration)
+	bucket, _ := slices.BinarySearch(QueryLatencyBuckets, duration)
+	stat.Histogram[bucket]++
+}
+
+var dbMethodPrefix = reflect.TypeFor[DB]().PkgPath() + ".DB."
+
+// outermost db.DB method in the call stack, the one called from outside of package db
+func callerMethod() string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	method := "unknown"
+	for {
+		frame, more := frames.Next()
+		if name, ok := strings.CutPrefix(frame.Function, dbMethodPrefix); ok {
+			method, _, _ = strings.Cut(name, ".") // without closure suffix, e.g. ".func1"
+		}
+		if !more {
+			return method
+		}
+	}
+}
+
+// Connection pool and query statistics, e.g. for monitoring endpoints
+func (db DB) Stats() Stats {
+	stats := Stats{Replicas: db.replicaStats(), Queries: []QueryStat{}}
+	if pool, ok := db.PoolStats(); ok {
+		stats.Pool = &pool
+	}
+	if db.tracer == nil {
+		return stats
+	}
+	db.tracer.mtx.Lock()
+	for _, stat := range db.tracer.stats {
+		s := *stat
+		s.Histogram = slices.Clone(stat.Histogram)
+		stats.Queries = append(stats.Queries, s)
+	}
+	db.tracer.mtx.Unlock()
+	slices.SortFunc(stats.Queries, func(a, b QueryStat) int { return cmp.Compare(b.Total, a.Total) })
+	return stats
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"gopkg.cc/apibase/errx"
+)
+
+// Transaction of DB.WithTx(), for queries on own tables together with the tx-aware methods (e.g. DB.CreateNewUserWithTenantTx()).
+// Queries use Postgres placeholders ($1, $2, ...), which are rewritten for SQLite
+type Tx interface {
+	querier // only transactions of WithTx() implement Tx
+
+	Exec(ctx context.Context, query string, args ...any) (rowsAffected int64, err error)
+	// scan the first row into dst (a struct with db tags or a single column value), ErrDatabaseNotFound if there is no row
+	Get(ctx context.Context, dst any, query string, args ...any) error
+	// scan all rows into dst, a pointer to a slice
+	Select(ctx context.Context, dst any, query string, args ...any) error
+	// Run fn in a savepoint, which is rolled back if fn returns an error, the transaction can be continued in this case
+	Savepoint(ctx context.Context, fn func(tx Tx) error) error
+}
+
+type IsolationLevel uint
+
+const (
+	IsolationDefault IsolationLevel = iota // read committed for Postgres
+	IsolationReadCommitted
+	IsolationRepeatableRead
+	IsolationSerializable
+)
+
+// Options of WithTxOptions(), SQLite transactions are always serializable and ignore them
+type TxOptions struct {
+	Isolation IsolationLevel
+	ReadOnly  bool
+}
+
+func (o TxOptions) pgx() pgx.TxOptions {
+	opts := pgx.TxOptions{}
+	switch o.Isolation {
+	case IsolationReadCommitted:
+		opts.IsoLevel = pgx.ReadCommitted
+	case IsolationRepeatableRead:
+		opts.IsoLevel = pgx.RepeatableRead
+	case IsolationSerializable:
+		opts.IsoLevel = pgx.Serializable
+	}
+	if o.ReadOnly {
+		opts.AccessMode = pgx.ReadOnly
+	}
+	return opts
+}
+
+// Run fn in a transaction, which is committed if fn returns nil and rolled back otherwise.
+// The whole transaction is retried on connection loss, serialization failure or deadlock, so fn must not have side effects
+// outside of tx and must set its results on every run. The transaction is only bound by ctx
+func (db DB) WithTx(ctx context.Context, fn func(tx Tx) error) error {
+	return db.WithTxOptions(ctx, TxOptions{}, fn)
+}
+
+// WithTx() with isolation level and access mode, e.g. IsolationSerializable for read-modify-write of own tables
+func (db DB) WithTxOptions(ctx context.Context, opts TxOptions, fn func(tx Tx) error) error {
+	return db.runTxOptions(ctx, opts, func(tx querier) error {
+		return fn(&dbTx{querier: tx, savepoints: new(int)})
+	})
+}
+
+type dbTx struct {
+	querier
+	savepoints *int // shared by nested savepoints for unique names
+}
+
+func (t *dbTx) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	rowsAffected, err := t.exec(ctx, query, args...)
+	if err != nil {
+		return rowsAffected, errx.WrapWithType(ErrDatabaseQuery, err, "")
+	}
+	return rowsAffected, nil
+}
+
+func (t *dbTx) Get(ctx context.Context, dst any, query string, args ...any) error {
+	err := t.scanOne(ctx, dst, query, args...)
+	if errors.Is(err, errNoRows) {
+		return errx.NewWithType(ErrDatabaseNotFound, "query returned no rows")
+	}
+	if err != nil {
+		return errx.WrapWithType(ErrDatabaseQuery, err, "")
+	}
+	return nil
+}
+
+func (t *dbTx) Select(ctx context.Context, dst any, query string, args ...any) error {
+	if err := t.scanAll(ctx, dst, query, args...); err != nil {
+		return errx.WrapWithType(ErrDatabaseQuery, err, "")
+	}
+	return nil
+}
+
+func (t *dbTx) Savepoint(ctx context.Context, fn func(tx Tx) error) error {
+	*t.savepoints++
+	name := fmt.Sprintf("apibase_sp_%d", *t.savepoints)
+	if _, err := t.exec(ctx, "SAVEPOINT "+name); err != nil {
+		return errx.WrapWithTypef(ErrDatabaseQuery, err, "unable to create savepoint '%s'", name)
+	}
+	if err := fn(t); err != nil {
+		if _, rollbackErr := t.exec(ctx, "ROLLBACK TO SAVEPOINT "+name); rollbackErr != nil {
+			return errx.WrapWithTypef(ErrDatabaseQuery, rollbackErr, "unable to rollback to savepoint '%s' after: %s", name, err.Error())
+		}
+		return err
+	}
+	if _, err := t.exec(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return errx.WrapWithTypef(ErrDatabaseQuery, err, "unable to release savepoint '%s'", name)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gofrs/uuid/v5"
+	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/table"
+)
+
+// Append event to the audit log, actor, ip and user agent are taken from ctx if not set (see WithAuditActor())
+func (db DB) CreateAuditEvent(ctx context.Context, event table.AuditEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	return db.createAuditEvent(ctx, db.conn(), event)
+}
+
+func (db DB) createAuditEvent(ctx context.Context, tx querier, event table.AuditEvent) error {
+	event = auditEventDefaults(ctx, event)
+	query := `INSERT INTO audit_events (id, actor_id, org_id, action, target, ip, user_agent, diff, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := tx.exec(ctx, query, event.ID, event.ActorID, event.OrgID, event.Action, event.Target, event.IP, event.UserAgent, event.Diff, event.CreatedAt)
+	if err != nil {
+		return errx.WrapWithTypef(ErrDatabaseInsert, err, "audit event '%s' could not be created", event.Action)
+	}
+	return nil
+}
+
+// audit events matching filter, newest first
+func (db DB) GetAuditEvents(ctx context.Context, filter AuditFilter) ([]table.AuditEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseLargeQuery)
+	defer cancel()
+	conditions, args := []string{"TRUE"}, []any{}
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.ActorID != uuid.Nil {
+		where("actor_id = $%d", filter.ActorID)
+	}
+	if len(filter.OrgIDs) > 0 {
+		placeholders := []string{}
+		for _, orgID := range filter.OrgIDs {
+			args = append(args, orgID)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+		conditions = append(conditions, "org_id IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if !filter.From.IsZero() {
+		where("created_at >= $%d", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		where("created_at < $%d", filter.To.UTC())
+	}
+	args = append(args, filter.limit())
+	query := fmt.Sprintf("SELECT * FROM audit_events WHERE %s ORDER BY created_at DESC, id DESC LIMIT $%d", strings.Join(conditions, " AND "), len(args))
+	events := []table.AuditEvent{}
+	if err := db.read(ctx).scanAll(ctx, &events, query, args...); err != nil {
+		return events, errx.WrapWithType(ErrDatabaseQuery, err, "")
+	}
+	return events, nil
+}
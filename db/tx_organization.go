@@ -2,23 +2,197 @@ package db
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
-	"github.com/georgysavva/scany/v2/pgxscan"
-	"github.com/jackc/pgx/v5"
+	"github.com/gofrs/uuid/v5"
 	"gopkg.cc/apibase/errx"
 	"gopkg.cc/apibase/table"
 )
 
-func (db DB) createOrg(org table.Organization, tx pgx.Tx, ctx context.Context) (table.Organization, error) {
+func (db DB) CreateOrg(ctx context.Context, org table.Organization) (table.Organization, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	return db.createOrg(org, db.conn(), ctx)
+}
+
+func (db DB) GetOrgByName(ctx context.Context, name string) (table.Organization, error) {
+	return db.getOrg(ctx, "name = $1", "name '"+name+"'", name)
+}
+
+func (db DB) GetOrgBySlug(ctx context.Context, slug string) (table.Organization, error) {
+	return db.getOrg(ctx, "slug = $1", "slug '"+slug+"'", slug)
+}
+
+// organization of tenantType with the id of the tenant in another system
+func (db DB) GetOrgByExternalID(ctx context.Context, tenantType string, externalID string) (table.Organization, error) {
+	if externalID == "" {
+		return table.Organization{}, errx.NewWithType(ErrDatabaseNotFound, "no organization found for empty external id")
+	}
+	return db.getOrg(ctx, "tenant_type = $1 AND external_id = $2", fmt.Sprintf("%s with external id '%s'", tenantType, externalID), tenantType, externalID)
+}
+
+var orgListSpec = listSpec{
+	table:       "organizations",
+	filters:     map[string]func(string) (any, error){"name": filterString, "tenant_type": filterString, "slug": filterString, "external_id": filterString},
+	sorts:       []string{"name", "slug", "id"},
+	defaultSort: "name",
+}
+
+// page of organizations, filters: name, tenant_type, slug, external_id. Sort: name (default), slug, id (creation order)
+func (db DB) ListOrgs(ctx context.Context, q ListQuery) (ListPage[table.Organization], error) {
+	return list[table.Organization](ctx, db, orgListSpec, q, []string{"deleted_at IS NULL"})
+}
+
+func (db DB) getOrg(ctx context.Context, condition string, description string, args ...any) (table.Organization, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	org := table.Organization{}
+	err := db.read(ctx).scanOne(ctx, &org, "SELECT * FROM organizations WHERE "+condition+" AND deleted_at IS NULL", args...)
+	if errors.Is(err, errNoRows) {
+		return org, errx.NewWithTypef(ErrDatabaseNotFound, "no organization found for %s", description)
+	}
+	if err != nil {
+		return org, errx.WrapWithType(ErrDatabaseQuery, err, "")
+	}
+	return org, nil
+}
+
+// CreateOrg() in the transaction tx
+func (db DB) CreateOrgTx(ctx context.Context, tx Tx, org table.Organization) (table.Organization, error) {
+	return db.createOrg(org, tx, ctx)
+}
+
+func (db DB) createOrg(org table.Organization, tx querier, ctx context.Context) (table.Organization, error) {
 	createdOrg := table.Organization{}
-	query := "INSERT INTO organizations (name, description) VALUES ($1, $2) RETURNING id, name, description"
-	rows, err := tx.Query(ctx, query, org.Name, org.Description)
+	generatedSlug := org.Slug == ""
+	org = tenantDefaults(org, table.NewID())
+	// a taken slug doesn't abort the transaction, so a generated slug can be retried with suffix
+	query := `INSERT INTO organizations (id, name, description, tenant_type, slug, external_id, metadata) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (slug) WHERE deleted_at IS NULL DO NOTHING
+		RETURNING id, name, description, tenant_type, slug, external_id, metadata`
+	err := tx.scanOne(ctx, &createdOrg, query, org.ID, org.Name, org.Description, org.TenantType, org.Slug, org.ExternalID, org.Metadata)
+	if errors.Is(err, errNoRows) && generatedSlug {
+		org.Slug = slugWithSuffix(org.Slug, org.ID)
+		err = tx.scanOne(ctx, &createdOrg, query, org.ID, org.Name, org.Description, org.TenantType, org.Slug, org.ExternalID, org.Metadata)
+	}
+	if errors.Is(err, errNoRows) {
+		return createdOrg, errx.NewWithTypef(ErrOrgSlugExists, "organization '%s' could not be created with slug '%s'", org.Name, org.Slug)
+	}
 	if err != nil {
 		return createdOrg, errx.WrapWithTypef(ErrDatabaseInsert, err, "organization '%s' could not be created", org.Name)
 	}
-	err = pgxscan.ScanOne(&createdOrg, rows)
+	return createdOrg, nil
+}
+
+// default organization created for a new user, if no other tenant is given
+func defaultUserTenant(user table.User) table.Organization {
+	return table.Organization{
+		Name:        fmt.Sprintf("userorg-%s", user.Name),
+		Description: fmt.Sprintf("Default org for user '%s'", user.Name),
+		TenantType:  table.TenantTypeUser,
+	}
+}
+
+// set id and fill empty type, slug and metadata of new organization
+func tenantDefaults(org table.Organization, id uuid.UUID) table.Organization {
+	org.ID = id
+	if org.TenantType == "" {
+		org.TenantType = table.TenantTypeOrg
+	}
+	if org.Slug == "" {
+		org.Slug = slugify(org.Name)
+	}
+	if org.Slug == "" {
+		org.Slug = id.String()
+	}
+	if org.Metadata == "" {
+		org.Metadata = "{}"
+	}
+	return org
+}
+
+// slug made unique by the last 8 characters of id, e.g. "acme-inc-1f3a9c2e"
+func slugWithSuffix(slug string, id uuid.UUID) string {
+	suffix := id.String()
+	return slug + "-" + suffix[len(suffix)-8:]
+}
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// lower case, other characters than a-z and 0-9 are replaced by '-', e.g. "Acme Inc." -> "acme-inc"
+func slugify(name string) string {
+	return strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// soft delete organization together with its roles and scheduled tasks, it can be restored with RestoreOrg until it is purged.
+// Scheduled tasks of the organization keep running until they are removed with cron.Remove()
+func (db DB) DeleteOrg(ctx context.Context, orgID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	return db.runTx(ctx, func(tx querier) error {
+		now := time.Now().UTC()
+		rowsAffected, err := tx.exec(ctx, "UPDATE organizations SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL", now, orgID)
+		if err != nil {
+			return errx.WrapWithTypef(ErrDatabaseDelete, err, "unable to delete organization (id: %s)", orgID)
+		}
+		if rowsAffected != 1 {
+			return errx.NewWithTypef(ErrDatabaseNotFound, "no organization found for id '%s'", orgID)
+		}
+		if _, err := tx.exec(ctx, "UPDATE user_roles SET deleted_at = $1 WHERE org_id = $2 AND deleted_at IS NULL", now, orgID); err != nil {
+			return errx.WrapWithTypef(ErrDatabaseDelete, err, "unable to delete roles of organization (id: %s)", orgID)
+		}
+		if _, err := tx.exec(ctx, "UPDATE scheduled_tasks SET deleted_at = $1 WHERE org_id = $2 AND deleted_at IS NULL", now, orgID); err != nil {
+			return errx.WrapWithTypef(ErrDatabaseDelete, err, "unable to delete scheduled tasks of organization (id: %s)", orgID)
+		}
+		return nil
+	})
+}
+
+// restore deleted organization and the roles and scheduled tasks deleted together with it,
+// except roles of deleted users and tasks whose id was reused in the meantime
+func (db DB) RestoreOrg(ctx context.Context, orgID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	return db.runTx(ctx, func(tx querier) error {
+		org := table.Organization{}
+		err := tx.scanOne(ctx, &org, "SELECT * FROM organizations WHERE id = $1 AND deleted_at IS NOT NULL", orgID)
+		if errors.Is(err, errNoRows) {
+			return errx.NewWithTypef(ErrDatabaseNotFound, "no deleted organization found for id '%s'", orgID)
+		}
+		if err != nil {
+			return errx.WrapWithType(ErrDatabaseQuery, err, "")
+		}
+		query := `UPDATE user_roles SET deleted_at = NULL WHERE org_id = $1
+			AND deleted_at = (SELECT deleted_at FROM organizations WHERE id = $1)
+			AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)`
+		if _, err := tx.exec(ctx, query, orgID); err != nil {
+			return errx.WrapWithTypef(ErrDatabaseRestore, err, "unable to restore roles of organization (id: %s)", orgID)
+		}
+		query = `UPDATE scheduled_tasks SET deleted_at = NULL WHERE org_id = $1
+			AND deleted_at = (SELECT deleted_at FROM organizations WHERE id = $1)
+			AND task_id NOT IN (SELECT task_id FROM scheduled_tasks WHERE deleted_at IS NULL)`
+		if _, err := tx.exec(ctx, query, orgID); err != nil {
+			return errx.WrapWithTypef(ErrDatabaseRestore, err, "unable to restore scheduled tasks of organization (id: %s)", orgID)
+		}
+		if _, err := tx.exec(ctx, "UPDATE organizations SET deleted_at = NULL WHERE id = $1", orgID); err != nil {
+			return errx.WrapWithTypef(ErrDatabaseRestore, err, "organization '%s' already exists", org.Name)
+		}
+		return nil
+	})
+}
+
+// all deleted organizations that weren't purged yet, last deleted first
+func (db DB) GetDeletedOrgs(ctx context.Context) ([]table.Organization, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	orgs := []table.Organization{}
+	err := db.conn().scanAll(ctx, &orgs, "SELECT * FROM organizations WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
 	if err != nil {
-		return createdOrg, errx.WrapWithType(ErrDatabaseScan, err, "")
+		return orgs, errx.WrapWithType(ErrDatabaseQuery, err, "")
 	}
-	return createdOrg, nil
+	return orgs, nil
 }
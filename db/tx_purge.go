@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"gopkg.cc/apibase/errx"
+)
+
+// referencing tables first, roles and tasks are purged together with their user or organization
+var purgeQueries = []string{
+	"DELETE FROM scheduled_tasks WHERE deleted_at < $1 OR org_id IN (SELECT id FROM organizations WHERE deleted_at < $1)",
+	`DELETE FROM user_roles WHERE deleted_at < $1
+		OR user_id IN (SELECT id FROM users WHERE deleted_at < $1)
+		OR org_id IN (SELECT id FROM organizations WHERE deleted_at < $1)`,
+	"DELETE FROM refresh_tokens WHERE user_id IN (SELECT id FROM users WHERE deleted_at < $1)",
+	"DELETE FROM users WHERE deleted_at < $1",
+	"DELETE FROM organizations WHERE deleted_at < $1",
+}
+
+// Permanently delete all entries that were soft deleted before deletedBefore, returns the number of purged rows.
+// Own tables referencing users or organizations must delete their rows first (e.g. ON DELETE CASCADE), otherwise purging fails
+func (db DB) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseLargeQuery)
+	defer cancel()
+	var purged int64
+	err := db.runTx(ctx, func(tx querier) error {
+		purged = 0
+		for _, query := range purgeQueries {
+			rowsAffected, err := tx.exec(ctx, query, deletedBefore.UTC())
+			if err != nil {
+				return errx.WrapWithType(ErrDatabaseDelete, err, "unable to purge deleted entries")
+			}
+			purged += rowsAffected
+		}
+		return nil
+	})
+	return purged, err
+}
@@ -5,41 +5,36 @@ import (
 	"errors"
 	"time"
 
-	"github.com/georgysavva/scany/v2/pgxscan"
-	"github.com/jackc/pgx/v5"
+	"github.com/gofrs/uuid/v5"
 	"gopkg.cc/apibase/errx"
 	"gopkg.cc/apibase/log"
 	"gopkg.cc/apibase/table"
 )
 
-func (db DB) GetScheduledTask(taskId string) (table.ScheduledTask, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+func (db DB) GetScheduledTask(ctx context.Context, taskId string) (table.ScheduledTask, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
 	task := table.ScheduledTask{}
-	rows, err := db.Postgres.Query(ctx, "SELECT * FROM scheduled_tasks WHERE task_id = $1", taskId)
-	if err != nil {
-		return task, errx.WrapWithType(ErrDatabaseQuery, err, "")
-	}
-	err = pgxscan.ScanOne(&task, rows)
-	if errors.Is(err, pgx.ErrNoRows) {
+	err := db.read(ctx).scanOne(ctx, &task, "SELECT * FROM scheduled_tasks WHERE task_id = $1 AND deleted_at IS NULL", taskId)
+	if errors.Is(err, errNoRows) {
 		return task, errx.NewWithTypef(ErrDatabaseNotFound, "no task found with id '%s'", taskId)
 	}
 	if err != nil {
-		return task, errx.WrapWithType(ErrDatabaseScan, err, "")
+		return task, errx.WrapWithType(ErrDatabaseQuery, err, "")
 	}
 	return task, nil
 }
 
-func (db DB) GetScheduledTasks(userId int) ([]table.ScheduledTask, error) {
+func (db DB) GetScheduledTasks(ctx context.Context, userId uuid.UUID) ([]table.ScheduledTask, error) {
 	tasks := []table.ScheduledTask{}
-	roles, err := db.GetUserRoles(userId)
+	roles, err := db.GetUserRoles(ctx, userId)
 	if err != nil {
 		return tasks, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
 
-	var noViewPermsForOrg []int
+	var noViewPermsForOrg []uuid.UUID
 	for _, role := range roles {
 		if !role.OrgView {
 			noViewPermsForOrg = append(noViewPermsForOrg, role.OrgID)
@@ -47,7 +42,7 @@ func (db DB) GetScheduledTasks(userId int) ([]table.ScheduledTask, error) {
 		}
 		orgTasks, err := db.getScheduledTasksForOrg(role.OrgID, ctx)
 		if err != nil {
-			log.Logf(log.LevelError, "unable to get tasks for org (id: %d): %s", role.OrgID, err.Error())
+			log.Logf(log.LevelError, "unable to get tasks for org (id: %s): %s", role.OrgID, err.Error())
 			continue
 		}
 		tasks = append(tasks, orgTasks...)
@@ -59,72 +54,125 @@ func (db DB) GetScheduledTasks(userId int) ([]table.ScheduledTask, error) {
 	return tasks, nil
 }
 
-func (db DB) getScheduledTasksForOrg(orgId int, ctx context.Context) ([]table.ScheduledTask, error) {
+func (db DB) getScheduledTasksForOrg(orgId uuid.UUID, ctx context.Context) ([]table.ScheduledTask, error) {
 	tasks := []table.ScheduledTask{}
-	query := "SELECT * FROM scheduled_tasks WHERE org_id = $1"
-	rows, err := db.Postgres.Query(ctx, query, orgId)
-	if err != nil {
-		return tasks, errx.WrapWithTypef(ErrDatabaseQuery, err, "")
-	}
-	err = pgxscan.ScanAll(&tasks, rows)
+	query := "SELECT * FROM scheduled_tasks WHERE org_id = $1 AND deleted_at IS NULL"
+	err := db.read(ctx).scanAll(ctx, &tasks, query, orgId)
 	if err != nil {
-		return tasks, errx.WrapWithTypef(ErrDatabaseScan, err, "")
+		return tasks, errx.WrapWithType(ErrDatabaseQuery, err, "")
 	}
 	// if no tasks are found, the empty array is returned
 	return tasks, nil
 }
 
-func (db DB) GetAllScheduledTasks() ([]table.ScheduledTask, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+var scheduledTaskListSpec = listSpec{
+	table:       "scheduled_tasks",
+	filters:     map[string]func(string) (any, error){"task_id": filterString, "task_type": filterString},
+	sorts:       []string{"created_at", "updated_at", "start_date", "task_id"},
+	defaultSort: "created_at",
+}
+
+// page of the tasks of an organization, filters: task_id, task_type. Sort: created_at (default), updated_at, start_date, task_id
+func (db DB) ListScheduledTasks(ctx context.Context, orgID uuid.UUID, q ListQuery) (ListPage[table.ScheduledTask], error) {
+	return list[table.ScheduledTask](ctx, db, scheduledTaskListSpec, q, []string{"org_id = $1", "deleted_at IS NULL"}, orgID)
+}
+
+func (db DB) GetAllScheduledTasks(ctx context.Context) ([]table.ScheduledTask, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
 	tasks := []table.ScheduledTask{}
-	rows, err := db.Postgres.Query(ctx, "SELECT * FROM scheduled_tasks")
+	err := db.conn().scanAll(ctx, &tasks, "SELECT * FROM scheduled_tasks WHERE deleted_at IS NULL")
 	if err != nil {
 		return tasks, errx.WrapWithType(ErrDatabaseQuery, err, "")
 	}
-	err = pgxscan.ScanAll(&tasks, rows)
-	if err != nil {
-		return tasks, errx.WrapWithType(ErrDatabaseScan, err, "")
-	}
 	if len(tasks) < 1 {
 		return tasks, errx.NewWithType(ErrDatabaseNotFound, "no tasks found")
 	}
 	return tasks, nil
 }
 
-func (db DB) CreateScheduledTask(task table.ScheduledTask) error {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+func (db DB) CreateScheduledTask(ctx context.Context, task table.ScheduledTask) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
-	query := "INSERT INTO scheduled_tasks (task_id, org_id, start_date, interval, task_type, task_data) VALUES ($1, $2, $3, $4, $5, $6)"
-	_, err := db.Postgres.Exec(ctx, query, task.TaskID, task.OrgID, task.StartDate, task.Interval, task.TaskType, task.TaskData)
-	if err != nil {
-		return errx.WrapWithType(ErrDatabaseInsert, err, "scheduled task entry could not be created")
-	}
-	return nil
+	return db.runTx(ctx, func(tx querier) error {
+		query := "INSERT INTO scheduled_tasks (id, task_id, org_id, start_date, interval, task_type, task_data) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+		_, err := tx.exec(ctx, query, table.NewID(), task.TaskID, task.OrgID, task.StartDate, task.Interval, task.TaskType, task.TaskData)
+		if err != nil {
+			return errx.WrapWithType(ErrDatabaseInsert, err, "scheduled task entry could not be created")
+		}
+		return db.createAuditEvent(ctx, tx, taskEvent(table.AuditTaskCreate, nil, &task))
+	})
 }
 
-func (db DB) DeleteScheduledTask(taskId string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+// soft delete, the task can be restored with RestoreScheduledTask until it is purged
+func (db DB) DeleteScheduledTask(ctx context.Context, taskId string) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
+	return db.runTx(ctx, func(tx querier) error {
+		task := table.ScheduledTask{}
+		query := "UPDATE scheduled_tasks SET deleted_at = $1 WHERE task_id = $2 AND deleted_at IS NULL RETURNING *"
+		err := tx.scanOne(ctx, &task, query, time.Now().UTC(), taskId)
+		if errors.Is(err, errNoRows) {
+			return errx.NewWithTypef(ErrDatabaseDelete, "no scheduled task found with id '%s'", taskId)
+		}
+		if err != nil {
+			return errx.WrapWithTypef(ErrDatabaseDelete, err, "scheduled task with id '%s'", taskId)
+		}
+		return db.createAuditEvent(ctx, tx, taskEvent(table.AuditTaskDelete, &task, nil))
+	})
+}
 
-	query := "DELETE FROM scheduled_tasks WHERE task_id = $1"
-	res, err := db.Postgres.Exec(ctx, query, taskId)
-	if err != nil {
-		return errx.WrapWithTypef(ErrDatabaseDelete, err, "scheduled task token entry (rows affected: %d)", res.RowsAffected())
-	}
-	if res.RowsAffected() != 1 {
-		return errx.NewWithTypef(ErrDatabaseDelete, "scheduled task rows affected != 1 (instead got %d)", res.RowsAffected())
-	}
-	return nil
+// restore the last deleted task with taskId, the organization of the task must not be deleted.
+// The restored task isn't scheduled, use cron.Schedule()
+func (db DB) RestoreScheduledTask(ctx context.Context, taskId string) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	return db.runTx(ctx, func(tx querier) error {
+		task := table.ScheduledTask{}
+		query := `SELECT t.* FROM scheduled_tasks t JOIN organizations o ON o.id = t.org_id
+			WHERE t.task_id = $1 AND t.deleted_at IS NOT NULL AND o.deleted_at IS NULL ORDER BY t.deleted_at DESC LIMIT 1`
+		err := tx.scanOne(ctx, &task, query, taskId)
+		if errors.Is(err, errNoRows) {
+			return errx.NewWithTypef(ErrDatabaseNotFound, "no deleted task found with id '%s'", taskId)
+		}
+		if err != nil {
+			return errx.WrapWithType(ErrDatabaseQuery, err, "")
+		}
+		_, err = tx.exec(ctx, "UPDATE scheduled_tasks SET (deleted_at, updated_at) = (NULL, $1) WHERE id = $2", time.Now(), task.ID)
+		if err != nil {
+			return errx.WrapWithTypef(ErrDatabaseRestore, err, "task with id '%s' already exists", taskId)
+		}
+		return nil
+	})
 }
 
-func (db DB) UpdateScheduledTask(task table.ScheduledTask) error {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+func (db DB) UpdateScheduledTask(ctx context.Context, task table.ScheduledTask) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
-	query := "UPDATE scheduled_tasks SET (org_id, start_date, interval, task_type, task_data, updated_at) = ($1, $2, $3, $4, $5, $6) WHERE task_id = $7"
-	_, err := db.Postgres.Exec(ctx, query, task.OrgID, task.StartDate, task.Interval, task.TaskType, task.TaskData, time.Now(), task.TaskID)
-	if err != nil {
-		return errx.WrapWithType(ErrDatabaseUpdate, err, "scheduled task could not be updated")
+	return db.runTx(ctx, func(tx querier) error {
+		old := table.ScheduledTask{}
+		err := tx.scanOne(ctx, &old, "SELECT * FROM scheduled_tasks WHERE task_id = $1 AND deleted_at IS NULL", task.TaskID)
+		if errors.Is(err, errNoRows) {
+			// nothing to update
+			return nil
+		}
+		if err != nil {
+			return errx.WrapWithType(ErrDatabaseQuery, err, "")
+		}
+		query := "UPDATE scheduled_tasks SET (org_id, start_date, interval, task_type, task_data, updated_at) = ($1, $2, $3, $4, $5, $6) WHERE id = $7"
+		_, err = tx.exec(ctx, query, task.OrgID, task.StartDate, task.Interval, task.TaskType, task.TaskData, time.Now(), old.ID)
+		if err != nil {
+			return errx.WrapWithType(ErrDatabaseUpdate, err, "scheduled task could not be updated")
+		}
+		return db.createAuditEvent(ctx, tx, taskEvent(table.AuditTaskUpdate, &old, &task))
+	})
+}
+
+// old is nil for created and new for deleted tasks
+func taskEvent(action string, old *table.ScheduledTask, new *table.ScheduledTask) table.AuditEvent {
+	task := new
+	if task == nil {
+		task = old
 	}
-	return nil
+	return table.AuditEvent{OrgID: &task.OrgID, Action: action, Target: "task:" + task.TaskID, Diff: AuditDiff(old, new)}
 }
@@ -0,0 +1,81 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/table"
+)
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+	database := newStores(t)["sqlite"].(db.DB)
+	if _, err := database.SQLite.DB.Exec("CREATE TABLE profiles (user_id TEXT NOT NULL REFERENCES users (id), bio TEXT NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	errProfile := errors.New("profile failed")
+
+	tests := []struct {
+		name    string
+		email   string
+		profile func(ctx context.Context, tx db.Tx, user table.User) error
+		wantErr error
+	}{
+		{"committed", "alice@example.com", func(ctx context.Context, tx db.Tx, user table.User) error {
+			_, err := tx.Exec(ctx, "INSERT INTO profiles (user_id, bio) VALUES ($1, $2)", user.ID, "hello")
+			return err
+		}, nil},
+		{"rolled back", "bob@example.com", func(ctx context.Context, tx db.Tx, user table.User) error {
+			return errProfile
+		}, errProfile},
+		{"savepoint rolled back", "carol@example.com", func(ctx context.Context, tx db.Tx, user table.User) error {
+			err := tx.Savepoint(ctx, func(tx db.Tx) error {
+				if _, err := tx.Exec(ctx, "INSERT INTO profiles (user_id, bio) VALUES ($1, $2)", user.ID, "first"); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, "INSERT INTO profiles (user_id, bio) VALUES ($1, NULL)", user.ID)
+				return err
+			})
+			if !errors.Is(err, db.ErrDatabaseQuery) {
+				return errors.New("savepoint didn't fail")
+			}
+			_, err = tx.Exec(ctx, "INSERT INTO profiles (user_id, bio) VALUES ($1, $2)", user.ID, "second")
+			return err
+		}, nil},
+	}
+	for _, tt := range tests {
+		err := database.WithTx(ctx, func(tx db.Tx) error {
+			user, err := database.CreateNewUserWithTenantTx(ctx, tx, table.User{Name: tt.email, AuthProvider: "local", Email: tt.email, SecretsVersion: 1}, table.Organization{})
+			if err != nil {
+				return err
+			}
+			return tt.profile(ctx, tx, user)
+		})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: WithTx() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		user, err := database.GetUserByEmail(ctx, tt.email)
+		if (err == nil) != (tt.wantErr == nil) {
+			t.Errorf("%s: GetUserByEmail() after WithTx() error = %v", tt.name, err)
+		}
+		if tt.wantErr == nil {
+			bios := []string{}
+			err := database.WithTxOptions(ctx, db.TxOptions{ReadOnly: true}, func(tx db.Tx) error {
+				return tx.Select(ctx, &bios, "SELECT bio FROM profiles WHERE user_id = $1", user.ID)
+			})
+			if err != nil || len(bios) != 1 {
+				t.Errorf("%s: profiles = %v, %v, want one", tt.name, bios, err)
+			}
+		}
+	}
+
+	err := database.WithTx(ctx, func(tx db.Tx) error {
+		var bio string
+		return tx.Get(ctx, &bio, "SELECT bio FROM profiles WHERE bio = $1", "missing")
+	})
+	if !errors.Is(err, db.ErrDatabaseNotFound) {
+		t.Errorf("Get() of missing row error = %v, want ErrDatabaseNotFound", err)
+	}
+}
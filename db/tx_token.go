@@ -3,114 +3,119 @@ package db
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
-	"github.com/georgysavva/scany/v2/pgxscan"
-	"github.com/jackc/pgx/v5"
+	"github.com/gofrs/uuid/v5"
 	"gopkg.cc/apibase/errx"
 	h "gopkg.cc/apibase/helper"
 	"gopkg.cc/apibase/table"
 )
 
-func (db DB) DeleteRefreshToken(userID int, sessionId h.SecretString) error {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+func (db DB) DeleteRefreshToken(ctx context.Context, userID uuid.UUID, sessionId h.SecretString) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
 
 	query := "DELETE FROM refresh_tokens WHERE user_id = $1 AND session_id = $2"
-	res, err := db.Postgres.Exec(ctx, query, userID, sessionId)
+	rowsAffected, err := db.conn().exec(ctx, query, userID, sessionId)
 	if err != nil {
-		return errx.WrapWithTypef(ErrDatabaseDelete, err, "refresh token entry (rows affected: %d)", res.RowsAffected())
+		return errx.WrapWithTypef(ErrDatabaseDelete, err, "refresh token entry (rows affected: %d)", rowsAffected)
 	}
-	if res.RowsAffected() != 1 {
-		return errx.NewWithTypef(ErrDatabaseDelete, "refresh token rows affected != 1 (instead got %d)", res.RowsAffected())
+	if rowsAffected != 1 {
+		return errx.NewWithTypef(ErrDatabaseDelete, "refresh token rows affected != 1 (instead got %d)", rowsAffected)
 	}
 	return nil
 }
 
-func (db DB) VerifyRefreshTokenSessionId(userID int, sessionId h.SecretString) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+func (db DB) VerifyRefreshTokenSessionId(ctx context.Context, userID uuid.UUID, sessionId h.SecretString) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
-	tx, err := db.Postgres.Begin(ctx)
-	if err != nil {
-		return false, errx.WrapWithType(ErrDatabaseQuery, err, "unable to start db transaction: %s")
-	}
-	_, err = db.getTokenByUserIdAndSessionId(ctx, tx, userID, sessionId)
-	if e, ok := err.(*errx.BaseError); ok {
-		if e.Is(ErrDatabaseNotFound) {
-			return false, nil
-		}
+	_, err := db.getTokenByUserIdAndSessionId(ctx, db.conn(), userID, sessionId)
+	if errors.Is(err, ErrDatabaseNotFound) {
+		return false, nil
 	}
 	if err != nil {
 		return false, err
 	}
-	err = tx.Commit(ctx)
-	if err != nil {
-		return false, errx.NewWithType(ErrDatabaseCommit, err.Error())
-	}
 	return true, nil
 }
 
-func (db DB) UpdateRefreshTokenEntry(userId int, sessionId h.SecretString, newSessionId h.SecretString, userAgent string, expiresAt time.Time) error {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+func (db DB) UpdateRefreshTokenEntry(ctx context.Context, userId uuid.UUID, sessionId h.SecretString, newSessionId h.SecretString, userAgent string, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
-	tx, err := db.Postgres.Begin(ctx)
-	if err != nil {
-		return errx.WrapWithType(ErrDatabaseQuery, err, "unable to start db transaction")
-	}
-	defer tx.Rollback(context.Background())
-
-	token, err := db.getTokenByUserIdAndSessionId(ctx, tx, userId, sessionId)
-	if err != nil {
-		return errx.Wrap(err, "unable to get existing entry for token")
-	}
-	token.SessionID = newSessionId
-	token.UserAgent = userAgent
-	token.ExpiresAt = expiresAt
-	err = db.updateToken(ctx, tx, token)
-	if err != nil {
-		return errx.Wrap(err, "unable to update refresh token entry")
-	}
-
-	err = tx.Commit(ctx)
-	if err != nil {
-		return errx.WrapWithType(ErrDatabaseCommit, err, "")
-	}
-	return nil
-
+	return db.runTx(ctx, func(tx querier) error {
+		token, err := db.getTokenByUserIdAndSessionId(ctx, tx, userId, sessionId)
+		if err != nil {
+			return errx.Wrap(err, "unable to get existing entry for token")
+		}
+		token.SessionID = newSessionId
+		token.UserAgent = userAgent
+		token.ExpiresAt = expiresAt
+		err = db.updateToken(ctx, tx, token)
+		if err != nil {
+			return errx.Wrap(err, "unable to update refresh token entry")
+		}
+		return nil
+	})
 }
 
-func (db DB) CreateRefreshTokenEntry(token table.RefreshToken) error {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+func (db DB) CreateRefreshTokenEntry(ctx context.Context, token table.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
-	query := "INSERT INTO refresh_tokens (user_id, session_id, reissue_count, user_agent, expires_at) VALUES ($1, $2, $3, $4, $5)"
-	_, err := db.Postgres.Exec(ctx, query, token.UserID, token.SessionID, token.ReissueCount, token.UserAgent, token.ExpiresAt)
+	query := "INSERT INTO refresh_tokens (id, user_id, session_id, reissue_count, user_agent, expires_at) VALUES ($1, $2, $3, $4, $5, $6)"
+	_, err := db.conn().exec(ctx, query, table.NewID(), token.UserID, token.SessionID, token.ReissueCount, token.UserAgent, token.ExpiresAt)
 	if err != nil {
 		return errx.WrapWithType(ErrDatabaseInsert, err, "refresh token entry for user could not be created")
 	}
 	return nil
 }
 
-func (db DB) getTokenByUserIdAndSessionId(ctx context.Context, tx pgx.Tx, userID int, sessionId h.SecretString) (table.RefreshToken, error) {
+func (db DB) getTokenByUserIdAndSessionId(ctx context.Context, tx querier, userID uuid.UUID, sessionId h.SecretString) (table.RefreshToken, error) {
 	token := table.RefreshToken{}
-	rows, err := tx.Query(ctx, "SELECT * FROM refresh_tokens WHERE user_id = $1 AND session_id = $2", userID, sessionId)
-	if err != nil {
-		return token, errx.WrapWithType(ErrDatabaseQuery, err, "")
-	}
-	err = pgxscan.ScanOne(&token, rows)
-	if errors.Is(err, pgx.ErrNoRows) {
+	err := tx.scanOne(ctx, &token, "SELECT * FROM refresh_tokens WHERE user_id = $1 AND session_id = $2", userID, sessionId)
+	if errors.Is(err, errNoRows) {
 		return token, errx.NewWithType(ErrDatabaseNotFound, "no refresh token found")
 	}
 	if err != nil {
-		return token, errx.WrapWithType(ErrDatabaseScan, err, "")
+		return token, errx.WrapWithType(ErrDatabaseQuery, err, "")
 	}
 	return token, nil
 }
 
-func (db DB) updateToken(ctx context.Context, tx pgx.Tx, token table.RefreshToken) error {
+func (db DB) updateToken(ctx context.Context, tx querier, token table.RefreshToken) error {
 	query := "UPDATE refresh_tokens SET (session_id, reissue_count, user_agent, updated_at, expires_at) = ($1, $2, $3, $4, $5) WHERE id = $6"
-	_, err := tx.Exec(ctx, query, token.SessionID, token.ReissueCount+1, token.UserAgent, token.UpdatedAt, token.ExpiresAt, token.ID)
+	_, err := tx.exec(ctx, query, token.SessionID, token.ReissueCount+1, token.UserAgent, token.UpdatedAt, token.ExpiresAt, token.ID)
 	if err != nil {
-		return errx.NewWithTypef(ErrDatabaseUpdate, "unable to update refresh token")
+		return errx.WrapWithType(ErrDatabaseUpdate, err, "unable to update refresh token")
 	}
 	return nil
 }
+
+// Revoke all sessions of the user, returns the number of revoked sessions
+func (db DB) DeleteRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	var revoked int64
+	err := db.runTx(ctx, func(tx querier) error {
+		var err error
+		revoked, err = db.revokeSessions(ctx, tx, userID)
+		return err
+	})
+	return revoked, err
+}
+
+// delete all refresh tokens of the user, records the table.AuditSessionsRevoke event if any session was revoked
+func (db DB) revokeSessions(ctx context.Context, tx querier, userID uuid.UUID) (int64, error) {
+	rowsAffected, err := tx.exec(ctx, "DELETE FROM refresh_tokens WHERE user_id = $1", userID)
+	if err != nil {
+		return rowsAffected, errx.WrapWithTypef(ErrDatabaseDelete, err, "unable to revoke sessions of user (id: %s)", userID)
+	}
+	if rowsAffected < 1 {
+		return 0, nil
+	}
+	return rowsAffected, db.createAuditEvent(ctx, tx, sessionsRevokeEvent(userID, rowsAffected))
+}
+
+func sessionsRevokeEvent(userID uuid.UUID, sessions int64) table.AuditEvent {
+	return table.AuditEvent{Action: table.AuditSessionsRevoke, Target: "user:" + userID.String(), Diff: fmt.Sprintf(`{"sessions":{"old":%d,"new":0}}`, sessions)}
+}
@@ -3,50 +3,65 @@ package db
 import (
 	"context"
 	"errors"
-	"fmt"
-
-	"github.com/georgysavva/scany/v2/pgxscan"
-	"github.com/jackc/pgx/v5"
+	"time"
 
+	"github.com/gofrs/uuid/v5"
 	"gopkg.cc/apibase/errx"
+	h "gopkg.cc/apibase/helper"
 	"gopkg.cc/apibase/log"
 	"gopkg.cc/apibase/table"
 )
 
 // If no roles are provided, a new organization is created for the user with admin role for user,
 // otherwise CreateUserIfNotExist is run
-func (db DB) CreateNewUserWithOrg(user table.User, roles ...table.UserRole) (table.User, error) {
+func (db DB) CreateNewUserWithOrg(ctx context.Context, user table.User, roles ...table.UserRole) (table.User, error) {
 	if len(roles) > 0 {
 		// Don't create new org for user, instead assign user defined roles
-		return db.CreateUserIfNotExist(user, roles...)
+		return db.CreateUserIfNotExist(ctx, user, roles...)
 	}
+	return db.CreateNewUserWithTenant(ctx, user, table.Organization{})
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+// Create user together with a new tenant, the user gets the admin role for it.
+// If tenant has no name, the default organization "userorg-<user name>" of type table.TenantTypeUser is created
+func (db DB) CreateNewUserWithTenant(ctx context.Context, user table.User, tenant table.Organization) (table.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
-	tx, err := db.Postgres.Begin(ctx)
+	userFromDB := user
+	err := db.runTx(ctx, func(tx querier) error {
+		var err error
+		userFromDB, err = db.createNewUserWithTenant(user, tenant, tx, ctx)
+		return err
+	})
 	if err != nil {
-		return user, errx.WrapWithType(ErrDatabaseQuery, err, "unable to start db transaction")
+		return user, err
 	}
-	defer tx.Rollback(context.Background())
+	return userFromDB, nil
+}
+
+// CreateNewUserWithTenant() in the transaction tx, e.g. to create an own profile row for the user in the same transaction
+func (db DB) CreateNewUserWithTenantTx(ctx context.Context, tx Tx, user table.User, tenant table.Organization) (table.User, error) {
+	return db.createNewUserWithTenant(user, tenant, tx, ctx)
+}
 
-	_, err = db.getUserByEmail(user.Email, tx, ctx)
+func (db DB) createNewUserWithTenant(user table.User, tenant table.Organization, tx querier, ctx context.Context) (table.User, error) {
+	_, err := db.getUserByEmail(user.Email, tx, ctx)
 	if err == nil || !errors.Is(err, ErrDatabaseNotFound) {
 		return user, errx.WrapWithType(ErrUserAlreadyExists, err, "user can't be created")
 	}
-	userFromDB, err := db.createUser(user, tx, ctx)
+	createdUser, err := db.createUser(user, tx, ctx)
 	if err != nil {
 		return user, err
 	}
-	org := table.Organization{
-		Name:        fmt.Sprintf("userorg-%s", userFromDB.Name),
-		Description: fmt.Sprintf("Default org for user '%s'", userFromDB.Name),
+	if tenant.Name == "" {
+		tenant = defaultUserTenant(createdUser)
 	}
-	orgFromDB, err := db.createOrg(org, tx, ctx)
+	orgFromDB, err := db.createOrg(tenant, tx, ctx)
 	if err != nil {
-		return user, errx.WrapWithTypef(ErrOrgCreate, err, "for user (id: %d)", userFromDB.ID)
+		return user, errx.WrapWithTypef(ErrOrgCreate, err, "for user (id: %s)", createdUser.ID)
 	}
 	role := table.UserRole{
-		UserID:   userFromDB.ID,
+		UserID:   createdUser.ID,
 		OrgID:    orgFromDB.ID,
 		OrgView:  true,
 		OrgEdit:  true,
@@ -54,153 +69,269 @@ func (db DB) CreateNewUserWithOrg(user table.User, roles ...table.UserRole) (tab
 	}
 	err = db.createUserRole(role, tx, ctx)
 	if err != nil {
-		return userFromDB, errx.Wrapf(err, "unable to create role for user with email '%s'", user.Email)
+		return user, errx.Wrapf(err, "unable to create role for user with email '%s'", user.Email)
 	}
-
-	err = tx.Commit(ctx)
-	if err != nil {
-		return userFromDB, errx.WrapWithType(ErrDatabaseCommit, err, "")
-	}
-	return userFromDB, nil
+	return createdUser, nil
 }
 
-func (db DB) CreateUserIfNotExist(user table.User, roles ...table.UserRole) (table.User, error) {
+func (db DB) CreateUserIfNotExist(ctx context.Context, user table.User, roles ...table.UserRole) (table.User, error) {
 	if len(roles) < 1 {
 		return user, errx.NewWithType(ErrNoRoles, "CreateUserIfNotExist must have at least one role for the new user")
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
-	tx, err := db.Postgres.Begin(ctx)
-	if err != nil {
-		return user, errx.WrapWithType(ErrDatabaseQuery, err, "unable to start db transaction")
-	}
-	defer tx.Rollback(context.Background())
-
-	_, err = db.getUserByEmail(user.Email, tx, ctx)
-	if err == nil || !errors.Is(err, ErrDatabaseNotFound) {
-		return user, errx.WrapWithType(ErrUserAlreadyExists, err, "user can't be created")
-	}
-	userFromDB, err := db.createUser(user, tx, ctx)
-	if err != nil {
-		return user, err
-	}
-	for _, role := range roles {
-		role.UserID = userFromDB.ID
-		err = db.createUserRole(role, tx, ctx)
+	userFromDB := user
+	err := db.runTx(ctx, func(tx querier) error {
+		_, err := db.getUserByEmail(user.Email, tx, ctx)
+		if err == nil || !errors.Is(err, ErrDatabaseNotFound) {
+			return errx.WrapWithType(ErrUserAlreadyExists, err, "user can't be created")
+		}
+		createdUser, err := db.createUser(user, tx, ctx)
 		if err != nil {
-			return userFromDB, errx.Wrapf(err, "unable to create role for user with email '%s'", user.Email)
+			return err
 		}
-	}
-
-	err = tx.Commit(ctx)
+		for _, role := range roles {
+			role.UserID = createdUser.ID
+			err = db.createUserRole(role, tx, ctx)
+			if err != nil {
+				return errx.Wrapf(err, "unable to create role for user with email '%s'", user.Email)
+			}
+		}
+		userFromDB = createdUser
+		return nil
+	})
 	if err != nil {
-		return userFromDB, errx.WrapWithType(ErrDatabaseCommit, err, "")
+		return user, err
 	}
 	return userFromDB, nil
 }
 
-func (db DB) GetUserByID(id int) (table.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+func (db DB) GetUserByID(ctx context.Context, id uuid.UUID) (table.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
 	user := table.User{}
-	// err := pgxscan.Select(ctx, Database, &user, "SELECT * FROM users WHERE id = $1", id)
-	rows, err := db.Postgres.Query(ctx, "SELECT * FROM users WHERE id = $1", id)
-	if err != nil {
-		return user, errx.WrapWithType(ErrDatabaseQuery, err, "")
-	}
-	err = pgxscan.ScanOne(&user, rows)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return user, errx.NewWithTypef(ErrDatabaseNotFound, "no user found for id '%d'", id)
+	err := db.read(ctx).scanOne(ctx, &user, "SELECT * FROM users WHERE id = $1 AND deleted_at IS NULL", id)
+	if errors.Is(err, errNoRows) {
+		return user, errx.NewWithTypef(ErrDatabaseNotFound, "no user found for id '%s'", id)
 	}
 	if err != nil {
-		return user, errx.WrapWithType(ErrDatabaseScan, err, "")
+		return user, errx.WrapWithType(ErrDatabaseQuery, err, "")
 	}
-	// err := pgxscan.NewScanner(row).Scan(&user)
 	return user, nil
 }
 
-func (db DB) GetUserByEmail(email string) (table.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+func (db DB) GetUserByEmail(ctx context.Context, email string) (table.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
-	tx, err := db.Postgres.Begin(ctx)
-	if err != nil {
-		return table.User{}, errx.WrapWithType(ErrDatabaseQuery, err, "unable to start db transaction")
-	}
-	defer tx.Rollback(context.Background())
+	return db.getUserByEmail(email, db.read(ctx), ctx)
+}
 
-	user, err := db.getUserByEmail(email, tx, ctx)
+// unique user is defined by user.Email, also creates the default viewer role for the specified organization
+func (db DB) GetOrCreateUser(ctx context.Context, user table.User, role table.UserRole) (table.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	userFromDB := table.User{}
+	err := db.runTx(ctx, func(tx querier) error {
+		var err error
+		userFromDB, err = db.getUserByEmail(user.Email, tx, ctx)
+		if err != nil && !errors.Is(err, ErrDatabaseNotFound) {
+			return err
+		}
+		if errors.Is(err, ErrDatabaseNotFound) {
+			// TODO: validate, that user has non-empty string for NickName, Email
+			userFromDB, err = db.createUser(user, tx, ctx)
+			if err != nil {
+				return err
+			}
+			role.UserID = userFromDB.ID
+			err = db.createUserRole(role, tx, ctx)
+			if err != nil {
+				return errx.Wrapf(err, "unable to create role for user with email '%s'", user.Email)
+			}
+			log.Logf(log.LevelDebug, "User created: %s (%s)", user.Name, user.Email)
+		}
+		return nil
+	})
 	if err != nil {
 		return user, err
 	}
+	return userFromDB, nil
+}
+
+// GetUserByEmail() in the transaction tx
+func (db DB) GetUserByEmailTx(ctx context.Context, tx Tx, email string) (table.User, error) {
+	return db.getUserByEmail(email, tx, ctx)
+}
 
-	err = tx.Commit(ctx)
+func (db DB) getUserByEmail(email string, tx querier, ctx context.Context) (table.User, error) {
+	user := table.User{}
+	err := tx.scanOne(ctx, &user, "SELECT * FROM users WHERE email = $1 AND deleted_at IS NULL", email)
+	if errors.Is(err, errNoRows) {
+		return user, errx.NewWithTypef(ErrDatabaseNotFound, "no user found for email '%s'", email)
+	}
 	if err != nil {
-		return user, errx.NewWithType(ErrDatabaseCommit, err.Error())
+		return user, errx.WrapWithType(ErrDatabaseQuery, err, "")
 	}
 	return user, nil
 }
 
-// unique user is defined by user.Email, also creates the default viewer role for the specified organization
-func (db DB) GetOrCreateUser(user table.User, role table.UserRole) (table.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
-	defer cancel()
-	tx, err := db.Postgres.Begin(ctx)
+// Create user without organization or roles in the transaction tx, use CreateUserRoleTx() to add roles
+func (db DB) CreateUserTx(ctx context.Context, tx Tx, user table.User) (table.User, error) {
+	return db.createUser(user, tx, ctx)
+}
+
+func (db DB) createUser(user table.User, tx querier, ctx context.Context) (table.User, error) {
+	createdUser := table.User{}
+	query := "INSERT INTO users (id, name, auth_provider, email, email_verified, password_hash, secrets_version, totp_secret, super_admin) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, name, auth_provider, email, email_verified, password_hash, secrets_version, totp_secret, super_admin, created_at, updated_at"
+	err := tx.scanOne(ctx, &createdUser, query, table.NewID(), user.Name, user.AuthProvider, user.Email, user.EmailVerified, user.PasswordHash, user.SecretsVersion, user.TotpSecret, user.SuperAdmin)
 	if err != nil {
-		return user, errx.NewWithTypef(ErrDatabaseQuery, "unable to start db transaction: %s", err.Error())
+		return createdUser, errx.WrapWithTypef(ErrDatabaseInsert, err, "user (email: %s) could not be created", user.Email)
 	}
-	defer tx.Rollback(context.Background())
+	return createdUser, nil
+}
 
-	userFromDB, err := db.getUserByEmail(user.Email, tx, ctx)
-	if err != nil && !errors.Is(err, ErrDatabaseNotFound) {
-		return user, err
+func (db DB) GetAllUsers(ctx context.Context) ([]table.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	users := []table.User{}
+	err := db.conn().scanAll(ctx, &users, "SELECT * FROM users WHERE deleted_at IS NULL ORDER BY id")
+	if err != nil {
+		return users, errx.WrapWithType(ErrDatabaseQuery, err, "")
 	}
-	if errors.Is(err, ErrDatabaseNotFound) {
-		// TODO: validate, that user has non-empty string for NickName, Email
-		userFromDB, err = db.createUser(user, tx, ctx)
+	return users, nil
+}
+
+var userListSpec = listSpec{
+	table: "users",
+	filters: map[string]func(string) (any, error){
+		"name": filterString, "email": filterString, "auth_provider": filterString,
+		"email_verified": filterBool, "super_admin": filterBool, "disabled": filterBool,
+	},
+	sorts:       []string{"created_at", "name", "email"},
+	defaultSort: "created_at",
+}
+
+// page of users, filters: name, email, auth_provider, email_verified, super_admin, disabled. Sort: created_at (default), name, email
+func (db DB) ListUsers(ctx context.Context, q ListQuery) (ListPage[table.User], error) {
+	return list[table.User](ctx, db, userListSpec, q, []string{"deleted_at IS NULL"})
+}
+
+// disabling a user also revokes all sessions of the user
+func (db DB) SetUserDisabled(ctx context.Context, userID uuid.UUID, disabled bool) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	return db.runTx(ctx, func(tx querier) error {
+		rowsAffected, err := tx.exec(ctx, "UPDATE users SET (disabled, updated_at) = ($1, $2) WHERE id = $3 AND deleted_at IS NULL", disabled, time.Now(), userID)
 		if err != nil {
-			return user, err
+			return errx.WrapWithTypef(ErrDatabaseUpdate, err, "unable to update disabled state of user (id: %s)", userID)
 		}
-		role.UserID = userFromDB.ID
-		err = db.createUserRole(role, tx, ctx)
-		if err != nil {
-			return user, errx.Wrapf(err, "unable to create role for user with email '%s'", user.Email)
+		if rowsAffected != 1 {
+			return errx.NewWithTypef(ErrDatabaseNotFound, "no user found for id '%s'", userID)
 		}
-		log.Logf(log.LevelDebug, "User created: %s (%s)", user.Name, user.Email)
-	}
-
-	err = tx.Commit(ctx)
-	if err != nil {
-		return user, errx.WrapWithType(ErrDatabaseCommit, err, "")
-	}
-	return userFromDB, nil
+		if disabled {
+			if _, err := db.revokeSessions(ctx, tx, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (db DB) getUserByEmail(email string, tx pgx.Tx, ctx context.Context) (table.User, error) {
-	user := table.User{}
-	rows, err := tx.Query(ctx, "SELECT * FROM users WHERE email = $1", email)
+func (db DB) SetUserSuperAdmin(ctx context.Context, userID uuid.UUID, superAdmin bool) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	rowsAffected, err := db.conn().exec(ctx, "UPDATE users SET (super_admin, updated_at) = ($1, $2) WHERE id = $3 AND deleted_at IS NULL", superAdmin, time.Now(), userID)
 	if err != nil {
-		return user, errx.WrapWithType(ErrDatabaseQuery, err, "")
+		return errx.WrapWithTypef(ErrDatabaseUpdate, err, "unable to update super admin state of user (id: %s)", userID)
 	}
-	err = pgxscan.ScanOne(&user, rows)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return user, errx.NewWithTypef(ErrDatabaseNotFound, "no user found for email '%s'", email)
+	if rowsAffected != 1 {
+		return errx.NewWithTypef(ErrDatabaseNotFound, "no user found for id '%s'", userID)
 	}
-	if err != nil {
-		return user, errx.WrapWithType(ErrDatabaseScan, err, "")
-	}
-	return user, nil
+	return nil
 }
 
-func (db DB) createUser(user table.User, tx pgx.Tx, ctx context.Context) (table.User, error) {
-	createdUser := table.User{}
-	query := "INSERT INTO users (name, auth_provider, email, email_verified, password_hash, secrets_version, totp_secret, super_admin) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, name, auth_provider, email, email_verified, password_hash, secrets_version, totp_secret, super_admin, created_at, updated_at"
-	rows, err := tx.Query(ctx, query, user.Name, user.AuthProvider, user.Email, user.EmailVerified, user.PasswordHash, user.SecretsVersion, user.TotpSecret, user.SuperAdmin)
-	if err != nil {
-		return createdUser, errx.WrapWithTypef(ErrDatabaseInsert, err, "user (email: %s) could not be created", user.Email)
-	}
-	err = pgxscan.ScanOne(&createdUser, rows)
+// passwordHash must already be hashed, secrets version is increased and all sessions of the user are revoked
+func (db DB) UpdateUserPassword(ctx context.Context, userID uuid.UUID, passwordHash h.SecretString) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	return db.runTx(ctx, func(tx querier) error {
+		query := "UPDATE users SET (password_hash, secrets_version, updated_at) = ($1, secrets_version + 1, $2) WHERE id = $3 AND deleted_at IS NULL"
+		rowsAffected, err := tx.exec(ctx, query, passwordHash, time.Now(), userID)
+		if err != nil {
+			return errx.WrapWithTypef(ErrDatabaseUpdate, err, "unable to update password of user (id: %s)", userID)
+		}
+		if rowsAffected != 1 {
+			return errx.NewWithTypef(ErrDatabaseNotFound, "no user found for id '%s'", userID)
+		}
+		if _, err := db.revokeSessions(ctx, tx, userID); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// soft delete user and the roles of the user and revoke all sessions, the user can be restored with RestoreUser until it is purged
+func (db DB) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	return db.runTx(ctx, func(tx querier) error {
+		now := time.Now().UTC()
+		rowsAffected, err := tx.exec(ctx, "UPDATE users SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL", now, userID)
+		if err != nil {
+			return errx.WrapWithTypef(ErrDatabaseDelete, err, "unable to delete user (id: %s)", userID)
+		}
+		if rowsAffected != 1 {
+			return errx.NewWithTypef(ErrDatabaseNotFound, "no user found for id '%s'", userID)
+		}
+		if _, err := tx.exec(ctx, "UPDATE user_roles SET deleted_at = $1 WHERE user_id = $2 AND deleted_at IS NULL", now, userID); err != nil {
+			return errx.WrapWithTypef(ErrDatabaseDelete, err, "unable to delete roles of user (id: %s)", userID)
+		}
+		if _, err := db.revokeSessions(ctx, tx, userID); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// restore deleted user and the roles deleted together with the user, except roles of deleted organizations.
+// Fails with ErrUserAlreadyExists if another user with the same email was created in the meantime
+func (db DB) RestoreUser(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	return db.runTx(ctx, func(tx querier) error {
+		user := table.User{}
+		err := tx.scanOne(ctx, &user, "SELECT * FROM users WHERE id = $1 AND deleted_at IS NOT NULL", userID)
+		if errors.Is(err, errNoRows) {
+			return errx.NewWithTypef(ErrDatabaseNotFound, "no deleted user found for id '%s'", userID)
+		}
+		if err != nil {
+			return errx.WrapWithType(ErrDatabaseQuery, err, "")
+		}
+		_, err = db.getUserByEmail(user.Email, tx, ctx)
+		if err == nil || !errors.Is(err, ErrDatabaseNotFound) {
+			return errx.WrapWithType(ErrUserAlreadyExists, err, "user can't be restored")
+		}
+		query := `UPDATE user_roles SET deleted_at = NULL WHERE user_id = $1
+			AND deleted_at = (SELECT deleted_at FROM users WHERE id = $1)
+			AND org_id IN (SELECT id FROM organizations WHERE deleted_at IS NULL)`
+		if _, err := tx.exec(ctx, query, userID); err != nil {
+			return errx.WrapWithTypef(ErrDatabaseRestore, err, "unable to restore roles of user (id: %s)", userID)
+		}
+		if _, err := tx.exec(ctx, "UPDATE users SET (deleted_at, updated_at) = (NULL, $1) WHERE id = $2", time.Now(), userID); err != nil {
+			return errx.WrapWithTypef(ErrDatabaseRestore, err, "unable to restore user (id: %s)", userID)
+		}
+		return nil
+	})
+}
+
+// all deleted users that weren't purged yet, last deleted first
+func (db DB) GetDeletedUsers(ctx context.Context) ([]table.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	users := []table.User{}
+	err := db.conn().scanAll(ctx, &users, "SELECT * FROM users WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
 	if err != nil {
-		return createdUser, errx.WrapWithType(ErrDatabaseScan, err, "")
+		return users, errx.WrapWithType(ErrDatabaseQuery, err, "")
 	}
-	return createdUser, nil
+	return users, nil
 }
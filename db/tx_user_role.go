@@ -4,53 +4,72 @@ import (
 	"context"
 	"errors"
 
-	"github.com/georgysavva/scany/v2/pgxscan"
-	"github.com/jackc/pgx/v5"
+	"github.com/gofrs/uuid/v5"
 	"gopkg.cc/apibase/errx"
 	"gopkg.cc/apibase/table"
 )
 
-func (db DB) GetUserRoles(userID int) ([]table.UserRole, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), db.BaseConfig.TimeoutDatabaseQuery)
+func (db DB) GetUserRoles(ctx context.Context, userID uuid.UUID) ([]table.UserRole, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
 	defer cancel()
 	roles := []table.UserRole{}
-	// err := pgxscan.Select(ctx, Database, &user, "SELECT * FROM users WHERE id = $1", id)
-	rows, err := db.Postgres.Query(ctx, "SELECT * FROM user_roles WHERE user_id = $1", userID)
+	err := db.read(ctx).scanAll(ctx, &roles, "SELECT * FROM user_roles WHERE user_id = $1 AND deleted_at IS NULL", userID)
 	if err != nil {
 		return roles, errx.WrapWithType(ErrDatabaseQuery, err, "")
 	}
-	err = pgxscan.ScanAll(&roles, rows)
-	if err != nil {
-		return roles, errx.WrapWithType(ErrDatabaseScan, err, "")
-	}
 	if len(roles) < 1 {
-		return roles, errx.NewWithTypef(ErrDatabaseNotFound, "no roles found for user (id: %d)", userID)
+		return roles, errx.NewWithTypef(ErrDatabaseNotFound, "no roles found for user (id: %s)", userID)
 	}
-	// err := pgxscan.NewScanner(row).Scan(&user)
 	return roles, nil
 }
 
-func (db DB) getUserRole(userID int, orgID int, tx pgx.Tx, ctx context.Context) (table.UserRole, error) {
+var userRoleListSpec = listSpec{
+	table:       "user_roles",
+	filters:     map[string]func(string) (any, error){"org_id": filterUUID, "org_view": filterBool, "org_edit": filterBool, "org_admin": filterBool},
+	sorts:       []string{"id", "org_id"},
+	defaultSort: "id",
+}
+
+// page of the roles of a user, filters: org_id, org_view, org_edit, org_admin. Sort: id (default, creation order), org_id
+func (db DB) ListUserRoles(ctx context.Context, userID uuid.UUID, q ListQuery) (ListPage[table.UserRole], error) {
+	return list[table.UserRole](ctx, db, userRoleListSpec, q, []string{"user_id = $1", "deleted_at IS NULL"}, userID)
+}
+
+func (db DB) getUserRole(userID uuid.UUID, orgID uuid.UUID, tx querier, ctx context.Context) (table.UserRole, error) {
 	role := table.UserRole{}
-	rows, err := tx.Query(ctx, "SELECT * FROM user_roles WHERE user_id = $1 AND org_id = $2", userID, orgID)
-	if err != nil {
-		return role, errx.WrapWithType(ErrDatabaseQuery, err, "")
-	}
-	err = pgxscan.ScanOne(&role, rows)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return role, errx.NewWithTypef(ErrDatabaseNotFound, "no role found for user (id: %d) and org (id: %d)", userID, orgID)
+	err := tx.scanOne(ctx, &role, "SELECT * FROM user_roles WHERE user_id = $1 AND org_id = $2 AND deleted_at IS NULL", userID, orgID)
+	if errors.Is(err, errNoRows) {
+		return role, errx.NewWithTypef(ErrDatabaseNotFound, "no role found for user (id: %s) and org (id: %s)", userID, orgID)
 	}
 	if err != nil {
-		return role, errx.WrapWithType(ErrDatabaseScan, err, "")
+		return role, errx.WrapWithType(ErrDatabaseQuery, err, "")
 	}
 	return role, nil
 }
 
-func (db DB) createUserRole(role table.UserRole, tx pgx.Tx, ctx context.Context) error {
-	query := "INSERT INTO user_roles (user_id, org_id, org_view, org_edit, org_admin) VALUES ($1, $2, $3, $4, $5)"
-	_, err := tx.Exec(ctx, query, role.UserID, role.OrgID, role.OrgView, role.OrgEdit, role.OrgAdmin)
+// also records the table.AuditRoleGrant event, tx should be a transaction
+func (db DB) createUserRole(role table.UserRole, tx querier, ctx context.Context) error {
+	query := "INSERT INTO user_roles (id, user_id, org_id, org_view, org_edit, org_admin) VALUES ($1, $2, $3, $4, $5, $6)"
+	_, err := tx.exec(ctx, query, table.NewID(), role.UserID, role.OrgID, role.OrgView, role.OrgEdit, role.OrgAdmin)
 	if err != nil {
-		return errx.WrapWithTypef(ErrDatabaseInsert, err, "role for user (id: %d) could not be created", role.UserID)
+		return errx.WrapWithTypef(ErrDatabaseInsert, err, "role for user (id: %s) could not be created", role.UserID)
 	}
-	return nil
+	return db.createAuditEvent(ctx, tx, roleGrantEvent(role))
+}
+
+func (db DB) CreateUserRole(ctx context.Context, role table.UserRole) error {
+	ctx, cancel := context.WithTimeout(ctx, db.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	return db.runTx(ctx, func(tx querier) error {
+		return db.createUserRole(role, tx, ctx)
+	})
+}
+
+// CreateUserRole() in the transaction tx
+func (db DB) CreateUserRoleTx(ctx context.Context, tx Tx, role table.UserRole) error {
+	return db.createUserRole(role, tx, ctx)
+}
+
+func roleGrantEvent(role table.UserRole) table.AuditEvent {
+	return table.AuditEvent{OrgID: &role.OrgID, Action: table.AuditRoleGrant, Target: "user:" + role.UserID.String(), Diff: AuditDiff(nil, role)}
 }
@@ -0,0 +1,203 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"gopkg.cc/apibase/errx"
+	h "gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/table"
+)
+
+// Difference between a table struct and the database table
+type SchemaDiff struct {
+	Table   string
+	Column  string // empty if the whole table is affected
+	Problem string
+}
+
+func (d SchemaDiff) String() string {
+	if d.Column == "" {
+		return fmt.Sprintf("%s: %s", d.Table, d.Problem)
+	}
+	return fmt.Sprintf("%s.%s: %s", d.Table, d.Column, d.Problem)
+}
+
+type schemaColumn struct {
+	Name       string `db:"name"`
+	Type       string `db:"type"`
+	Nullable   bool   `db:"nullable"`
+	HasDefault bool   `db:"has_default"`
+}
+
+var (
+	verifyTablesMtx sync.Mutex
+	verifyTables    = []any{table.User{}, table.Organization{}, table.RefreshToken{}, table.UserRole{}, table.ScheduledTask{}}
+)
+
+// Register own table structs, which are verified against the database by VerifySchema() alongside the default tables.
+// The table name is set by the 'table' tag of any field, columns by 'db' tags and columns with database default by 'default:"true"'
+func RegisterTables(structs ...any) error {
+	verifyTablesMtx.Lock()
+	defer verifyTablesMtx.Unlock()
+	for _, s := range structs {
+		if _, err := tableName(reflect.TypeOf(s)); err != nil {
+			return err
+		}
+		verifyTables = append(verifyTables, s)
+	}
+	return nil
+}
+
+// Compare all registered table structs to the database tables (column names, types, nullability and defaults),
+// every column selected by 'SELECT *' must be a field of the struct, otherwise scanning fails
+func VerifySchema(ctx context.Context, database DB) ([]SchemaDiff, error) {
+	ctx, cancel := context.WithTimeout(ctx, database.BaseConfig.TimeoutDatabaseQuery)
+	defer cancel()
+	verifyTablesMtx.Lock()
+	structs := slices.Clone(verifyTables)
+	verifyTablesMtx.Unlock()
+
+	query := `SELECT column_name AS name, data_type AS type, is_nullable = 'YES' AS nullable, column_default IS NOT NULL AS has_default
+		FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1`
+	if database.Kind == SQLite {
+		query = `SELECT name, type, "notnull" = 0 AND pk = 0 AS nullable, dflt_value IS NOT NULL OR (pk = 1 AND lower(type) = 'integer') AS has_default
+			FROM pragma_table_info($1)`
+	}
+	diffs := []SchemaDiff{}
+	for _, s := range structs {
+		t := reflect.TypeOf(s)
+		name, err := tableName(t)
+		if err != nil {
+			return nil, err
+		}
+		columns := []schemaColumn{}
+		if err := database.conn().scanAll(ctx, &columns, query, name); err != nil {
+			return nil, errx.WrapWithTypef(ErrDatabaseQuery, err, "unable to read columns of table '%s'", name)
+		}
+		diffs = append(diffs, diffTable(name, t, columns)...)
+	}
+	return diffs, nil
+}
+
+// Verify schema like VerifySchema(), returns ErrDatabaseSchema with a report of all differences if any is found
+func CheckSchema(ctx context.Context, database DB) error {
+	diffs, err := VerifySchema(ctx, database)
+	if err != nil || len(diffs) < 1 {
+		return err
+	}
+	report := ""
+	for _, diff := range diffs {
+		report += "\n  - " + diff.String()
+	}
+	return errx.NewWithTypef(ErrDatabaseSchema, "%d differences found:%s", len(diffs), report)
+}
+
+func diffTable(name string, t reflect.Type, columns []schemaColumn) []SchemaDiff {
+	if len(columns) < 1 {
+		return []SchemaDiff{{Table: name, Problem: "table doesn't exist"}}
+	}
+	diffs := []SchemaDiff{}
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		if column, ok := t.Field(i).Tag.Lookup("db"); ok && column != "-" {
+			fields[column] = t.Field(i)
+		}
+	}
+	for _, column := range columns {
+		field, ok := fields[column.Name]
+		if !ok {
+			diffs = append(diffs, SchemaDiff{name, column.Name, fmt.Sprintf("column has no field with tag db:\"%s\" in %s", column.Name, t.String())})
+			continue
+		}
+		delete(fields, column.Name)
+		fieldType, nullable := field.Type, false
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType, nullable = fieldType.Elem(), true
+		}
+		if kinds := columnTypes(fieldType); kinds != nil && !slices.Contains(kinds, normalizeColumnType(column.Type)) {
+			diffs = append(diffs, SchemaDiff{name, column.Name, fmt.Sprintf("column type %s doesn't match field %s of type %s", column.Type, field.Name, field.Type.String())})
+		}
+		if nullable && !column.Nullable {
+			diffs = append(diffs, SchemaDiff{name, column.Name, fmt.Sprintf("column is NOT NULL, but field %s of type %s may be nil", field.Name, field.Type.String())})
+		}
+		if column.Nullable && !column.HasDefault && !nullable && !scansNull(field.Type) {
+			diffs = append(diffs, SchemaDiff{name, column.Name, fmt.Sprintf("column is nullable without default, but field %s of type %s can't hold NULL", field.Name, field.Type.String())})
+		}
+		if field.Tag.Get("default") == "true" && !column.HasDefault {
+			diffs = append(diffs, SchemaDiff{name, column.Name, fmt.Sprintf("field %s is tagged default:\"true\", but column has no default", field.Name)})
+		}
+	}
+	for column, field := range fields {
+		diffs = append(diffs, SchemaDiff{name, column, fmt.Sprintf("column of field %s doesn't exist", field.Name)})
+	}
+	slices.SortStableFunc(diffs, func(a, b SchemaDiff) int { return strings.Compare(a.Column, b.Column) })
+	return diffs
+}
+
+func tableName(t reflect.Type) (string, error) {
+	if t == nil || t.Kind() != reflect.Struct {
+		return "", errx.NewWithTypef(ErrDatabaseSchema, "table struct must be a struct, got %v", t)
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := t.Field(i).Tag.Lookup("table"); ok && name != "" {
+			return name, nil
+		}
+	}
+	return "", errx.NewWithTypef(ErrDatabaseSchema, "no field of %s has a 'table' tag", t.String())
+}
+
+var columnTypeLength = regexp.MustCompile(`\s*\(.*\)$`)
+
+// lower case without length, e.g. "VARCHAR(255)" -> "varchar"
+func normalizeColumnType(columnType string) string {
+	return columnTypeLength.ReplaceAllString(strings.ToLower(strings.TrimSpace(columnType)), "")
+}
+
+var (
+	integerColumns   = []string{"integer", "bigint", "smallint", "int", "serial", "bigserial"}
+	textColumns      = []string{"text", "character varying", "varchar", "character", "char", "json", "jsonb", "uuid"}
+	timestampColumns = []string{"timestamp with time zone", "timestamp without time zone", "timestamp", "timestamptz", "date", "datetime"}
+)
+
+// normalized column types accepted for a field type, nil if any type is accepted (e.g. own sql.Scanner types)
+func columnTypes(t reflect.Type) []string {
+	switch t {
+	case reflect.TypeFor[time.Time]():
+		return timestampColumns
+	case reflect.TypeFor[h.SecretString]():
+		return textColumns
+	case reflect.TypeFor[table.Duration]():
+		return integerColumns
+	case reflect.TypeFor[uuid.UUID]():
+		return []string{"uuid", "text"}
+	}
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[sql.Scanner]()) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return integerColumns
+	case reflect.String:
+		return textColumns
+	case reflect.Bool:
+		return []string{"boolean", "bool"}
+	case reflect.Float32, reflect.Float64:
+		return []string{"real", "double precision", "numeric", "decimal", "float", "double"}
+	}
+	return nil
+}
+
+// own sql.Scanner types may handle NULL, e.g. table.Duration
+func scansNull(t reflect.Type) bool {
+	return t != reflect.TypeFor[uuid.UUID]() && reflect.PointerTo(t).Implements(reflect.TypeFor[sql.Scanner]())
+}
@@ -0,0 +1,82 @@
+package db_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"gopkg.cc/apibase/baseconfig"
+	"gopkg.cc/apibase/db"
+)
+
+type verifyItem struct {
+	ID        int        `db:"id" default:"true" table:"verify_items"`
+	Name      string     `db:"name"`
+	Count     int        `db:"count"`
+	Note      string     `db:"note"`
+	Deleted   *time.Time `db:"deleted"`
+	Missing   bool       `db:"missing"`
+	CreatedAt time.Time  `db:"created_at" default:"true"`
+}
+
+var _ = db.RegisterTables(verifyItem{})
+
+func TestVerifySchema(t *testing.T) {
+	bc := baseconfig.BaseConfig{}
+	if err := bc.AddMissingFromDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	database, err := db.SQLiteInit(context.Background(), db.SQLiteConfig{FilePath: filepath.Join(t.TempDir(), "test.db")}, &bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close(context.Background())
+	if err := db.MigrateUp(context.Background(), database); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"verify_items: table doesn't exist"}
+	diffs, err := db.VerifySchema(context.Background(), database)
+	if err != nil {
+		t.Fatalf("VerifySchema() error: %v", err)
+	}
+	if got := diffStrings(diffs); !slices.Equal(got, want) {
+		t.Errorf("VerifySchema() without table = %v, want %v", got, want)
+	}
+
+	if _, err := database.SQLite.DB.Exec(`CREATE TABLE verify_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		count TEXT NOT NULL,
+		note TEXT,
+		deleted TIMESTAMP NOT NULL,
+		extra TEXT,
+		created_at TIMESTAMP NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		"verify_items.count: column type TEXT doesn't match field Count of type int",
+		"verify_items.created_at: field CreatedAt is tagged default:\"true\", but column has no default",
+		"verify_items.deleted: column is NOT NULL, but field Deleted of type *time.Time may be nil",
+		"verify_items.extra: column has no field with tag db:\"extra\" in db_test.verifyItem",
+		"verify_items.missing: column of field Missing doesn't exist",
+		"verify_items.note: column is nullable without default, but field Note of type string can't hold NULL",
+	}
+	diffs, err = db.VerifySchema(context.Background(), database)
+	if err != nil {
+		t.Fatalf("VerifySchema() error: %v", err)
+	}
+	if got := diffStrings(diffs); !slices.Equal(got, want) {
+		t.Errorf("VerifySchema() = %v, want %v", got, want)
+	}
+}
+
+func diffStrings(diffs []db.SchemaDiff) []string {
+	result := []string{}
+	for _, diff := range diffs {
+		result = append(result, diff.String())
+	}
+	return result
+}
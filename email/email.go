@@ -1,8 +1,12 @@
 package email
 
 import (
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/smtp"
+	"strconv"
+	"time"
 
 	"gopkg.cc/apibase/errx"
 	"gopkg.cc/apibase/helper"
@@ -50,3 +54,34 @@ func (c EmailConfig) SendMailFrom(from string, to []string, msg []byte) error {
 	}
 	return errx.NewWithTypef(ErrInvalidConfig, "Unknown sender type %d", c.Sender)
 }
+
+// Connect and authenticate to the smtp server without sending an email, e.g. to verify config
+func (c EmailConfig) TestConnection(timeout time.Duration) error {
+	if c.Host == "" || c.Port == 0 {
+		return errx.NewWithType(ErrInvalidConfig, "Host and Port must be specified in config")
+	}
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return errx.WrapWithTypef(ErrConnection, err, "unable to connect to '%s'", addr)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return errx.WrapWithTypef(ErrConnection, err, "'%s' is no smtp server", addr)
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: c.Host}); err != nil {
+			return errx.WrapWithType(ErrConnection, err, "starttls failed")
+		}
+	}
+	if c.Username != "" {
+		// same as smtp.SendMail, plain auth is refused by net/smtp without tls unless connecting to localhost
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password.GetSecret(), c.Host)); err != nil {
+			return errx.WrapWithType(ErrConnection, err, "authentication failed")
+		}
+	}
+	return client.Quit()
+}
@@ -4,6 +4,7 @@ import "gopkg.cc/apibase/errx"
 
 var (
 	ErrInvalidConfig           = errx.NewType("invalid email sender config")
+	ErrConnection              = errx.NewType("email server connection failed")
 	ErrInvalidTemplate         = errx.NewType("invalide email template")
 	ErrTemplateExec            = errx.NewType("error during template execution")
 	ErrTemplateNoFileInEmbedFS = errx.NewType("template file not found in embedfs")
@@ -0,0 +1,10 @@
+package helper
+
+import "gopkg.cc/apibase/errx"
+
+var (
+	ErrSecretFetch  = errx.NewType("unable to fetch secret")
+	ErrSecretConfig = errx.NewType("invalid secrets config")
+	ErrVault        = errx.NewType("vault request failed")
+	ErrConfigValue  = errx.NewType("invalid config value")
+)
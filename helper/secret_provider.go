@@ -0,0 +1,233 @@
+package helper
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/log"
+)
+
+// Fetches secret values for SecretString references of the form "${<scheme>:<ref>}", e.g. "${vault:kv/app#token_secret}"
+type SecretProvider interface {
+	// ref is passed without scheme, e.g. "kv/app#token_secret"
+	Fetch(ctx context.Context, ref string) (string, error)
+}
+
+const DEFAULT_SECRETS_REFRESH_INTERVAL = time.Minute * 5
+
+// Config for referenced secrets, [secrets] section in config file
+type SecretsConfig struct {
+	TomlRefreshInterval string       `toml:"refresh_interval"` // how often referenced secrets are fetched again, default 5m
+	VaultAddress        string       `toml:"vault_address"`    // falls back to VAULT_ADDR env var
+	VaultNamespace      string       `toml:"vault_namespace"`  // falls back to VAULT_NAMESPACE env var
+	VaultToken          SecretString `toml:"vault_token"`      // falls back to VAULT_TOKEN env var, may be a reference itself, e.g. "${file:/run/secrets/vault}"
+
+	RefreshInterval time.Duration `internal:"refresh_interval"`
+}
+
+func (sc *SecretsConfig) AddMissingFromDefaults() error {
+	sc.RefreshInterval = DEFAULT_SECRETS_REFRESH_INTERVAL
+	if sc.TomlRefreshInterval == "" {
+		return nil
+	}
+	interval, err := StringToDuration(sc.TomlRefreshInterval)
+	if err != nil || interval <= 0 {
+		return errx.NewWithTypef(ErrSecretConfig, "invalid refresh_interval '%s'", sc.TomlRefreshInterval)
+	}
+	sc.RefreshInterval = interval
+	return nil
+}
+
+// Default SecretManager used by SecretString, providers for "file", "env" and "vault" are registered by default
+var Secrets = NewSecretManager()
+
+// Resolves and caches referenced secrets and notifies subscribers once a secret value changes
+type SecretManager struct {
+	mtx         sync.RWMutex
+	providers   map[string]SecretProvider
+	values      map[string]*secretValue // by full reference, including scheme
+	subscribers map[string][]func(value string)
+}
+
+type secretValue struct {
+	value   string
+	fetched bool
+}
+
+func NewSecretManager() *SecretManager {
+	m := &SecretManager{
+		providers:   map[string]SecretProvider{},
+		values:      map[string]*secretValue{},
+		subscribers: map[string][]func(value string){},
+	}
+	m.RegisterProvider("file", FileSecretProvider{})
+	m.RegisterProvider("env", EnvSecretProvider{})
+	m.RegisterProvider("vault", &VaultSecretProvider{})
+	return m
+}
+
+// Register provider for scheme, an existing provider for the same scheme is replaced.
+// Must be done before config is decoded, otherwise values with this scheme are parsed as plain secret
+func (m *SecretManager) RegisterProvider(scheme string, provider SecretProvider) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.providers[scheme] = provider
+}
+
+// Configure the registered vault provider, see VaultSecretProvider.Configure()
+func (m *SecretManager) ConfigureVault(address string, namespace string, token SecretString) {
+	m.mtx.Lock()
+	vault, ok := m.providers["vault"].(*VaultSecretProvider)
+	if !ok {
+		vault = &VaultSecretProvider{}
+		m.providers["vault"] = vault
+	}
+	m.mtx.Unlock()
+	vault.Configure(address, namespace, token, nil)
+}
+
+// Get cached secret value of reference, false if it wasn't fetched yet
+func (m *SecretManager) Get(ref string) (string, bool) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	v, ok := m.values[ref]
+	if !ok || !v.fetched {
+		return "", false
+	}
+	return v.value, true
+}
+
+// Call fn every time the value of reference changes, fn must not block
+func (m *SecretManager) Subscribe(ref string, fn func(value string)) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.subscribers[ref] = append(m.subscribers[ref], fn)
+}
+
+// Fetch all tracked references, previous values are kept for references that couldn't be fetched.
+// References with scheme "vault" are fetched last, since the vault token may be a reference itself
+func (m *SecretManager) Refresh(ctx context.Context) error {
+	return m.refresh(ctx, false)
+}
+
+// Fetch tracked references that weren't fetched yet, e.g. added by a reloaded config.
+// Values of references that were already fetched are kept and their subscribers aren't notified
+func (m *SecretManager) RefreshNew(ctx context.Context) error {
+	return m.refresh(ctx, true)
+}
+
+func (m *SecretManager) refresh(ctx context.Context, onlyNew bool) error {
+	m.mtx.RLock()
+	refs := []string{}
+	for ref, v := range m.values {
+		if !onlyNew || !v.fetched {
+			refs = append(refs, ref)
+		}
+	}
+	m.mtx.RUnlock()
+	slices.SortFunc(refs, func(a, b string) int {
+		aVault, bVault := strings.HasPrefix(a, "vault:"), strings.HasPrefix(b, "vault:")
+		if aVault != bVault {
+			if aVault {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a, b)
+	})
+
+	failed := []string{}
+	for _, ref := range refs {
+		scheme, path, _ := strings.Cut(ref, ":")
+		m.mtx.RLock()
+		provider := m.providers[scheme]
+		m.mtx.RUnlock()
+		value, err := provider.Fetch(ctx, path)
+		if err != nil {
+			log.Logf(log.LevelError, "unable to fetch secret '%s': %s", ref, err.Error())
+			failed = append(failed, ref)
+			continue
+		}
+		m.mtx.Lock()
+		current := m.values[ref]
+		changed := current.fetched && current.value != value
+		current.value, current.fetched = value, true
+		subscribers := slices.Clone(m.subscribers[ref])
+		m.mtx.Unlock()
+		if changed {
+			log.Logf(log.LevelNotice, "secret '%s' changed", ref)
+			for _, fn := range subscribers {
+				fn(value)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return errx.NewWithTypef(ErrSecretFetch, "%s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Refresh all tracked references every interval until ctx is done
+func (m *SecretManager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = m.Refresh(ctx) // errors are already logged, previous values are kept
+		}
+	}
+}
+
+// Number of tracked references
+func (m *SecretManager) Len() int {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return len(m.values)
+}
+
+// returns true if text starts with the scheme of a registered provider, the reference is tracked for Refresh()
+func (m *SecretManager) track(text string) bool {
+	scheme, _, ok := strings.Cut(text, ":")
+	if !ok {
+		return false
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if _, ok := m.providers[scheme]; !ok {
+		return false
+	}
+	if _, ok := m.values[text]; !ok {
+		m.values[text] = &secretValue{}
+	}
+	return true
+}
+
+// Reads secret from file, e.g. "file:/run/secrets/db", a single trailing newline is removed
+type FileSecretProvider struct{}
+
+func (FileSecretProvider) Fetch(ctx context.Context, ref string) (string, error) {
+	content, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(content), "\n"), "\r"), nil
+}
+
+// Reads secret from environment variable, e.g. "env:DB_PASSWORD"
+type EnvSecretProvider struct{}
+
+func (EnvSecretProvider) Fetch(ctx context.Context, ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", errx.Newf("env var %s is not set", ref)
+	}
+	return value, nil
+}
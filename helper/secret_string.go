@@ -4,10 +4,18 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strings"
+
+	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/log"
 )
 
+// Secret config value, which isn't printed. If the value is a reference to a registered SecretProvider (e.g. "${vault:kv/app#token_secret}"),
+// the current value is resolved by the default SecretManager (helper.Secrets), which must be refreshed before use.
+// Literal values starting with "${" are escaped with another "$", e.g. "$${not a reference}"
 type SecretString struct {
 	value string
+	ref   string
 }
 
 func CreateSecretString(value string) SecretString {
@@ -19,12 +27,60 @@ func (s SecretString) String() string {
 }
 
 func (s SecretString) GetSecret() string {
+	if s.ref == "" {
+		return s.value
+	}
+	value, ok := Secrets.Get(s.ref)
+	if !ok {
+		log.Logf(log.LevelError, "secret '%s' wasn't fetched yet", s.ref)
+	}
+	return value
+}
+
+// Get secret reference without "${}", e.g. "vault:kv/app#token_secret", empty if secret isn't a reference
+func (s SecretString) Ref() string {
+	return s.ref
+}
+
+// Call fn every time the referenced secret changes, does nothing if secret isn't a reference
+func (s SecretString) OnChange(fn func(value string)) {
+	if s.ref != "" {
+		Secrets.Subscribe(s.ref, fn)
+	}
+}
+
+// Value as written to a config file, references as "${<scheme>:<ref>}" and literals starting with "${" escaped
+func (s SecretString) ConfigText() string {
+	if s.ref != "" {
+		return "${" + s.ref + "}"
+	}
+	if dollars := len(s.value) - len(strings.TrimLeft(s.value, "$")); dollars > 0 && strings.HasPrefix(s.value[dollars:], "{") {
+		return "$" + s.value
+	}
 	return s.value
 }
 
-// Used by BurntSushi/toml to parse secrets from .toml config file
+// Used by BurntSushi/toml to parse secrets from .toml config file, only "${<scheme>:<ref>}" is a reference,
+// whose scheme must be registered (see SecretManager.RegisterProvider())
 func (s *SecretString) UnmarshalText(text []byte) error {
-	s.value = string(text)
+	value := string(text)
+	if dollars := len(value) - len(strings.TrimLeft(value, "$")); dollars > 1 && strings.HasPrefix(value[dollars:], "{") {
+		// escaped literal
+		s.value, s.ref = value[1:], ""
+		return nil
+	}
+	ref, ok := strings.CutPrefix(value, "${")
+	if ok {
+		ref, ok = strings.CutSuffix(ref, "}")
+	}
+	if !ok {
+		s.value, s.ref = value, ""
+		return nil
+	}
+	if !Secrets.track(ref) {
+		return errx.NewWithTypef(ErrSecretConfig, "secret reference '%s' has no registered provider, escape literal values as '$%s'", value, value)
+	}
+	s.value, s.ref = "", ref
 	return nil
 }
 
@@ -35,17 +91,18 @@ func (s *SecretString) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &tmp); err != nil {
 		return err
 	}
-	s.value = tmp
+	s.value, s.ref = tmp, ""
 	return nil
 }
 
 // Used to parse secret string to json
 func (s SecretString) MarshalJSON() ([]byte, error) {
-	return json.Marshal(s.value)
+	return json.Marshal(s.GetSecret())
 }
 
 // Used by pgx to scan value from database
 func (s *SecretString) Scan(src interface{}) error {
+	s.ref = ""
 	if src == nil {
 		s.value = ""
 		return nil
@@ -63,5 +120,5 @@ func (s *SecretString) Scan(src interface{}) error {
 
 // Used by pgx to get value for db update/insert/select...where
 func (s SecretString) Value() (driver.Value, error) {
-	return s.value, nil
+	return s.GetSecret(), nil
 }
@@ -0,0 +1,43 @@
+package helper_test
+
+import (
+	"errors"
+	"testing"
+
+	"gopkg.cc/apibase/helper"
+)
+
+func TestSecretStringReference(t *testing.T) {
+	// references are tracked by helper.Secrets and refreshed by other tests, so they must be resolvable
+	tests := []struct {
+		text    string
+		ref     string
+		value   string
+		wantErr error
+	}{
+		{"plain", "", "plain", nil},
+		{"env:PATH", "", "env:PATH", nil},
+		{"file:/etc/passwd", "", "file:/etc/passwd", nil},
+		{"${env:PATH}", "env:PATH", "", nil},
+		{"$${env:PATH}", "", "${env:PATH}", nil},
+		{"$$${x}", "", "$${x}", nil},
+		{"$5{x}", "", "$5{x}", nil},
+		{"${unknown:x}", "", "", helper.ErrSecretConfig},
+	}
+	for _, tt := range tests {
+		var s helper.SecretString
+		err := s.UnmarshalText([]byte(tt.text))
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("UnmarshalText(%s) = %v; want %v", tt.text, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if s.Ref() != tt.ref || (tt.ref == "" && s.GetSecret() != tt.value) {
+			t.Errorf("UnmarshalText(%s) = ref '%s', value '%s'; want ref '%s', value '%s'", tt.text, s.Ref(), s.GetSecret(), tt.ref, tt.value)
+		}
+		if s.ConfigText() != tt.text {
+			t.Errorf("ConfigText() of %s = %s", tt.text, s.ConfigText())
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.cc/apibase/errx"
+)
+
+const VAULT_REQUEST_TIMEOUT = time.Second * 10
+
+// Reads secrets from HashiCorp Vault KV version 2 secrets engine over HTTP, reference format is "<mount>/<path>#<key>",
+// e.g. "${vault:kv/app#token_secret}" reads key token_secret of secret app in mount kv.
+// If not configured, VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE env vars are used
+type VaultSecretProvider struct {
+	mtx       sync.RWMutex
+	address   string
+	namespace string
+	token     SecretString
+	client    *http.Client
+}
+
+// Set vault address, namespace (optional) and token, empty values fall back to env vars
+func (v *VaultSecretProvider) Configure(address string, namespace string, token SecretString, client *http.Client) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	v.address = address
+	v.namespace = namespace
+	v.token = token
+	v.client = client
+}
+
+func (v *VaultSecretProvider) Fetch(ctx context.Context, ref string) (string, error) {
+	secretPath, key, ok := strings.Cut(ref, "#")
+	mount, secretPath, okPath := strings.Cut(secretPath, "/")
+	if !ok || !okPath || key == "" || mount == "" || secretPath == "" {
+		return "", errx.NewWithTypef(ErrVault, "invalid reference '%s', expected <mount>/<path>#<key>", ref)
+	}
+
+	v.mtx.RLock()
+	address, namespace, token, client := v.address, v.namespace, v.token.GetSecret(), v.client
+	v.mtx.RUnlock()
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if namespace == "" {
+		namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if address == "" || token == "" {
+		return "", errx.NewWithType(ErrVault, "vault address and token must be configured")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: VAULT_REQUEST_TIMEOUT}
+	}
+
+	uri, err := url.JoinPath(address, "v1", mount, "data", secretPath)
+	if err != nil {
+		return "", errx.WrapWithType(ErrVault, err, "invalid vault address")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return "", errx.WrapWithType(ErrVault, err, "unable to create request")
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errx.WrapWithType(ErrVault, err, "")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errx.NewWithTypef(ErrVault, "unexpected status %d for '%s/%s'", resp.StatusCode, mount, secretPath)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", errx.WrapWithType(ErrVault, err, "unable to decode response")
+	}
+	value, ok := body.Data.Data[key]
+	if !ok {
+		return "", errx.NewWithTypef(ErrVault, "key '%s' doesn't exist in '%s/%s'", key, mount, secretPath)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
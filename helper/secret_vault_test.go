@@ -0,0 +1,108 @@
+package helper_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"gopkg.cc/apibase/helper"
+)
+
+func vaultStub(t *testing.T, secret *atomic.Value) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/kv/data/app" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"token_secret": secret.Load(), "port": 5432},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	}))
+}
+
+func TestVaultSecretProvider(t *testing.T) {
+	secret := &atomic.Value{}
+	secret.Store("s3cret")
+	server := vaultStub(t, secret)
+	defer server.Close()
+
+	vault := &helper.VaultSecretProvider{}
+	vault.Configure(server.URL, "", helper.CreateSecretString("test-token"), server.Client())
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{"kv/app#token_secret", "s3cret", false},
+		{"kv/app#port", "5432", false},
+		{"kv/app#missing", "", true},
+		{"kv/other#token_secret", "", true},
+		{"kv/app", "", true},
+		{"app#token_secret", "", true},
+	}
+	for _, test := range tests {
+		got, err := vault.Fetch(context.Background(), test.ref)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Fetch(%s) error = %v; wantErr %v", test.ref, err, test.wantErr)
+			continue
+		}
+		if err != nil && !errors.Is(err, helper.ErrVault) {
+			t.Errorf("Fetch(%s) error = %v; want ErrVault", test.ref, err)
+		}
+		if got != test.want {
+			t.Errorf("Fetch(%s) = %s; want %s", test.ref, got, test.want)
+		}
+	}
+
+	vault.Configure(server.URL, "", helper.CreateSecretString("wrong-token"), server.Client())
+	if _, err := vault.Fetch(context.Background(), "kv/app#token_secret"); err == nil {
+		t.Errorf("Fetch() with wrong token = nil; want error")
+	}
+}
+
+func TestSecretRotation(t *testing.T) {
+	secret := &atomic.Value{}
+	secret.Store("first")
+	server := vaultStub(t, secret)
+	defer server.Close()
+	helper.Secrets.ConfigureVault(server.URL, "", helper.CreateSecretString("test-token"))
+
+	var s helper.SecretString
+	if err := s.UnmarshalText([]byte("${vault:kv/app#token_secret}")); err != nil {
+		t.Fatal(err)
+	}
+	if err := helper.Secrets.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() = %v", err)
+	}
+	if got := s.GetSecret(); got != "first" {
+		t.Errorf("GetSecret() = %s; want first", got)
+	}
+
+	notified := ""
+	s.OnChange(func(value string) { notified = value })
+	secret.Store("second")
+	if err := helper.Secrets.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() = %v", err)
+	}
+	if got := s.GetSecret(); got != "second" || notified != "second" {
+		t.Errorf("after rotation GetSecret() = %s, notified = %s; want second", got, notified)
+	}
+
+	var plain helper.SecretString
+	plain.UnmarshalText([]byte("plain:not-a-provider"))
+	if plain.Ref() != "" || plain.GetSecret() != "plain:not-a-provider" {
+		t.Errorf("unknown scheme must be parsed as plain secret")
+	}
+}
@@ -0,0 +1,76 @@
+package helper
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Compare two config structs of the same type and return the dotted toml key of every differing value, e.g. "apiconfig.cors".
+// Only fields with 'toml' tag are compared, nested structs and maps are compared recursively,
+// any other value (including slices and structs without any 'toml' tag) is compared as a whole
+func DiffToml[T any](old *T, new *T) []string {
+	changes := []string{}
+	diffTomlValue(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), "", &changes)
+	sort.Strings(changes)
+	return changes
+}
+
+func diffTomlValue(old reflect.Value, new reflect.Value, key string, changes *[]string) {
+	if old.Kind() == reflect.Ptr || new.Kind() == reflect.Ptr {
+		old, new = derefOrZero(old), derefOrZero(new)
+	}
+	switch {
+	case old.Kind() == reflect.Struct && hasTomlFields(old.Type()):
+		for i := 0; i < old.NumField(); i++ {
+			tag, ok := old.Type().Field(i).Tag.Lookup("toml")
+			if !ok || tag == "" || tag == "-" || !old.Type().Field(i).IsExported() {
+				continue
+			}
+			diffTomlValue(old.Field(i), new.Field(i), joinTomlKey(key, tag), changes)
+		}
+	case old.Kind() == reflect.Map:
+		keys := map[string]reflect.Value{}
+		for _, k := range append(old.MapKeys(), new.MapKeys()...) {
+			keys[k.String()] = k
+		}
+		for name, k := range keys {
+			oldValue, newValue := old.MapIndex(k), new.MapIndex(k)
+			if !oldValue.IsValid() || !newValue.IsValid() {
+				*changes = append(*changes, joinTomlKey(key, name))
+				continue
+			}
+			diffTomlValue(oldValue, newValue, joinTomlKey(key, name), changes)
+		}
+	default:
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			*changes = append(*changes, key)
+		}
+	}
+}
+
+// nil pointers are compared as zero value of the underlying type
+func derefOrZero(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Ptr {
+		return v
+	}
+	if v.IsNil() {
+		return reflect.New(v.Type().Elem()).Elem()
+	}
+	return v.Elem()
+}
+
+func hasTomlFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("toml"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func joinTomlKey(parent string, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
@@ -0,0 +1,114 @@
+package helper
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.cc/apibase/log"
+)
+
+// shown instead of the value of a set SecretString by EffectiveToml()
+const REDACTED_SECRET = "*****"
+
+// Get the effective values of a config struct as map by toml key, e.g. for printing with toml or json encoder.
+// Fields with 'toml' tag that are parsed into a field with 'internal' tag of the same name (see ParseTomlConfigAndDefaults())
+// are shown with the parsed internal value, so defaults are included once they are added.
+// Set SecretString values are redacted, secret references (e.g. "${vault:kv/app#token_secret}") are shown as reference.
+// Only fields with 'toml' tag are included, nested structs without any 'toml' tag are keyed by field name like the toml decoder does, so secrets in them are redacted as well
+func EffectiveToml(config any) map[string]any {
+	effective, _ := effectiveTomlValue(reflect.ValueOf(config)).(map[string]any)
+	return effective
+}
+
+func effectiveTomlValue(v reflect.Value) any {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if secret, ok := v.Interface().(SecretString); ok {
+		if secret.Ref() != "" {
+			return secret.ConfigText()
+		}
+		if secret.GetSecret() != "" {
+			return REDACTED_SECRET
+		}
+		return ""
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if !hasExportedFields(v.Type()) {
+			return v.Interface()
+		}
+		result := map[string]any{}
+		tagged := hasTomlFields(v.Type())
+		internalTags := GetIndexForTag(v.Interface(), "internal")
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			tag, hasTag := field.Tag.Lookup("toml")
+			if _, internal := field.Tag.Lookup("internal"); internal || tag == "-" || !field.IsExported() {
+				continue
+			}
+			if !hasTag && tagged {
+				continue
+			}
+			if tag == "" {
+				tag = field.Name // same as toml encoder
+			}
+			if index, ok := internalTags[tag]; ok {
+				result[tag] = internalTomlValue(v.Field(index), v.Type().Field(index).Tag.Get("parsetype"))
+				continue
+			}
+			if value := effectiveTomlValue(v.Field(i)); value != nil {
+				result[tag] = value
+			}
+		}
+		return result
+	case reflect.Map:
+		result := map[string]any{}
+		iter := v.MapRange()
+		for iter.Next() {
+			if value := effectiveTomlValue(iter.Value()); value != nil {
+				result[iter.Key().String()] = value
+			}
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		result := make([]any, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			result = append(result, effectiveTomlValue(v.Index(i)))
+		}
+		return result
+	default:
+		return v.Interface()
+	}
+}
+
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+// format parsed internal value like it is written in the config file
+func internalTomlValue(v reflect.Value, parseType string) any {
+	switch value := v.Interface().(type) {
+	case time.Duration:
+		return value.String()
+	case log.Level:
+		return strings.ToLower(value.String())
+	case float32:
+		if parseType == "percentage" {
+			return FancyFloat(float64(value)*100) + "%"
+		}
+	}
+	return v.Interface()
+}
@@ -0,0 +1,66 @@
+package helper_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.cc/apibase/helper"
+)
+
+type effectiveTestSettings struct {
+	TomlTimeout string `toml:"timeout"`
+	TomlMargin  string `toml:"margin"`
+
+	Timeout time.Duration `internal:"timeout"`
+	Margin  float32       `internal:"margin" parsetype:"percentage"`
+}
+
+type effectiveTestConfig struct {
+	Name     string                                       `toml:"name"`
+	Password helper.SecretString                          `toml:"password"`
+	Empty    helper.SecretString                          `toml:"empty"`
+	Settings *effectiveTestSettings                       `toml:"settings"`
+	Nested   map[string]struct{ Key helper.SecretString } `toml:"nested"`
+	Internal chan struct{}
+}
+
+func TestEffectiveToml(t *testing.T) {
+	settings := &effectiveTestSettings{TomlTimeout: "2m"}
+	if err := helper.ParseTomlConfigAndDefaults(settings, &effectiveTestSettings{Timeout: time.Second, Margin: 0.2}); err != nil {
+		t.Fatal(err)
+	}
+	config := effectiveTestConfig{
+		Name:     "app",
+		Password: helper.CreateSecretString("secret"),
+		Settings: settings,
+		Nested:   map[string]struct{ Key helper.SecretString }{"a": {Key: helper.CreateSecretString("secret")}},
+	}
+	want := map[string]any{
+		"name":     "app",
+		"password": helper.REDACTED_SECRET,
+		"empty":    "",
+		"settings": map[string]any{"timeout": "2m0s", "margin": "20%"},
+		"nested":   map[string]any{"a": map[string]any{"Key": helper.REDACTED_SECRET}},
+	}
+	if got := helper.EffectiveToml(&config); !reflect.DeepEqual(got, want) {
+		t.Errorf("EffectiveToml() = %v, want %v", got, want)
+	}
+}
+
+func TestCheckTomlConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings effectiveTestSettings
+		problems int
+	}{
+		{"unset", effectiveTestSettings{}, 0},
+		{"valid", effectiveTestSettings{TomlTimeout: "1h", TomlMargin: "10%"}, 0},
+		{"invalid", effectiveTestSettings{TomlTimeout: "forever", TomlMargin: "lots"}, 2},
+	}
+	for _, tt := range tests {
+		if problems := helper.CheckTomlConfig(&tt.settings); len(problems) != tt.problems {
+			t.Errorf("%s: CheckTomlConfig() = %v, want %d problems", tt.name, problems, tt.problems)
+		}
+	}
+}
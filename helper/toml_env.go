@@ -0,0 +1,286 @@
+package helper
+
+import (
+	"context"
+	"encoding"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.cc/apibase/errx"
+)
+
+// Effective source of config values, mapped by dotted toml key, e.g. "postgres.password".
+// Keys that are missing are either unset or set from defaults
+type ConfigSources map[string]string
+
+const (
+	SourceToml = "toml"
+	SourceEnv  = "env"
+	SourceFile = "file"
+)
+
+// Overwrite any field with 'toml' tag of config from environment variables, named after the uppercase dotted toml key
+// with '.' replaced by '_' and prefix, e.g. APIBASE_POSTGRES_PASSWORD for key "postgres.password" and prefix "APIBASE".
+// SecretString fields may also be read from a file, whose path is set by the same env var with suffix _FILE, e.g. APIBASE_POSTGRES_PASSWORD_FILE.
+// Map entries (e.g. [email.<key>]) that don't exist in config are created from env vars as well.
+//
+// Precedence (lowest to highest): defaults < toml < toml '<key>_file' < env < env '<KEY>_FILE'
+//
+// slices are read as comma separated list, time.Duration as duration string (e.g. "1h30m")
+func OverlayEnv[T any](config *T, prefix string, sources ConfigSources) error {
+	_, err := overlayEnvValue(reflect.ValueOf(config).Elem(), []string{}, strings.ToUpper(prefix), sources)
+	return err
+}
+
+// Set SecretString field with dotted toml key (e.g. "postgres.password") from content of file at path,
+// a single trailing newline is removed
+func SetSecretFromFile[T any](config *T, key string, path string) error {
+	found := false
+	err := walkTomlLeafs(reflect.ValueOf(config).Elem(), []string{}, func(field reflect.Value, fieldKey []string) error {
+		if strings.Join(fieldKey, ".") != key {
+			return nil
+		}
+		if field.Type() != reflect.TypeFor[SecretString]() {
+			return errx.Newf("config key '%s' isn't a secret, reading from file is not supported", key)
+		}
+		found = true
+		return setSecretFile(field, path)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errx.Newf("config key '%s' doesn't exist", key)
+	}
+	return nil
+}
+
+// Get env var name for dotted toml key, prefix may be empty
+func EnvName(prefix string, key string) string {
+	name := strings.ToUpper(key)
+	if prefix != "" {
+		name = strings.ToUpper(prefix) + "_" + name
+	}
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// returns true if any value was set
+func overlayEnvValue(v reflect.Value, key []string, prefix string, sources ConfigSources) (bool, error) {
+	switch {
+	case v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct:
+		if !v.IsNil() {
+			return overlayEnvValue(v.Elem(), key, prefix, sources)
+		}
+		// only allocate struct if at least one value is set from env
+		tmp := reflect.New(v.Type().Elem())
+		set, err := overlayEnvValue(tmp.Elem(), key, prefix, sources)
+		if set {
+			v.Set(tmp)
+		}
+		return set, err
+	case v.Kind() == reflect.Struct && v.Type() != reflect.TypeFor[SecretString]() && hasTomlFields(v.Type()):
+		anySet := false
+		for i := 0; i < v.NumField(); i++ {
+			tag, ok := v.Type().Field(i).Tag.Lookup("toml")
+			if !ok || tag == "" || tag == "-" || !v.Type().Field(i).IsExported() {
+				continue
+			}
+			set, err := overlayEnvValue(v.Field(i), append(key, tag), prefix, sources)
+			if err != nil {
+				return anySet, err
+			}
+			anySet = anySet || set
+		}
+		return anySet, nil
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String && isStructOrPtr(v.Type().Elem()):
+		anySet := false
+		for _, mapKey := range envMapKeys(v, key, prefix) {
+			k := reflect.ValueOf(mapKey).Convert(v.Type().Key())
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if existing := v.MapIndex(k); existing.IsValid() {
+				elem.Set(existing)
+			}
+			set, err := overlayEnvValue(elem, append(key, mapKey), prefix, sources)
+			if err != nil {
+				return anySet, err
+			}
+			if !set {
+				continue
+			}
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+			v.SetMapIndex(k, elem)
+			anySet = true
+		}
+		return anySet, nil
+	}
+
+	set := false
+	dottedKey := strings.Join(key, ".")
+	name := EnvName(prefix, dottedKey)
+	if value, ok := os.LookupEnv(name); ok {
+		if err := setFromString(v, value); err != nil {
+			return set, errx.Wrapf(err, "unable to set config key '%s' from env %s", dottedKey, name)
+		}
+		sources[dottedKey] = SourceEnv + " " + name
+		set = true
+	}
+	if v.Type() != reflect.TypeFor[SecretString]() {
+		return set, nil
+	}
+	if path, ok := os.LookupEnv(name + "_FILE"); ok {
+		if err := setSecretFile(v, path); err != nil {
+			return set, errx.Wrapf(err, "unable to set config key '%s' from env %s_FILE", dottedKey, name)
+		}
+		sources[dottedKey] = SourceFile + " " + path
+		set = true
+	}
+	return set, nil
+}
+
+// existing map keys and keys found in env vars, e.g. "default" for APIBASE_EMAIL_DEFAULT_HOST
+func envMapKeys(m reflect.Value, key []string, prefix string) []string {
+	keys := []string{}
+	known := map[string]bool{}
+	for _, k := range m.MapKeys() {
+		keys = append(keys, k.String())
+		known[EnvName("", k.String())] = true
+	}
+	suffixes := []string{}
+	walkTomlLeafs(reflect.New(m.Type().Elem()).Elem(), []string{}, func(field reflect.Value, fieldKey []string) error {
+		suffix := EnvName("", strings.Join(fieldKey, "."))
+		suffixes = append(suffixes, suffix)
+		if field.Type() == reflect.TypeFor[SecretString]() {
+			suffixes = append(suffixes, suffix+"_FILE")
+		}
+		return nil
+	})
+	// longest suffix first, e.g. SMTP_HOST must not be matched as key SMTP with suffix HOST
+	slices.SortFunc(suffixes, func(a, b string) int { return len(b) - len(a) })
+	mapPrefix := EnvName(prefix, strings.Join(key, ".")) + "_"
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		rest, ok := strings.CutPrefix(name, mapPrefix)
+		if !ok {
+			continue
+		}
+		for _, suffix := range suffixes {
+			mapKey, ok := strings.CutSuffix(rest, suffix)
+			if !ok || len(mapKey) < 2 || !strings.HasSuffix(mapKey, "_") {
+				continue
+			}
+			mapKey = strings.TrimSuffix(mapKey, "_")
+			if !known[mapKey] {
+				known[mapKey] = true
+				keys = append(keys, strings.ToLower(mapKey))
+			}
+			break
+		}
+	}
+	return keys
+}
+
+// call fn for every non-struct value with 'toml' tag, nil pointers are skipped
+func walkTomlLeafs(v reflect.Value, key []string, fn func(field reflect.Value, key []string) error) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || v.Type() == reflect.TypeFor[SecretString]() || !hasTomlFields(v.Type()) {
+		return fn(v, key)
+	}
+	for i := 0; i < v.NumField(); i++ {
+		tag, ok := v.Type().Field(i).Tag.Lookup("toml")
+		if !ok || tag == "" || tag == "-" || !v.Type().Field(i).IsExported() {
+			continue
+		}
+		if err := walkTomlLeafs(v.Field(i), append(key, tag), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setSecretFile(v reflect.Value, path string) error {
+	secret, err := FileSecretProvider{}.Fetch(context.Background(), path)
+	if err != nil {
+		return errx.Wrapf(err, "unable to read secret file '%s'", path)
+	}
+	v.Set(reflect.ValueOf(CreateSecretString(secret)))
+	return nil
+}
+
+func setFromString(v reflect.Value, value string) error {
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(value))
+		}
+	}
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		d, err := StringToDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := []string{}
+		if value != "" {
+			items = strings.Split(value, ",")
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setFromString(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return errx.Newf("unsupported type %s", v.Type().String())
+	}
+	return nil
+}
+
+func isStructOrPtr(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct || (t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct)
+}
@@ -0,0 +1,88 @@
+package helper_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"gopkg.cc/apibase/helper"
+)
+
+type envTestMail struct {
+	Host     string              `toml:"host"`
+	SmtpHost string              `toml:"smtp_host"`
+	Password helper.SecretString `toml:"password"`
+}
+
+type envTestSettings struct {
+	Timeout time.Duration `toml:"timeout"`
+}
+
+type envTestConfig struct {
+	Name     string                 `toml:"name"`
+	Port     int                    `toml:"port"`
+	Enabled  bool                   `toml:"enabled"`
+	CORS     []string               `toml:"cors"`
+	Password helper.SecretString    `toml:"password"`
+	Settings *envTestSettings       `toml:"settings"`
+	Email    map[string]envTestMail `toml:"email"`
+	Internal string
+}
+
+func TestOverlayEnv(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_NAME", "env-name")
+	t.Setenv("TEST_PORT", "8080")
+	t.Setenv("TEST_ENABLED", "true")
+	t.Setenv("TEST_CORS", "https://a.example, https://b.example")
+	t.Setenv("TEST_PASSWORD", "from-env")
+	t.Setenv("TEST_PASSWORD_FILE", secretFile)
+	t.Setenv("TEST_SETTINGS_TIMEOUT", "1m30s")
+	t.Setenv("TEST_EMAIL_DEFAULT_HOST", "smtp.example")
+	t.Setenv("TEST_EMAIL_NOREPLY_SMTP_HOST", "smtp2.example")
+
+	config := &envTestConfig{Name: "toml-name", Email: map[string]envTestMail{"default": {Host: "old"}}}
+	sources := helper.ConfigSources{"name": helper.SourceToml}
+	if err := helper.OverlayEnv(config, "test", sources); err != nil {
+		t.Fatalf("OverlayEnv() = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"string", config.Name, "env-name"},
+		{"int", config.Port, 8080},
+		{"bool", config.Enabled, true},
+		{"file has precedence over env", config.Password.GetSecret(), "from-file"},
+		{"nil pointer allocated", config.Settings != nil && config.Settings.Timeout == 90*time.Second, true},
+		{"existing map entry", config.Email["default"].Host, "smtp.example"},
+		{"new map entry", config.Email["noreply"].SmtpHost, "smtp2.example"},
+		{"source env", sources["name"], "env TEST_NAME"},
+		{"source file", sources["password"], "file " + secretFile},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: got %v; want %v", test.name, test.got, test.want)
+		}
+	}
+	if !slices.Equal(config.CORS, []string{"https://a.example", "https://b.example"}) {
+		t.Errorf("slice: got %v", config.CORS)
+	}
+	if _, ok := config.Email["noreply_smtp"]; ok {
+		t.Errorf("map key: noreply_smtp must not be created")
+	}
+}
+
+func TestOverlayEnvInvalid(t *testing.T) {
+	t.Setenv("TEST_PORT", "not-a-number")
+	if err := helper.OverlayEnv(&envTestConfig{}, "test", helper.ConfigSources{}); err == nil {
+		t.Errorf("OverlayEnv() with invalid int = nil; want error")
+	}
+}
@@ -14,6 +14,8 @@ import (
 // Alongside the "internal" tag there may exist a tag called "parsetype" which processes the config value in a special way:
 //
 // parsetype:"percentage" - parses a percentage string (e.g. "20%") from field with 'toml' tag to float32 in field with 'internal' tag
+//
+// parsetype:"loglevel" - parses a log level name (e.g. "debug") from field with 'toml' tag to log.Level in field with 'internal' tag
 func ParseTomlConfigAndDefaults[T any](config *T, defaults *T) error {
 	if reflect.TypeOf(defaults).Kind() != reflect.Ptr ||
 		reflect.ValueOf(defaults).Elem().Kind() != reflect.Struct {
@@ -70,12 +72,26 @@ func ParseTomlConfigAndDefaults[T any](config *T, defaults *T) error {
 					tomlTag,
 					configStruct.Type().String(),
 					configStruct.Field(i).Interface(),
-					FancyFloat(defaultsStruct.Field(dataIndex).Interface().(float64)*100),
+					FancyFloat(float64(defaultsStruct.Field(dataIndex).Interface().(float32))*100),
 				)
 				configStruct.Field(dataIndex).Set(defaultsStruct.Field(dataIndex))
 				continue
 			}
 			configStruct.Field(dataIndex).Set(reflect.ValueOf(percentage))
+		} else if configFieldType == reflect.TypeOf(log.Level(0)) && isConfigString && hasParseType && parseType == "loglevel" {
+			level, err := log.ParseLevel(configString)
+			if err != nil || configStruct.Field(i).IsZero() {
+				log.Logf(log.LevelWarning,
+					"Unable to parse field with toml tag %s of struct %s: '%v', assuming default '%s'",
+					tomlTag,
+					configStruct.Type().String(),
+					configStruct.Field(i).Interface(),
+					defaultsStruct.Field(dataIndex).Interface().(log.Level).String(),
+				)
+				configStruct.Field(dataIndex).Set(defaultsStruct.Field(dataIndex))
+				continue
+			}
+			configStruct.Field(dataIndex).Set(reflect.ValueOf(level))
 		} else if configStruct.Field(i).IsZero() {
 			log.Logf(log.LevelWarning,
 				"Unable to parse field with toml tag %s of struct %s: '%v', assuming default '%v'",
@@ -108,3 +124,37 @@ func GetIndexForTag(data any, tag string) map[string]int {
 	}
 	return result
 }
+
+// Check every field with 'toml' tag of config, which is parsed into a field with 'internal' tag by ParseTomlConfigAndDefaults(),
+// returns an error for every set value that can't be parsed instead of logging it and assuming the default
+func CheckTomlConfig[T any](config *T) []error {
+	problems := []error{}
+	configStruct := reflect.ValueOf(config).Elem()
+	if configStruct.Kind() != reflect.Struct {
+		return append(problems, errx.New("config must be a pointer to a struct"))
+	}
+	internalTags := GetIndexForTag(config, "internal")
+	for i := 0; i < configStruct.NumField(); i++ {
+		tomlTag, ok := configStruct.Type().Field(i).Tag.Lookup("toml")
+		dataIndex, hasInternal := internalTags[tomlTag]
+		configString, isConfigString := configStruct.Field(i).Interface().(string)
+		if !ok || !hasInternal || !isConfigString || configString == "" {
+			continue
+		}
+		var err error
+		configFieldType := configStruct.Field(dataIndex).Type()
+		parseType := configStruct.Type().Field(dataIndex).Tag.Get("parsetype")
+		switch {
+		case configFieldType == reflect.TypeOf(time.Duration(0)):
+			_, err = StringToDuration(configString)
+		case configFieldType == reflect.TypeOf(float32(0)) && parseType == "percentage":
+			_, err = PercentageToFloat32(configString)
+		case configFieldType == reflect.TypeOf(log.Level(0)) && parseType == "loglevel":
+			_, err = log.ParseLevel(configString)
+		}
+		if err != nil {
+			problems = append(problems, errx.WrapWithTypef(ErrConfigValue, err, "%s = '%s'", tomlTag, configString))
+		}
+	}
+	return problems
+}
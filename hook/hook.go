@@ -9,6 +9,7 @@ import (
 type (
 	PreSignupHook         func(username string, email string, password helper.SecretString) error
 	SignupDefaultRoleHook func(user table.User) ([]table.UserRole, error)
+	SignupTenantHook      func(user table.User) (table.Organization, error)
 	PostSignupHook        func(user table.User, roles []table.UserRole) error
 	PreLoginHook          func(username string, password helper.SecretString) error
 	PostLoginHook         func(user table.User, roles []table.UserRole) error
@@ -18,6 +19,7 @@ type (
 type Hooks struct {
 	PreSignup         []PreSignupHook
 	SignupDefaultRole []SignupDefaultRoleHook
+	SignupTenant      []SignupTenantHook
 	PostSignup        []PostSignupHook
 	PreLogin          []PreLoginHook
 	PostLogin         []PostLoginHook
@@ -38,6 +40,13 @@ func RegisterSignupDefaultRoleHook(hook SignupDefaultRoleHook) {
 	RegisteredHooks.SignupDefaultRole = []SignupDefaultRoleHook{hook}
 }
 
+// Provides ability to create another tenant than the default organization "userorg-<name>" for a new user,
+// e.g. of an own tenant type with an external id. Only runs if the SignupDefaultRole hook returned no roles,
+// return a tenant without name to create the default organization
+func RegisterSignupTenantHook(hook SignupTenantHook) {
+	RegisteredHooks.SignupTenant = []SignupTenantHook{hook}
+}
+
 // Runs before email confirmation, doesn't prevent signup on error, will be logged
 func RegisterPostSignupHooks(hooks ...PostSignupHook) {
 	RegisteredHooks.PostSignup = append(RegisteredHooks.PostSignup, hooks...)
@@ -70,13 +70,20 @@ func AddLogger(safeWriter *SafeWriter) {
 	// TODO: tryout this new logger to make sure it doesn't panic, log to info
 }
 
-// set log level, this should be done only once in the beginning
+// set log level, may also be changed at runtime, e.g. on config reload
 func SetLogLevel(level Level) {
 	loggerMutex.Lock()
 	logger.Level = level
 	loggerMutex.Unlock()
 }
 
+// get currently set log level
+func GetLogLevel() Level {
+	loggerMutex.RLock()
+	defer loggerMutex.RUnlock()
+	return logger.Level
+}
+
 func Log(l Level, s string) {
 	loggerMutex.RLock()
 	log := logger
@@ -1,7 +1,9 @@
 package log
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/labstack/gommon/color"
 )
@@ -40,6 +42,27 @@ func (i Level) String() string {
 	return _Level_name[i*4 : i*4+4]
 }
 
+// Parse log level from its name (e.g. "debug", "warning") or its short form (e.g. "DBUG", "WARN"), case insensitive
+func ParseLevel(level string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "devel", "devl":
+		return LevelDevel, nil
+	case "debug", "dbug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "notice", "note":
+		return LevelNotice, nil
+	case "warning", "warn":
+		return LevelWarning, nil
+	case "error", "eror":
+		return LevelError, nil
+	case "critical", "crit":
+		return LevelCritical, nil
+	}
+	return defaultLogLevel, fmt.Errorf("unknown log level '%s'", level)
+}
+
 func (i Level) StringColored(armoring Armoring) string {
 	var levelColored string
 	switch i {
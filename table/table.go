@@ -3,26 +3,34 @@ package table
 import (
 	"time"
 
+	"github.com/gofrs/uuid/v5"
 	h "gopkg.cc/apibase/helper"
 )
 
+// New time ordered UUID (version 7) for primary keys, consecutive keys are close in indexes
+func NewID() uuid.UUID {
+	return uuid.Must(uuid.NewV7())
+}
+
 type User struct {
-	ID             int            `db:"id" default:"true" table:"users"`
+	ID             uuid.UUID      `db:"id" default:"true" table:"users"`
 	Name           string         `db:"name"`
 	AuthProvider   string         `db:"auth_provider"`
 	Email          string         `db:"email"`
 	EmailVerified  bool           `db:"email_verified"`
 	PasswordHash   h.SecretString `db:"password_hash"`
 	SecretsVersion int            `db:"secrets_version"`
-	TotpSecret     string         `db:"totp_secret"`
+	TotpSecret     string         `db:"totp_secret" audit:"-"`
 	SuperAdmin     bool           `db:"super_admin"`
+	Disabled       bool           `db:"disabled"` // disabled users can't login and their sessions are revoked
 	CreatedAt      time.Time      `db:"created_at" default:"true"`
 	UpdatedAt      time.Time      `db:"updated_at" default:"true"`
+	DeletedAt      *time.Time     `db:"deleted_at"` // soft deleted, until purged
 }
 
 type RefreshToken struct {
-	ID           int            `db:"id" default:"true" table:"refresh_tokens"`
-	UserID       int            `db:"user_id"`
+	ID           uuid.UUID      `db:"id" default:"true" table:"refresh_tokens"`
+	UserID       uuid.UUID      `db:"user_id"`
 	SessionID    h.SecretString `db:"session_id"`
 	ReissueCount int            `db:"reissue_count"`
 	UserAgent    string         `db:"user_agent"`
@@ -31,29 +39,68 @@ type RefreshToken struct {
 	UpdatedAt    time.Time      `db:"updated_at" default:"true"`
 }
 
+// tenant types of organizations created by apibase, applications may use own types (e.g. "team" or "customer")
+const (
+	TenantTypeUser = "user" // default organization created for a new user
+	TenantTypeOrg  = "org"  // used if no type is set
+)
+
+// Organizations are the tenants, roles and scheduled tasks belong to one of them
 type Organization struct {
-	ID          int    `db:"id" default:"true" table:"organizations"`
-	Name        string `db:"name"`
-	Description string `db:"description"`
+	ID          uuid.UUID  `db:"id" default:"true" table:"organizations"`
+	Name        string     `db:"name"`
+	Description string     `db:"description"`
+	TenantType  string     `db:"tenant_type"`
+	Slug        string     `db:"slug"`        // unique, generated from name if empty
+	ExternalID  string     `db:"external_id"` // optional id of the tenant in another system, unique per tenant type
+	Metadata    string     `db:"metadata"`    // json object
+	DeletedAt   *time.Time `db:"deleted_at"`
 }
 
 type UserRole struct {
-	ID       int  `db:"id" default:"true" table:"user_roles"`
-	UserID   int  `db:"user_id"`
-	OrgID    int  `db:"org_id"`
-	OrgView  bool `db:"org_view"`
-	OrgEdit  bool `db:"org_edit"`
-	OrgAdmin bool `db:"org_admin"`
+	ID        uuid.UUID  `db:"id" default:"true" table:"user_roles"`
+	UserID    uuid.UUID  `db:"user_id"`
+	OrgID     uuid.UUID  `db:"org_id"`
+	OrgView   bool       `db:"org_view"`
+	OrgEdit   bool       `db:"org_edit"`
+	OrgAdmin  bool       `db:"org_admin"`
+	DeletedAt *time.Time `db:"deleted_at"`
 }
 
 type ScheduledTask struct {
-	ID        int       `db:"id" default:"true" table:"scheduled_tasks"`
-	TaskID    string    `db:"task_id"`
-	OrgID     int       `db:"org_id"`
-	StartDate time.Time `db:"start_date"`
-	Interval  Duration  `db:"interval"`
-	TaskType  string    `db:"task_type"`
-	TaskData  string    `db:"task_data"`
-	CreatedAt time.Time `db:"created_at" default:"true"`
-	UpdatedAt time.Time `db:"updated_at" default:"true"`
+	ID        uuid.UUID  `db:"id" default:"true" table:"scheduled_tasks"`
+	TaskID    string     `db:"task_id"`
+	OrgID     uuid.UUID  `db:"org_id"`
+	StartDate time.Time  `db:"start_date"`
+	Interval  Duration   `db:"interval"`
+	TaskType  string     `db:"task_type"`
+	TaskData  string     `db:"task_data"`
+	CreatedAt time.Time  `db:"created_at" default:"true"`
+	UpdatedAt time.Time  `db:"updated_at" default:"true"`
+	DeletedAt *time.Time `db:"deleted_at"`
+}
+
+// actions of audit events recorded by apibase, applications may record own actions
+const (
+	AuditSignup         = "user.signup"
+	AuditLogin          = "user.login"
+	AuditLoginFailed    = "user.login_failed"
+	AuditLogout         = "user.logout"
+	AuditSessionsRevoke = "user.sessions_revoke"
+	AuditRoleGrant      = "role.grant"
+	AuditTaskCreate     = "task.create"
+	AuditTaskUpdate     = "task.update"
+	AuditTaskDelete     = "task.delete"
+)
+
+type AuditEvent struct {
+	ID        uuid.UUID  `db:"id" default:"true" table:"audit_events"`
+	ActorID   *uuid.UUID `db:"actor_id"` // nil for actions without logged in user, e.g. failed logins or cli commands
+	OrgID     *uuid.UUID `db:"org_id"`
+	Action    string     `db:"action"`
+	Target    string     `db:"target"` // affected entry, e.g. "user:<id>" or "task:<task id>"
+	IP        string     `db:"ip"`
+	UserAgent string     `db:"user_agent"`
+	Diff      string     `db:"diff"` // json object of changed columns, see db.AuditDiff()
+	CreatedAt time.Time  `db:"created_at" default:"true"`
 }
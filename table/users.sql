@@ -1,13 +0,0 @@
-CREATE TABLE users (
-    id SERIAL PRIMARY KEY,
-    name VARCHAR(255) UNIQUE NOT NULL,
-    auth_provider TEXT NOT NULL,
-    email VARCHAR(255) UNIQUE NOT NULL,
-    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
-    password_hash TEXT NOT NULL,
-    secrets_version INTEGER NOT NULL,
-    totp_secret TEXT NOT NULL DEFAULT '',
-    super_admin BOOLEAN DEFAULT FALSE,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-);
\ No newline at end of file
@@ -0,0 +1,23 @@
+package web
+
+import (
+	"github.com/gofrs/uuid/v5"
+	"github.com/labstack/echo/v4"
+	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/log"
+	"gopkg.cc/apibase/table"
+)
+
+// Attribute audit events recorded with the request context to userID (uuid.Nil if not logged in) and the ip and user agent of the request.
+// Done by AuthJWT() for every authenticated request
+func SetAuditActor(c echo.Context, userID uuid.UUID) {
+	actor := db.AuditActor{UserID: userID, IP: c.RealIP(), UserAgent: c.Request().UserAgent()}
+	c.SetRequest(c.Request().WithContext(db.WithAuditActor(c.Request().Context(), actor)))
+}
+
+// Record audit event attributed to the actor of the request, errors are logged
+func Audit(c echo.Context, api *ApiServer, event table.AuditEvent) {
+	if err := api.DB.CreateAuditEvent(c.Request().Context(), event); err != nil {
+		log.Logf(log.LevelError, "unable to record audit event '%s' for '%s': %s", event.Action, event.Target, err.Error())
+	}
+}
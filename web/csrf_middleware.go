@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 	h "gopkg.cc/apibase/helper"
 	"gopkg.cc/apibase/log"
@@ -43,25 +44,33 @@ func GetCSRF(api *ApiServer) echo.HandlerFunc {
 func UpdateCSRF(c echo.Context, api *ApiServer, sessionId h.SecretString) {
 	var csrfToken h.SecretString
 	if sessionId.GetSecret() == "" {
-		csrfToken = createCSRF(api, getSessionId(c, api.Config.TokenSecretBytes()))
+		csrfToken = createCSRF(api, getSessionId(c, api.verificationKey))
 	} else {
 		csrfToken = createCSRF(api, h.CreateSecretString(""))
 	}
 
-	expiresIn := api.Config.AddCookieExpiryMargin(api.Config.Settings.TokenRefreshValidity)
+	expiresIn := api.AddCookieExpiryMargin(api.Settings().TokenRefreshValidity)
 	csrfCookie := &http.Cookie{Name: "csrf_token", Value: csrfToken.GetSecret(), Path: "/", Expires: time.Now().Add(expiresIn)}
 	h.OverwriteRequestCookie(c.Request(), csrfCookie) // set cookie for current request
 	c.SetCookie(csrfCookie)                           // set cookie for response
 }
 
 func createCSRF(api *ApiServer, sessionId h.SecretString) h.SecretString {
-	hash := hmac.New(sha256.New, api.Config.TokenSecretBytes())
+	hash := hmac.New(sha256.New, api.csrfKey())
 	hash.Write([]byte(h.RandomString(16) + sessionId.GetSecret()))
 	return h.CreateSecretString(base64.RawURLEncoding.EncodeToString(hash.Sum(nil)))
 }
 
-func getSessionId(c echo.Context, tokenSecret []byte) h.SecretString {
-	refreshToken, err := parseRefreshTokenCookie(c, tokenSecret)
+// csrf tokens are keyed with the active signing key, which is also set if only ApiConfig.KeyringFile is configured
+func (api *ApiServer) csrfKey() []byte {
+	if key, ok := api.activeKeyring().SigningKey(time.Now()); ok {
+		return key.secretBytes
+	}
+	return api.TokenSecretBytes()
+}
+
+func getSessionId(c echo.Context, keyFunc jwt.Keyfunc) h.SecretString {
+	refreshToken, err := parseRefreshTokenCookie(c, keyFunc)
 	if err != nil {
 		return h.CreateSecretString("")
 	}
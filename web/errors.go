@@ -8,4 +8,5 @@ var (
 	ErrAccessClaimsParsing = errx.NewType("unable to parse access claims")
 	ErrAccessClaimDataNil  = errx.NewType("access claim data is nil")
 	ErrFsKindNotEmbed      = errx.NewType("filesystem kind isn't embedfs")
+	ErrKeyring             = errx.NewType("keyring error")
 )
@@ -3,6 +3,7 @@ package web
 import (
 	"time"
 
+	"github.com/gofrs/uuid/v5"
 	"github.com/golang-jwt/jwt/v5"
 	h "gopkg.cc/apibase/helper"
 )
@@ -10,27 +11,26 @@ import (
 // Access Token
 
 // If changes are made to JwtAccessClaims, this revision uint must be incremented
-const LatestAccessTokenRevision uint = 1
+const LatestAccessTokenRevision uint = 2
 
 // intentionally obfuscated json keys for security and bandwidth savings
 type jwtAccessClaims[T any] struct {
-	UserID     int      `json:"a"`
-	Roles      JwtRoles `json:"b"`
-	SuperAdmin bool     `json:"c"`
-	Data       T        `json:"d"`
-	Revision   uint     `json:"e"`
+	UserID     uuid.UUID `json:"a"`
+	Roles      JwtRoles  `json:"b"`
+	SuperAdmin bool      `json:"c"`
+	Data       T         `json:"d"`
+	Revision   uint      `json:"e"`
 	jwt.RegisteredClaims
 }
 
 func (claims *jwtAccessClaims[any]) SignToken(api *ApiServer) (string, error) {
 	now := time.Now()
 	claims.IssuedAt = jwt.NewNumericDate(now)
-	claims.ExpiresAt = jwt.NewNumericDate(now.Add(api.Config.Settings.TokenAccessValidity))
-	rawToken := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-	return rawToken.SignedString(api.Config.TokenSecretBytes())
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(api.Settings().TokenAccessValidity))
+	return api.signToken(claims)
 }
 
-func CreateJwtAccessClaims[T any](userID int, roles JwtRoles, superAdmin bool, data T) *jwtAccessClaims[T] {
+func CreateJwtAccessClaims[T any](userID uuid.UUID, roles JwtRoles, superAdmin bool, data T) *jwtAccessClaims[T] {
 	return &jwtAccessClaims[T]{
 		UserID:     userID,
 		Roles:      roles,
@@ -43,11 +43,11 @@ func CreateJwtAccessClaims[T any](userID int, roles JwtRoles, superAdmin bool, d
 // Refresh Token
 
 // If changes are made to JwtAccessClaims, this revision uint must be incremented
-const LatestRefreshTokenRevision uint = 1
+const LatestRefreshTokenRevision uint = 2
 
 // intentionally obfuscated json keys for security and bandwidth savings
 type jwtRefreshClaims struct {
-	UserID    int            `json:"a"`
+	UserID    uuid.UUID      `json:"a"`
 	SessionID h.SecretString `json:"b"`
 	Revision  uint           `json:"c"`
 	jwt.RegisteredClaims
@@ -56,15 +56,13 @@ type jwtRefreshClaims struct {
 func (claims *jwtRefreshClaims) signToken(api *ApiServer) (string, time.Time, error) {
 	now := time.Now()
 	claims.IssuedAt = jwt.NewNumericDate(now)
-	expiresAt := now.Add(api.Config.Settings.TokenRefreshValidity)
+	expiresAt := now.Add(api.Settings().TokenRefreshValidity)
 	claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
-	rawToken := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-	token, err := rawToken.SignedString(api.Config.TokenSecretBytes())
+	token, err := api.signToken(claims)
 	return token, expiresAt, err
-
 }
 
-func createJwtRefreshClaims(userID int, sessionId h.SecretString) *jwtRefreshClaims {
+func createJwtRefreshClaims(userID uuid.UUID, sessionId h.SecretString) *jwtRefreshClaims {
 	return &jwtRefreshClaims{
 		UserID:    userID,
 		SessionID: sessionId,
@@ -10,7 +10,7 @@ import (
 // Get access claims with optional custom data.
 // To correctly parse access claim data, initialize empty struct of correct type using: api.GetAccessClaimDataType() or new(<your_custom_struct_type>)
 func GetAccessClaims[T any](c echo.Context, api *ApiServer, data T) (*jwtAccessClaims[T], error) {
-	accessToken, err := parseAccessTokenCookie(c, api.Config.TokenSecretBytes(), data)
+	accessToken, err := parseAccessTokenCookie(c, api.verificationKey, data)
 	if err != nil {
 		return &jwtAccessClaims[T]{}, err
 	}
@@ -1,10 +1,12 @@
 package web
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"gopkg.cc/apibase/db"
 	"gopkg.cc/apibase/errx"
 	h "gopkg.cc/apibase/helper"
 	"gopkg.cc/apibase/log"
@@ -33,15 +35,16 @@ func AuthJWT(api *ApiServer) echo.MiddlewareFunc {
 
 func AuthJwtHandler(c echo.Context, api *ApiServer) error {
 	// Verify Access Token
-	accessToken, err := parseAccessTokenCookie(c, api.Config.TokenSecretBytes(), api.GetAccessClaimDataType())
+	accessToken, err := parseAccessTokenCookie(c, api.verificationKey, api.GetAccessClaimDataType())
 	var oldAccessClaims *jwtAccessClaims[any]
 	if err == nil {
 		accessClaims, ok := accessToken.Claims.(*jwtAccessClaims[any])
 		if ok {
 			accessTokenExpire, err := accessClaims.GetExpirationTime()
 			if accessToken.Valid && err == nil && accessClaims.Revision == LatestAccessTokenRevision {
-				if accessTokenExpire.Time.Add(-api.Config.Settings.TokenAccessRenewMargin).After(time.Now()) {
+				if accessTokenExpire.Time.Add(-api.Settings().TokenAccessRenewMargin).After(time.Now()) {
 					// Do nothing, access token is still valid for long enough
+					SetAuditActor(c, accessClaims.UserID)
 					return nil
 				}
 				oldAccessClaims = accessClaims
@@ -50,7 +53,7 @@ func AuthJwtHandler(c echo.Context, api *ApiServer) error {
 	}
 
 	// Verify Refresh Token
-	refreshToken, err := parseRefreshTokenCookie(c, api.Config.TokenSecretBytes())
+	refreshToken, err := parseRefreshTokenCookie(c, api.verificationKey)
 	if err != nil {
 		// log.Logf(log.LevelDebug, "unable to parse refresh token from cookie, request: %s", c.Request().URL.String())
 		return wr.NewErrorWithStatus(http.StatusUnauthorized, wr.RespErrJwtRefreshTokenParsing, nil)
@@ -60,13 +63,20 @@ func AuthJwtHandler(c echo.Context, api *ApiServer) error {
 		// log.Logf(log.LevelDebug, "unable to parse refresh token claims, request: %s", c.Request().URL.String())
 		return wr.NewErrorWithStatus(http.StatusUnauthorized, wr.RespErrJwtRefreshTokenClaims, nil)
 	}
+	if refreshClaims.Revision != LatestRefreshTokenRevision {
+		return wr.NewErrorWithStatus(http.StatusUnauthorized, wr.RespErrJwtRefreshTokenInvalid, nil)
+	}
 	refreshTokenExpire, err := refreshClaims.GetExpirationTime()
 	if err != nil || refreshTokenExpire.Time.Before(time.Now()) {
 		return wr.NewErrorWithStatus(http.StatusUnauthorized, wr.RespErrJwtRefreshTokenExpired, nil)
 	}
-	valid, err := api.DB.VerifyRefreshTokenSessionId(refreshClaims.UserID, refreshClaims.SessionID)
+	valid, err := api.DB.VerifyRefreshTokenSessionId(c.Request().Context(), refreshClaims.UserID, refreshClaims.SessionID)
 	if err != nil {
 		log.Logf(log.LevelDebug, "unable to verify refresh token: %s", err.Error())
+		if errors.Is(err, db.ErrDatabaseConn) {
+			// don't log out the client, the session may still be valid once the database is reachable again
+			return wr.NewErrorWithStatus(http.StatusServiceUnavailable, wr.RespErrJwtRefreshTokenVerifyErr, err)
+		}
 		return wr.NewErrorWithStatus(http.StatusUnauthorized, wr.RespErrJwtRefreshTokenVerifyErr, nil)
 	}
 	if !valid {
@@ -74,13 +84,16 @@ func AuthJwtHandler(c echo.Context, api *ApiServer) error {
 	}
 
 	// Create New Access Token Claims
-	user, err := api.DB.GetUserByID(refreshClaims.UserID)
+	user, err := api.DB.GetUserByID(c.Request().Context(), refreshClaims.UserID)
 	if err != nil {
 		return wr.NewErrorWithStatus(http.StatusUnauthorized, wr.RespErrUserDoesNotExist, errx.Wrap(err, "unable to get user from refresh token user id"))
 	}
-	roles, err := api.DB.GetUserRoles(refreshClaims.UserID)
+	if user.Disabled {
+		return wr.NewErrorWithStatus(http.StatusUnauthorized, wr.RespErrUserDisabled, nil)
+	}
+	roles, err := api.DB.GetUserRoles(c.Request().Context(), refreshClaims.UserID)
 	if err != nil {
-		return wr.NewErrorWithStatus(http.StatusUnauthorized, wr.RespErrUserNoRoles, errx.Wrapf(err, "unable to get roles for jwt access token for user (id: %d)", refreshClaims.UserID))
+		return wr.NewErrorWithStatus(http.StatusUnauthorized, wr.RespErrUserNoRoles, errx.Wrapf(err, "unable to get roles for jwt access token for user (id: %s)", refreshClaims.UserID))
 	}
 	var accessClaimData any
 	if oldAccessClaims != nil {
@@ -95,24 +108,24 @@ func AuthJwtHandler(c echo.Context, api *ApiServer) error {
 	currentRequest := c.Request()
 
 	// Renew Refresh Token, if valid for less than 1 week
-	if refreshTokenExpire.Time.Add(-api.Config.Settings.TokenRefreshRenewMargin).Before(time.Now()) {
+	if refreshTokenExpire.Time.Add(-api.Settings().TokenRefreshRenewMargin).Before(time.Now()) {
 		newSessionId := h.CreateSecretString(h.RandomString(16)) // TODO: maybe use another random generator...
 
 		newRefreshClaims := createJwtRefreshClaims(user.ID, newSessionId)
 
 		newRefreshToken, expiresAt, err := newRefreshClaims.signToken(api)
 		if err != nil {
-			log.Logf(log.LevelDebug, "unable to create new refresh token for user '%s' (id: '%d')", user.Name, user.ID)
+			log.Logf(log.LevelDebug, "unable to create new refresh token for user '%s' (id: '%s')", user.Name, user.ID)
 			return wr.NewErrorWithStatus(http.StatusUnauthorized, wr.RespErrJwtRefreshTokenSigning, nil)
 		}
 		userAgent := currentRequest.Header.Get("User-Agent")
-		err = api.DB.UpdateRefreshTokenEntry(refreshClaims.UserID, refreshClaims.SessionID, newSessionId, userAgent, expiresAt)
+		err = api.DB.UpdateRefreshTokenEntry(c.Request().Context(), refreshClaims.UserID, refreshClaims.SessionID, newSessionId, userAgent, expiresAt)
 		if err != nil {
-			log.Logf(log.LevelDebug, "unable to update refresh token for user (id: %d): %s", user.ID, err.Error())
+			log.Logf(log.LevelDebug, "unable to update refresh token for user (id: %s): %s", user.ID, err.Error())
 			return wr.NewErrorWithStatus(http.StatusUnauthorized, wr.RespErrJwtRefreshTokenUpdate, nil)
 		}
 
-		expiresIn := api.Config.AddCookieExpiryMargin(api.Config.Settings.TokenRefreshValidity)
+		expiresIn := api.AddCookieExpiryMargin(api.Settings().TokenRefreshValidity)
 		newRefreshTokenCookie := &http.Cookie{Name: "refresh_token", Value: newRefreshToken, Path: "/", Expires: time.Now().Add(expiresIn)}
 
 		h.OverwriteRequestCookie(currentRequest, newRefreshTokenCookie) // set cookie for current request
@@ -122,15 +135,16 @@ func AuthJwtHandler(c echo.Context, api *ApiServer) error {
 	// Renew Access Token, since refresh token changed
 	newAccessToken, err := accessClaims.SignToken(api)
 	if err != nil {
-		log.Logf(log.LevelDebug, "unable to create new access token for user '%s' (id: '%d')", user.Name, user.ID)
+		log.Logf(log.LevelDebug, "unable to create new access token for user '%s' (id: '%s')", user.Name, user.ID)
 		return wr.NewErrorWithStatus(http.StatusUnauthorized, wr.RespErrJwtAccessTokenSigning, nil)
 	}
 
-	expiresIn := api.Config.AddCookieExpiryMargin(api.Config.Settings.TokenAccessValidity)
+	expiresIn := api.AddCookieExpiryMargin(api.Settings().TokenAccessValidity)
 	newAccessTokenCookie := &http.Cookie{Name: "access_token", Value: newAccessToken, Path: "/", Expires: time.Now().Add(expiresIn)}
 	h.OverwriteRequestCookie(currentRequest, newAccessTokenCookie) // set cookie for current request
 	c.SetCookie(newAccessTokenCookie)                              // set cookie for response
 
 	c.SetRequest(currentRequest) // rewrite request with new token(s)
+	SetAuditActor(c, user.ID)
 	return nil
 }
@@ -6,7 +6,7 @@ import (
 	"gopkg.cc/apibase/errx"
 )
 
-func parseAccessTokenCookie[T any](c echo.Context, secret []byte, data T) (*jwt.Token, error) {
+func parseAccessTokenCookie[T any](c echo.Context, keyFunc jwt.Keyfunc, data T) (*jwt.Token, error) {
 	tokenRaw, err := c.Cookie("access_token")
 	if err != nil {
 		// c.Logger().Debugf("no cookie 'access_token' in request (%s): %v", c.Request().RequestURI, err)
@@ -16,9 +16,7 @@ func parseAccessTokenCookie[T any](c echo.Context, secret []byte, data T) (*jwt.
 	accessClaims := &jwtAccessClaims[T]{
 		Data: data,
 	}
-	token, err := jwt.ParseWithClaims(tokenRaw.Value, accessClaims, func(t *jwt.Token) (interface{}, error) {
-		return secret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenRaw.Value, accessClaims, keyFunc, jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}))
 	if err != nil {
 		// c.Logger().Debugf("error parsing token from cookie: %v", err)
 		return &jwt.Token{}, errx.NewWithType(ErrTokenValidate, "error parsing token 'access_token'")
@@ -26,15 +24,13 @@ func parseAccessTokenCookie[T any](c echo.Context, secret []byte, data T) (*jwt.
 	return token, nil
 }
 
-func parseRefreshTokenCookie(c echo.Context, secret []byte) (*jwt.Token, error) {
+func parseRefreshTokenCookie(c echo.Context, keyFunc jwt.Keyfunc) (*jwt.Token, error) {
 	tokenRaw, err := c.Cookie("refresh_token")
 	if err != nil {
 		// c.Logger().Debugf("no cookie 'refresh_token' in request (%s): %v", c.Request().RequestURI, err)
 		return &jwt.Token{}, errx.NewWithType(ErrTokenValidate, "no cookie 'refresh_token' present in request")
 	}
-	token, err := jwt.ParseWithClaims(tokenRaw.Value, new(jwtRefreshClaims), func(t *jwt.Token) (interface{}, error) {
-		return secret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenRaw.Value, new(jwtRefreshClaims), keyFunc, jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}))
 	if err != nil {
 		// c.Logger().Debugf("error parsing token from cookie: %v", err)
 		return &jwt.Token{}, errx.NewWithType(ErrTokenValidate, "error parsing token 'refresh_token'")
@@ -1,6 +1,9 @@
 package web
 
-import "gopkg.cc/apibase/table"
+import (
+	"github.com/gofrs/uuid/v5"
+	"gopkg.cc/apibase/table"
+)
 
 // intentionally obfuscated json keys for security and bandwidth savings
 type JwtRole struct {
@@ -9,7 +12,7 @@ type JwtRole struct {
 	OrgAdmin bool `json:"c" toml:"org_admin"`
 }
 
-type JwtRoles map[int]JwtRole // map[orgID]Permissions
+type JwtRoles map[uuid.UUID]JwtRole // map[tenantID]Permissions, tenant ids are the ids of table.Organization
 
 func jwtRolesFromTable(roles []table.UserRole) JwtRoles {
 	jwtRoles := JwtRoles{}
@@ -23,7 +26,7 @@ func jwtRolesFromTable(roles []table.UserRole) JwtRoles {
 	return jwtRoles
 }
 
-func (role JwtRole) GetTable(userId int, orgId int) table.UserRole {
+func (role JwtRole) GetTable(userId uuid.UUID, orgId uuid.UUID) table.UserRole {
 	return table.UserRole{
 		UserID:   userId,
 		OrgID:    orgId,
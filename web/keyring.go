@@ -0,0 +1,303 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/golang-jwt/jwt/v5"
+	"gopkg.cc/apibase/errx"
+	h "gopkg.cc/apibase/helper"
+)
+
+// key id of ApiConfig.TokenSecret, which is used alongside the keyring and may be retired by a keyring entry without secret
+const LEGACY_KEY_ID = "default"
+
+// Signing key of the keyring, the newest active key signs new tokens (kid header is set to ID),
+// any other key is only used to verify tokens until it is retired
+type SigningKey struct {
+	ID        string         `toml:"id"`
+	Secret    h.SecretString `toml:"secret"` // random base64 byte string with at least 64 bytes, may be a secret reference
+	CreatedAt time.Time      `toml:"created_at"`
+	NotBefore time.Time      `toml:"not_before,omitempty"` // not used for signing before this date, allows all instances to load the key first
+	RetireAt  time.Time      `toml:"retire_at,omitempty"`  // tokens signed with this key are rejected from this date on
+
+	secretBytes []byte
+}
+
+// Keyring file content, loaded from ApiConfig.KeyringFile
+type Keyring struct {
+	Keys []SigningKey `toml:"key"`
+}
+
+// same as SigningKey but with plain secret, only used to write keyring file
+type keyringFileKey struct {
+	ID        string    `toml:"id"`
+	Secret    string    `toml:"secret,omitempty"`
+	CreatedAt time.Time `toml:"created_at,omitempty"`
+	NotBefore time.Time `toml:"not_before,omitempty"`
+	RetireAt  time.Time `toml:"retire_at,omitempty"`
+}
+
+// Load keyring from toml file, see Keyring.Save()
+func LoadKeyring(path string) (*Keyring, error) {
+	keyring := &Keyring{}
+	if _, err := toml.DecodeFile(path, keyring); err != nil {
+		return nil, errx.WrapWithTypef(ErrKeyring, err, "unable to read keyring file '%s'", path)
+	}
+	for _, key := range keyring.Keys {
+		if key.Secret.Ref() != "" {
+			if err := h.Secrets.Refresh(context.Background()); err != nil {
+				return nil, errx.WrapWithType(ErrKeyring, err, "unable to resolve keyring secret references")
+			}
+			break
+		}
+	}
+	return keyring, keyring.validate()
+}
+
+// Write keyring to toml file, only readable by the current user
+func (k *Keyring) Save(path string) error {
+	file := struct {
+		Keys []keyringFileKey `toml:"key"`
+	}{}
+	for _, key := range k.Keys {
+		file.Keys = append(file.Keys, keyringFileKey{key.ID, key.Secret.ConfigText(), key.CreatedAt, key.NotBefore, key.RetireAt})
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".keyring-*")
+	if err != nil {
+		return errx.WrapWithType(ErrKeyring, err, "unable to create keyring file")
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return errx.WrapWithType(ErrKeyring, err, "unable to set keyring file permissions")
+	}
+	if err := toml.NewEncoder(tmp).Encode(file); err != nil {
+		tmp.Close()
+		return errx.WrapWithType(ErrKeyring, err, "unable to write keyring file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errx.WrapWithType(ErrKeyring, err, "unable to write keyring file")
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errx.WrapWithType(ErrKeyring, err, "unable to replace keyring file")
+	}
+	return nil
+}
+
+// Add new random signing key, which is used for signing from notBefore on (zero or past means immediately)
+func (k *Keyring) Add(now time.Time, notBefore time.Time) SigningKey {
+	id := make([]byte, 4)
+	secret := make([]byte, 64)
+	if _, err := rand.Read(id); err != nil {
+		panic("crypto/rand.Read() failed, this should never happen: " + err.Error())
+	}
+	if _, err := rand.Read(secret); err != nil {
+		panic("crypto/rand.Read() failed, this should never happen: " + err.Error())
+	}
+	key := SigningKey{
+		ID:        now.UTC().Format("20060102") + "-" + hex.EncodeToString(id),
+		Secret:    h.CreateSecretString(base64.StdEncoding.EncodeToString(secret)),
+		CreatedAt: now.UTC().Truncate(time.Second),
+		NotBefore: notBefore.UTC().Truncate(time.Second),
+
+		secretBytes: secret,
+	}
+	k.Keys = append(k.Keys, key)
+	return key
+}
+
+// Retire key at the specified date, tokens signed with this key are rejected from then on.
+// The legacy ApiConfig.TokenSecret is retired by id "default"
+func (k *Keyring) Retire(id string, at time.Time) error {
+	for i := range k.Keys {
+		if k.Keys[i].ID == id {
+			k.Keys[i].RetireAt = at.UTC().Truncate(time.Second)
+			return nil
+		}
+	}
+	if id == LEGACY_KEY_ID {
+		k.Keys = append(k.Keys, SigningKey{ID: LEGACY_KEY_ID, RetireAt: at.UTC().Truncate(time.Second)})
+		return nil
+	}
+	return errx.NewWithTypef(ErrKeyring, "key '%s' doesn't exist", id)
+}
+
+// Retire every key that is signing or verify-only at now, including the legacy ApiConfig.TokenSecret, at the specified date.
+// Pending keys and keys that already retire before that date are kept as they are
+func (k *Keyring) RetireActive(now time.Time, at time.Time) error {
+	for _, key := range k.Keys {
+		if status := k.Status(key.ID, now); status == "retired" || status == "pending" {
+			continue
+		}
+		if key.RetireAt.IsZero() || key.RetireAt.After(at) {
+			if err := k.Retire(key.ID, at); err != nil {
+				return err
+			}
+		}
+	}
+	if k.Status(LEGACY_KEY_ID, now) == "" {
+		return k.Retire(LEGACY_KEY_ID, at)
+	}
+	return nil
+}
+
+// Get key that is used for signing at the specified time, false if no key is active
+func (k *Keyring) SigningKey(now time.Time) (SigningKey, bool) {
+	var signing SigningKey
+	found := false
+	for _, key := range k.Keys {
+		if len(key.secretBytes) < 1 || key.retired(now) || key.NotBefore.After(now) {
+			continue
+		}
+		// on equal activation the later key in the keyring wins
+		if !found || !key.activeFrom().Before(signing.activeFrom()) {
+			signing, found = key, true
+		}
+	}
+	return signing, found
+}
+
+func (key SigningKey) activeFrom() time.Time {
+	if key.NotBefore.IsZero() {
+		return key.CreatedAt
+	}
+	return key.NotBefore
+}
+
+// Status of key at the specified time: signing, verify-only, pending or retired
+func (k *Keyring) Status(id string, now time.Time) string {
+	key, ok := k.key(id)
+	switch {
+	case !ok:
+		return ""
+	case key.retired(now):
+		return "retired"
+	case key.NotBefore.After(now):
+		return "pending"
+	}
+	if signing, ok := k.SigningKey(now); ok && signing.ID == id {
+		return "signing"
+	}
+	return "verify-only"
+}
+
+func (key SigningKey) retired(now time.Time) bool {
+	return !key.RetireAt.IsZero() && !now.Before(key.RetireAt)
+}
+
+func (k *Keyring) key(id string) (SigningKey, bool) {
+	for _, key := range k.Keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+func (k *Keyring) validate() error {
+	ids := []string{}
+	for i, key := range k.Keys {
+		if key.ID == "" || slices.Contains(ids, key.ID) {
+			return errx.NewWithTypef(ErrKeyring, "key id '%s' is empty or not unique", key.ID)
+		}
+		ids = append(ids, key.ID)
+		if key.ID == LEGACY_KEY_ID && key.Secret.GetSecret() == "" {
+			continue // only used to retire ApiConfig.TokenSecret
+		}
+		secret, err := DecodeTokenSecret(key.Secret.GetSecret())
+		if err != nil {
+			return errx.WrapWithTypef(ErrKeyring, err, "invalid secret of key '%s'", key.ID)
+		}
+		k.Keys[i].secretBytes = secret
+	}
+	return nil
+}
+
+// Keyring used for signing and verifying tokens, the legacy ApiConfig.TokenSecret is added as key "default",
+// unless the keyring contains a key with the same id
+func (api *ApiServer) activeKeyring() *Keyring {
+	keyring := &Keyring{}
+	legacy := SigningKey{ID: LEGACY_KEY_ID}
+	if api.live != nil {
+		api.live.RLock()
+		if api.live.keyring != nil {
+			keyring.Keys = slices.Clone(api.live.keyring.Keys)
+		}
+		legacy.secretBytes = api.live.tokenSecret
+		api.live.RUnlock()
+	}
+	for i := range keyring.Keys {
+		if keyring.Keys[i].ID != LEGACY_KEY_ID {
+			continue
+		}
+		if len(keyring.Keys[i].secretBytes) < 1 {
+			// entry without secret only retires TokenSecret
+			keyring.Keys[i].secretBytes = legacy.secretBytes
+		}
+		return keyring
+	}
+	if len(legacy.secretBytes) > 0 {
+		keyring.Keys = append(keyring.Keys, legacy)
+	}
+	return keyring
+}
+
+// Load ApiConfig.KeyringFile, if configured. Used on setup and reload
+func (api *ApiServer) LoadKeyringFile() error {
+	if api.Config.KeyringFile == "" {
+		return nil
+	}
+	keyring, err := LoadKeyring(api.Config.KeyringFile)
+	if err != nil {
+		return err
+	}
+	if api.live == nil {
+		api.Reload(api.Config.CORS, api.Config.Settings)
+	}
+	api.live.Lock()
+	previous := api.live.keyring
+	api.live.keyring = keyring
+	api.live.Unlock()
+	if _, ok := api.activeKeyring().SigningKey(time.Now()); !ok {
+		api.live.Lock()
+		api.live.keyring = previous
+		api.live.Unlock()
+		return errx.NewWithTypef(ErrKeyring, "keyring file '%s' doesn't contain any active key and token_secret isn't set", api.Config.KeyringFile)
+	}
+	return nil
+}
+
+// sign token with the currently active signing key, kid header is set to the key id
+func (api *ApiServer) signToken(claims jwt.Claims) (string, error) {
+	key, ok := api.activeKeyring().SigningKey(time.Now())
+	if !ok {
+		return "", errx.NewWithType(ErrKeyring, "no active signing key")
+	}
+	rawToken := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+	rawToken.Header["kid"] = key.ID
+	return rawToken.SignedString(key.secretBytes)
+}
+
+// jwt.Keyfunc selecting the verification key by kid header, tokens without kid are verified with the legacy key
+func (api *ApiServer) verificationKey(token *jwt.Token) (any, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		kid = LEGACY_KEY_ID
+	}
+	key, ok := api.activeKeyring().key(kid)
+	if !ok || len(key.secretBytes) < 1 {
+		return nil, errx.NewWithTypef(ErrKeyring, "unknown key id '%s'", kid)
+	}
+	if key.retired(time.Now()) {
+		return nil, errx.NewWithTypef(ErrKeyring, "key '%s' is retired", kid)
+	}
+	return key.secretBytes, nil
+}
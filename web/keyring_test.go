@@ -0,0 +1,89 @@
+package web_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.cc/apibase/web"
+)
+
+func TestKeyringRotation(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	k := &web.Keyring{}
+	first := k.Add(now, time.Time{})
+	second := k.Add(now, now.Add(time.Hour))
+	if err := k.Retire(first.ID, now.Add(time.Hour*2)); err != nil {
+		t.Fatalf("Retire() = %v", err)
+	}
+	if err := k.Retire("missing", now); err == nil {
+		t.Errorf("Retire() of missing key = nil; want error")
+	}
+
+	path := filepath.Join(t.TempDir(), "keyring.toml")
+	if err := k.Save(path); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+	loaded, err := web.LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("LoadKeyring() = %v", err)
+	}
+
+	tests := []struct {
+		at      time.Time
+		signing string
+		first   string
+		second  string
+	}{
+		{now, first.ID, "signing", "pending"},
+		{now.Add(time.Hour), second.ID, "verify-only", "signing"},
+		{now.Add(time.Hour * 2), second.ID, "retired", "signing"},
+	}
+	for _, test := range tests {
+		key, ok := loaded.SigningKey(test.at)
+		if !ok || key.ID != test.signing {
+			t.Errorf("SigningKey(%s) = %s; want %s", test.at, key.ID, test.signing)
+		}
+		if got := loaded.Status(first.ID, test.at); got != test.first {
+			t.Errorf("Status(first, %s) = %s; want %s", test.at, got, test.first)
+		}
+		if got := loaded.Status(second.ID, test.at); got != test.second {
+			t.Errorf("Status(second, %s) = %s; want %s", test.at, got, test.second)
+		}
+	}
+}
+
+func TestKeyringRetireActive(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	k := &web.Keyring{}
+	active := k.Add(now.Add(-time.Hour), time.Time{})
+	retiring := k.Add(now.Add(-time.Hour), time.Time{})
+	pending := k.Add(now, now.Add(time.Hour))
+	if err := k.Retire(retiring.ID, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Retire() = %v", err)
+	}
+
+	at := now.Add(time.Hour * 24)
+	if err := k.RetireActive(now, at); err != nil {
+		t.Fatalf("RetireActive() = %v", err)
+	}
+	tests := []struct {
+		id   string
+		want time.Time
+	}{
+		{active.ID, at},
+		{retiring.ID, now.Add(time.Minute)},
+		{pending.ID, time.Time{}},
+		{web.LEGACY_KEY_ID, at},
+	}
+	for _, test := range tests {
+		for _, key := range k.Keys {
+			if key.ID == test.id && !key.RetireAt.Equal(test.want) {
+				t.Errorf("RetireAt of '%s' = %s; want %s", test.id, key.RetireAt, test.want)
+			}
+		}
+		if k.Status(test.id, now) == "" {
+			t.Errorf("Status(%s) = ''; want key to exist", test.id)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/errx"
+	wr "gopkg.cc/apibase/web_response"
+)
+
+// List query from the query params cursor, limit and sort, all other params except ignore are filters (e.g. ?task_type=report&sort=-created_at).
+// Filters and sort keys are validated by the db.Store List methods
+func BindListQuery(c echo.Context, ignore ...string) (db.ListQuery, error) {
+	q := db.ListQuery{Filters: map[string]string{}, Cursor: c.QueryParam("cursor"), Sort: c.QueryParam("sort")}
+	if limit := c.QueryParam("limit"); limit != "" {
+		var err error
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			return q, errx.WrapWithType(db.ErrInvalidListQuery, err, "limit")
+		}
+	}
+	for param, values := range c.QueryParams() {
+		if param == "cursor" || param == "limit" || param == "sort" || slices.Contains(ignore, param) {
+			continue
+		}
+		if len(values) != 1 {
+			return q, errx.NewWithTypef(db.ErrInvalidListQuery, "filter '%s' must be set once", param)
+		}
+		q.Filters[param] = values[0]
+	}
+	return q, nil
+}
+
+// Send page as JsonResponse with pagination, entries are converted to their response type
+func SendPage[T any, R any](c echo.Context, page db.ListPage[T], convert func(entry T) R) error {
+	data := make([]R, 0, len(page.Items))
+	for _, entry := range page.Items {
+		data = append(data, convert(entry))
+	}
+	return c.JSON(http.StatusOK, wr.JsonResponse[[]R]{
+		ResponseID: wr.RespSccsGeneric,
+		Data:       data,
+		Page:       &wr.Page{NextCursor: page.NextCursor, Total: page.Total},
+	})
+}
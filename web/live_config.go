@@ -0,0 +1,96 @@
+package web
+
+import (
+	"path"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Values of ApiServer.Config that may be replaced while the server is running, see ApiServer.Reload()
+type liveConfig struct {
+	sync.RWMutex
+	cors        []string
+	settings    *ApiConfigSettings
+	tokenSecret []byte   // decoded ApiConfig.TokenSecret, updated if rotated
+	keyring     *Keyring // loaded from ApiConfig.KeyringFile
+}
+
+// Get currently active settings, use this instead of api.Config.Settings,
+// since api.Config only contains the settings the ApiServer was initialized with
+func (api *ApiServer) Settings() *ApiConfigSettings {
+	if api.live == nil {
+		return api.Config.Settings
+	}
+	api.live.RLock()
+	defer api.live.RUnlock()
+	return api.live.settings
+}
+
+// Get currently active CORS origins
+func (api *ApiServer) CORS() []string {
+	if api.live == nil {
+		return api.Config.CORS
+	}
+	api.live.RLock()
+	defer api.live.RUnlock()
+	return api.live.cors
+}
+
+// Replace reloadable config values of running ApiServer, settings must already contain parsed defaults.
+// Requests that are already being processed keep using the previous values
+func (api *ApiServer) Reload(cors []string, settings *ApiConfigSettings) {
+	if api.live == nil {
+		api.live = &liveConfig{}
+	}
+	api.live.Lock()
+	api.live.cors = slices.Clone(cors)
+	api.live.settings = settings
+	api.live.Unlock()
+}
+
+// Get currently active token secret used for signing and verifying jwt and csrf tokens
+func (api *ApiServer) TokenSecretBytes() []byte {
+	if api.live != nil {
+		api.live.RLock()
+		secret := api.live.tokenSecret
+		api.live.RUnlock()
+		if len(secret) > 0 {
+			return secret
+		}
+	}
+	return api.Config.TokenSecretBytes()
+}
+
+// Replace token secret of running ApiServer, e.g. after secret rotation, previously issued tokens become invalid
+func (api *ApiServer) SetTokenSecret(secret string) error {
+	secretBytes, err := DecodeTokenSecret(secret)
+	if err != nil {
+		return err
+	}
+	if api.live == nil {
+		api.Reload(api.Config.CORS, api.Config.Settings)
+	}
+	api.live.Lock()
+	api.live.tokenSecret = secretBytes
+	api.live.Unlock()
+	return nil
+}
+
+// Used by the CORS middleware, origins may contain wildcards, e.g. https://*.example.com
+func (api *ApiServer) AllowOrigin(origin string) (bool, error) {
+	for _, allowed := range api.CORS() {
+		if allowed == "*" || allowed == origin {
+			return true, nil
+		}
+		if match, err := path.Match(allowed, origin); err == nil && match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Same as ApiConfig.AddCookieExpiryMargin() but uses the currently active settings
+func (api *ApiServer) AddCookieExpiryMargin(validity time.Duration) time.Duration {
+	return validity + time.Duration(float32(validity)*api.Settings().TokenCookieExpiryMargin)
+}
@@ -0,0 +1,37 @@
+package web_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	h "gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/web"
+)
+
+func TestTokenSecretBytes(t *testing.T) {
+	configSecret, rotatedSecret := web.GenerateTokenSecret(), web.GenerateTokenSecret()
+	configBytes, _ := base64.StdEncoding.DecodeString(configSecret)
+	rotatedBytes, _ := base64.StdEncoding.DecodeString(rotatedSecret)
+
+	tests := []struct {
+		name  string
+		setup func(api *web.ApiServer)
+		want  []byte
+	}{
+		{"not reloaded", func(api *web.ApiServer) {}, configBytes},
+		{"reloaded without live secret", func(api *web.ApiServer) { api.Reload(nil, nil) }, configBytes},
+		{"rotated", func(api *web.ApiServer) {
+			if err := api.SetTokenSecret(rotatedSecret); err != nil {
+				t.Fatalf("SetTokenSecret() = %v", err)
+			}
+		}, rotatedBytes},
+	}
+	for _, test := range tests {
+		api := &web.ApiServer{Config: web.ApiConfig{TokenSecret: h.CreateSecretString(configSecret)}}
+		test.setup(api)
+		if got := api.TokenSecretBytes(); !bytes.Equal(got, test.want) {
+			t.Errorf("%s: TokenSecretBytes() = %x; want %x", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package web
+
+import (
+	"github.com/labstack/echo/v4"
+	"gopkg.cc/apibase/db"
+)
+
+// echo middleware routing reads of a request to the primary database once it wrote (see db.WithPrimaryAfterWrite()),
+// so responses contain the writes of the request even if replicas lag behind. Used for all requests by web_setup.SetupRest()
+func PinPrimaryAfterWrite() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.SetRequest(c.Request().WithContext(db.WithPrimaryAfterWrite(c.Request().Context())))
+			return next(c)
+		}
+	}
+}
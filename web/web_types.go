@@ -1,6 +1,7 @@
 package web
 
 import (
+	"crypto/rand"
 	"embed"
 	"encoding/base64"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/gofrs/uuid/v5"
 	"github.com/labstack/echo/v4"
 	"gopkg.cc/apibase/db"
 	"gopkg.cc/apibase/errx"
@@ -71,16 +73,17 @@ func (app *CustomURI) AddQueryParams(params []QueryParam[any]) *CustomURI {
 // The struct must have json tags and you are encouraged to obfuscate the tags e.g. using a, b, c, ...
 // If bool variable is true, return only an initialized empty struct of the desired type, this must always return without an error.
 // Errors will be logged and access claim data will be nil in jwt.
-type AccessClaimDataFunc func(int, bool) (any, error)
+type AccessClaimDataFunc func(uuid.UUID, bool) (any, error)
 
 type ApiServer struct {
 	E      *echo.Echo  // Direct access to the echo webserver instance
 	Api    *echo.Group // Used to register API endpoints, leading slash already present (/api/<route>)
 	Kind   ApiKind     // REST (or HTMX, TODO: this)
 	Config ApiConfig   // API config used to initialize ApiServer
-	DB     db.DB       // Database connection for this ApiServer
+	DB     db.Store    // Database of this ApiServer, db.DB or db.MemoryStore for tests
 
 	accessClaimData AccessClaimDataFunc // Custom Access Claims for User
+	live            *liveConfig         // Reloadable config values, see ApiServer.Reload()
 	// middleware []echo.MiddlewareFunc
 }
 
@@ -90,13 +93,13 @@ func (api *ApiServer) RegisterAccessClaimDataFunc(accessClaimDataFunc AccessClai
 	api.accessClaimData = accessClaimDataFunc
 }
 
-func (api ApiServer) GetAccessClaimData(userId int) any {
+func (api ApiServer) GetAccessClaimData(userId uuid.UUID) any {
 	if api.accessClaimData == nil {
 		return nil
 	}
 	data, err := api.accessClaimData(userId, false)
 	if err != nil {
-		log.Logf(log.LevelError, "Unable to get custom access claim data for user (id: %d): %s", userId, err.Error())
+		log.Logf(log.LevelError, "Unable to get custom access claim data for user (id: %s): %s", userId, err.Error())
 		return nil
 	}
 	return data
@@ -107,7 +110,7 @@ func (api ApiServer) GetAccessClaimDataType() any {
 	if api.accessClaimData == nil {
 		return nil
 	}
-	data, _ := api.accessClaimData(0, true)
+	data, _ := api.accessClaimData(uuid.Nil, true)
 	return data
 }
 
@@ -118,6 +121,7 @@ type ApiConfig struct {
 
 	// Secrets
 	TokenSecret h.SecretString `toml:"token_secret"`
+	KeyringFile string         `toml:"keyring_file"` // signing keys for jwt rotation, managed with cli command 'keyring', TokenSecret is optional if set
 
 	// Flags
 	LocalAuth          bool `toml:"local_auth"`
@@ -135,6 +139,24 @@ type ApiConfig struct {
 	embedFS          embed.FS   // if configured in ApiRoot, must be registered with ApiConfig.RegisterEmbedFS()
 }
 
+// Decode base64 token secret, which must be at least 64 bytes long
+func DecodeTokenSecret(secret string) ([]byte, error) {
+	secretBytes, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil || len(secretBytes) < 64 {
+		return nil, errx.New("TokenSecret must be random base64 byte string with at least 64 bytes")
+	}
+	return secretBytes, nil
+}
+
+// Generate random token secret accepted by DecodeTokenSecret(), 64 random bytes as base64 string
+func GenerateTokenSecret() string {
+	secret := make([]byte, 64)
+	if _, err := rand.Read(secret); err != nil {
+		panic("crypto/rand.Read() failed, this should never happen: " + err.Error())
+	}
+	return base64.StdEncoding.EncodeToString(secret)
+}
+
 func (ac ApiConfig) TokenSecretBytes() []byte {
 	if len(ac.tokenSecretBytes) > 0 {
 		return ac.tokenSecretBytes
@@ -156,9 +178,9 @@ func (ac *ApiConfig) AppUri() *CustomURI {
 		return NewCustomUri(ac.appURI.uri)
 	}
 
-	uri, err := url.ParseRequestURI(ac.AppURI)
+	uri, err := ac.ParseAppUri()
 	if err != nil {
-		log.Logf(log.LevelCritical, "app_uri (from config: %s) must be valid url with protocol and without fragment of the application using the api: %s", ac.AppURI, err.Error())
+		log.Log(log.LevelCritical, err.Error())
 		panic(1)
 	}
 	ac.appURI = NewCustomUri(uri)
@@ -166,6 +188,49 @@ func (ac *ApiConfig) AppUri() *CustomURI {
 	return NewCustomUri(ac.appURI.uri)
 }
 
+// Parse AppURI without caching it, use AppUri() once the config is validated
+func (ac ApiConfig) ParseAppUri() (*url.URL, error) {
+	uri, err := url.ParseRequestURI(ac.AppURI)
+	if err != nil {
+		return nil, errx.Wrapf(err, "app_uri (from config: %s) must be valid url with protocol and without fragment of the application using the api", ac.AppURI)
+	}
+	return uri, nil
+}
+
+// Validate config like SetupRest() does, but return every problem instead of only the first one and never panic.
+// Settings are checked for unparsable values, which are otherwise replaced by defaults with a warning
+func (ac *ApiConfig) Validate() []error {
+	problems := []error{}
+	if ac.TokenSecret.GetSecret() != "" || ac.KeyringFile == "" {
+		if _, err := DecodeTokenSecret(ac.TokenSecret.GetSecret()); err != nil {
+			problems = append(problems, errx.Wrap(err, "token_secret"))
+		}
+	}
+	if ac.KeyringFile != "" {
+		keyring, err := LoadKeyring(ac.KeyringFile)
+		if err != nil {
+			problems = append(problems, err)
+		} else if _, ok := keyring.SigningKey(time.Now()); !ok && ac.TokenSecret.GetSecret() == "" {
+			problems = append(problems, errx.NewWithTypef(ErrKeyring, "keyring file '%s' doesn't contain any active key and token_secret isn't set", ac.KeyringFile))
+		}
+	}
+	if !ac.LocalAuth && !ac.OAuthEnabled {
+		problems = append(problems, errx.New("No Authentication method enabled, either LocalAuth, OAuthEnabled or both need to be enabled"))
+	}
+	if err := ac.ValidateApiRoot(); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := ac.ParseAppUri(); err != nil {
+		problems = append(problems, err)
+	}
+	if ac.Settings != nil {
+		for _, err := range h.CheckTomlConfig(ac.Settings) {
+			problems = append(problems, errx.Wrap(err, "settings"))
+		}
+	}
+	return problems
+}
+
 func (ac *ApiConfig) RegisterEmbedFS(embedFS embed.FS) error {
 	if ac.ApiRoot.Kind != FsEmbed {
 		return errx.NewWithType(ErrFsKindNotEmbed, "unable to register EmbedFS")
@@ -193,6 +258,8 @@ type ApiConfigSettings struct {
 	TomlTimeoutSubprocShutdown       string `toml:"timeout_subproc_shutdown"`
 	TomlTimeoutScheduledTaskStartup  string `toml:"timeout_scheduled_task_startup"`
 	TomlTimeoutScheduledTaskShutdown string `toml:"timeout_scheduled_task_shutdown"`
+	TomlPurgeDeletedAfter            string `toml:"purge_deleted_after"`
+	TomlPurgeInterval                string `toml:"purge_interval"`
 
 	TokenAccessValidity          time.Duration `internal:"token_access_validity"`
 	TokenRefreshValidity         time.Duration `internal:"token_refresh_validity"`
@@ -203,6 +270,8 @@ type ApiConfigSettings struct {
 	TimeoutSubprocShutdown       time.Duration `internal:"timeout_subproc_shutdown"`
 	TimeoutScheduledTaskStartup  time.Duration `internal:"timeout_scheduled_task_startup"`
 	TimeoutScheduledTaskShutdown time.Duration `internal:"timeout_scheduled_task_shutdown"`
+	PurgeDeletedAfter            time.Duration `internal:"purge_deleted_after"` // soft deleted entries are purged after this duration, 0s disables purging
+	PurgeInterval                time.Duration `internal:"purge_interval"`
 }
 
 func (settings *ApiConfigSettings) AddMissingFromDefaults() error {
@@ -216,6 +285,8 @@ func (settings *ApiConfigSettings) AddMissingFromDefaults() error {
 		TimeoutSubprocShutdown:       time.Second * 3,
 		TimeoutScheduledTaskStartup:  time.Second,
 		TimeoutScheduledTaskShutdown: time.Second * 60,
+		PurgeDeletedAfter:            time.Hour * 24 * 30,
+		PurgeInterval:                time.Hour * 24,
 	}
 	return h.ParseTomlConfigAndDefaults(settings, defaults)
 }
@@ -1,6 +1,9 @@
 package web_auth
 
-import "github.com/Morpheus0x/argon2id"
+import (
+	"github.com/Morpheus0x/argon2id"
+	h "gopkg.cc/apibase/helper"
+)
 
 var argonParams = argon2id.Params{
 	Memory:      19 * 1024,
@@ -9,3 +12,12 @@ var argonParams = argon2id.Params{
 	SaltLength:  16,
 	KeyLength:   32,
 }
+
+// Hash password for table.User.PasswordHash, used by signup and the cli
+func HashPassword(password string) (h.SecretString, error) {
+	hash, err := argon2id.CreateHash(password, &argonParams)
+	if err != nil {
+		return h.CreateSecretString(""), err
+	}
+	return h.CreateSecretString(hash), nil
+}
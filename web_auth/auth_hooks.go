@@ -25,6 +25,13 @@ func runSignupDefaultRoleHook(user table.User) ([]table.UserRole, error) {
 	return hook.RegisteredHooks.SignupDefaultRole[0](user)
 }
 
+func runSignupTenantHook(user table.User) (table.Organization, error) {
+	if len(hook.RegisteredHooks.SignupTenant) < 1 {
+		return table.Organization{}, nil
+	}
+	return hook.RegisteredHooks.SignupTenant[0](user)
+}
+
 func runPostSignupHooks(user table.User, roles []table.UserRole) (int, error) {
 	var err error
 	for failedHookNr, hook := range hook.RegisteredHooks.PostSignup {
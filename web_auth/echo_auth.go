@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/Morpheus0x/argon2id"
+	"github.com/gofrs/uuid/v5"
 	"github.com/labstack/echo/v4"
 	"gopkg.cc/apibase/db"
 	h "gopkg.cc/apibase/helper"
@@ -43,7 +44,8 @@ func login(api *web.ApiServer) echo.HandlerFunc {
 			return wr.SendJsonErrorResponse(c, http.StatusInternalServerError, wr.RespErrHookPreLogin)
 		}
 
-		user, err := api.DB.GetUserByEmail(email)
+		web.SetAuditActor(c, uuid.Nil)
+		user, err := api.DB.GetUserByEmail(c.Request().Context(), email)
 		if err != nil {
 			log.Logf(log.LevelDebug, "user not found: %s", err.Error())
 			return wr.SendJsonErrorResponse(c, http.StatusUnauthorized, wr.RespErrLoginNoUser)
@@ -59,12 +61,17 @@ func login(api *web.ApiServer) echo.HandlerFunc {
 			return wr.SendJsonErrorResponse(c, http.StatusUnauthorized, wr.RespErrLoginComparePassword)
 		}
 		if !match {
+			web.Audit(c, api, table.AuditEvent{Action: table.AuditLoginFailed, Target: "user:" + user.ID.String(), Diff: `{"reason":{"new":"wrong password"}}`})
 			return wr.SendJsonErrorResponse(c, http.StatusUnauthorized, wr.RespErrLoginWrongPassword)
 		}
+		if user.Disabled {
+			web.Audit(c, api, table.AuditEvent{Action: table.AuditLoginFailed, Target: "user:" + user.ID.String(), Diff: `{"reason":{"new":"user disabled"}}`})
+			return wr.SendJsonErrorResponse(c, http.StatusForbidden, wr.RespErrUserDisabled)
+		}
 
-		roles, err := api.DB.GetUserRoles(user.ID)
+		roles, err := api.DB.GetUserRoles(c.Request().Context(), user.ID)
 		if err != nil {
-			log.Logf(log.LevelError, "no roles exist for user (id: %d), unable to login", user.ID)
+			log.Logf(log.LevelError, "no roles exist for user (id: %s), unable to login", user.ID)
 			return wr.SendJsonErrorResponse(c, http.StatusUnauthorized, wr.RespErrUserNoRoles)
 		}
 
@@ -111,7 +118,7 @@ func signup(api *web.ApiServer) echo.HandlerFunc {
 			return wr.SendJsonErrorResponse(c, http.StatusInternalServerError, wr.RespErrHookPreSignup)
 		}
 
-		hash, err := argon2id.CreateHash(password, &argonParams)
+		hash, err := HashPassword(password)
 		if err != nil {
 			return wr.SendJsonErrorResponse(c, http.StatusInternalServerError, wr.RespErrSignupPasswordHash)
 		}
@@ -120,7 +127,7 @@ func signup(api *web.ApiServer) echo.HandlerFunc {
 			AuthProvider:   "local",
 			Email:          email,
 			EmailVerified:  false,
-			PasswordHash:   h.CreateSecretString(hash),
+			PasswordHash:   hash,
 			SecretsVersion: 1,
 			TotpSecret:     "",
 			SuperAdmin:     false,
@@ -131,10 +138,24 @@ func signup(api *web.ApiServer) echo.HandlerFunc {
 			return wr.SendJsonErrorResponse(c, http.StatusInternalServerError, wr.RespErrHookSignupDefaultRole)
 		}
 		log.Logf(log.LevelDebug, "Signup Default Role Hook determined the following roles for the new user '%s': %+v", userToCreate.Email, rolesToCreate)
-		user, err := api.DB.CreateNewUserWithOrg(userToCreate, rolesToCreate...)
+		web.SetAuditActor(c, uuid.Nil)
+		var user table.User
+		if len(rolesToCreate) > 0 {
+			user, err = api.DB.CreateUserIfNotExist(c.Request().Context(), userToCreate, rolesToCreate...)
+		} else {
+			tenant, hookErr := runSignupTenantHook(userToCreate)
+			if hookErr != nil {
+				log.Logf(log.LevelError, "signup tenant hook failed: %s", hookErr.Error())
+				return wr.SendJsonErrorResponse(c, http.StatusInternalServerError, wr.RespErrHookSignupTenant)
+			}
+			user, err = api.DB.CreateNewUserWithTenant(c.Request().Context(), userToCreate, tenant)
+		}
 		if errors.Is(err, db.ErrUserAlreadyExists) {
 			return wr.SendJsonErrorResponse(c, http.StatusConflict, wr.RespErrSignupUserExists)
 		}
+		if errors.Is(err, db.ErrOrgSlugExists) {
+			return wr.SendJsonErrorResponse(c, http.StatusConflict, wr.RespErrSignupNewUserOrg)
+		}
 		if errors.Is(err, db.ErrOrgCreate) {
 			return wr.SendJsonErrorResponse(c, http.StatusInternalServerError, wr.RespErrSignupNewUserOrg)
 		}
@@ -142,10 +163,12 @@ func signup(api *web.ApiServer) echo.HandlerFunc {
 			log.Logf(log.LevelError, "unknown error occurred while signup of new user '%s': %s", userToCreate.Email, err.Error())
 			return wr.SendJsonErrorResponse(c, http.StatusConflict, wr.RespErrSignupUserCreate)
 		}
-		roles, err := api.DB.GetUserRoles(user.ID)
+		web.SetAuditActor(c, user.ID)
+		web.Audit(c, api, table.AuditEvent{Action: table.AuditSignup, Target: "user:" + user.ID.String(), Diff: db.AuditDiff(nil, user)})
+		roles, err := api.DB.GetUserRoles(c.Request().Context(), user.ID)
 		if err != nil {
 			// roles should already exist or have been created by CreateUserIfNotExist
-			log.Logf(log.LevelError, "unable to get any roles for user (id: %d)", user.ID)
+			log.Logf(log.LevelError, "unable to get any roles for user (id: %s)", user.ID)
 			return wr.SendJsonErrorResponse(c, http.StatusUnauthorized, wr.RespErrUserNoRoles)
 		}
 
@@ -88,7 +88,7 @@ func callback(api *web.ApiServer) echo.HandlerFunc {
 			EmailVerified: false,
 		}
 		// TODO: impl runSignupDefaultRoleHook to get org assignments from goth.User/userToCreate
-		user, err := api.DB.CreateNewUserWithOrg(userToCreate)
+		user, err := api.DB.CreateNewUserWithOrg(c.Request().Context(), userToCreate)
 		if errors.Is(err, db.ErrOrgCreate) {
 			return c.Redirect(http.StatusTemporaryRedirect, api.Config.AppUri().AddQueryParam(wr.QueryKeyError, wr.RespErrSignupNewUserOrg).String())
 		}
@@ -98,10 +98,10 @@ func callback(api *web.ApiServer) echo.HandlerFunc {
 		}
 		log.Logf(log.LevelDebug, "User logged in: %v", user)
 
-		roles, err := api.DB.GetUserRoles(user.ID)
+		roles, err := api.DB.GetUserRoles(c.Request().Context(), user.ID)
 		if err != nil {
 			// roles should already exist or have been created by GetOrCreateUser
-			log.Logf(log.LevelError, "unable to get any roles for user (id: %d): %s", user.ID, err.Error())
+			log.Logf(log.LevelError, "unable to get any roles for user (id: %s): %s", user.ID, err.Error())
 			return c.Redirect(http.StatusTemporaryRedirect, api.Config.AppUri().AddQueryParam(wr.QueryKeyError, wr.RespErrUserNoRoles).String())
 		}
 
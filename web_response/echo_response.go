@@ -1,15 +1,29 @@
 package web_response
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
 const (
 	QueryKeySuccess = "api_success"
 	QueryKeyError   = "api_error"
 )
 
-// Standardized JSON API response
+// Standardized JSON API response, Page is only set for paginated lists
 type JsonResponse[T any] struct {
 	ResponseID ResponseId `json:"id"`
 	Message    string     `json:"msg"`
 	Data       T          `json:"data"`
+	Page       *Page      `json:"page,omitempty"`
+}
+
+// Pagination of a list in JsonResponse.Data, the next page is requested with the query param cursor=NextCursor
+type Page struct {
+	NextCursor string `json:"next_cursor"` // empty on the last page
+	Total      int64  `json:"total"`       // entries of all pages
 }
 
 type RedirectTarget struct {
@@ -21,6 +35,30 @@ type FrontendSettings struct {
 	SuperAdmin bool `json:"super_admin"`
 }
 
+type AuditEvent struct {
+	ID        uuid.UUID       `json:"id"`
+	ActorID   *uuid.UUID      `json:"actor_id"`
+	OrgID     *uuid.UUID      `json:"org_id"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	IP        string          `json:"ip"`
+	UserAgent string          `json:"user_agent"`
+	Diff      json.RawMessage `json:"diff"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type ScheduledTask struct {
+	ID        uuid.UUID     `json:"id"`
+	TaskID    string        `json:"task_id"`
+	OrgID     uuid.UUID     `json:"org_id"`
+	StartDate time.Time     `json:"start_date"`
+	Interval  time.Duration `json:"interval"`
+	TaskType  string        `json:"task_type"`
+	TaskData  string        `json:"task_data"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
 // type HtmxResponse[T any] struct {
 // 	HtmlTemplate string
 // 	Data         T
@@ -60,6 +60,9 @@ const (
 	RespErrOauthMarshalState
 	RespErrGetAccessClaims
 	RespErrForbidden
+	RespErrUserDisabled
+	RespErrHookSignupTenant
+	RespErrInvalidInput
 	// Only append here to not break existing frontend error IDs
 )
 
@@ -53,11 +53,14 @@ func _() {
 	_ = x[RespErrOauthMarshalState-42]
 	_ = x[RespErrGetAccessClaims-43]
 	_ = x[RespErrForbidden-44]
+	_ = x[RespErrUserDisabled-45]
+	_ = x[RespErrHookSignupTenant-46]
+	_ = x[RespErrInvalidInput-47]
 }
 
-const _ResponseId_name = "RespSccsGenericRespSccsLoginRespSccsLogoutRespSccsSignupRespScssSignupEmailConfirmRespSccsAlreadyLoggedInRespErrUndefinedRespErrUnknownInternalRespErrCsrfInvalidRespErrUserDoesNotExistRespErrUserNoRolesRespErrMissingInputRespErrJwtAccessTokenSigningRespErrJwtAccessTokenParsingRespErrJwtRefreshTokenCreateRespErrJwtRefreshTokenSigningRespErrJwtRefreshTokenUpdateRespErrJwtRefreshTokenParsingRespErrJwtRefreshTokenClaimsRespErrJwtRefreshTokenInvalidRespErrJwtRefreshTokenExpiredRespErrJwtRefreshTokenVerifyErrRespErrJwtRefreshTokenVerifyInvalidRespErrOauthCallbackCompleteAuthRespErrOauthCallbackUnknownErrorRespErrAuthLoginUnknownErrorRespErrAuthLoginNotLocalRespErrAuthSignupUnknownErrorRespErrAuthLogoutUnknownErrorRespErrLoginNoUserRespErrLoginComparePasswordRespErrLoginWrongPasswordRespErrSignupPasswordMismatchRespErrSignupPasswordHashRespErrSignupUserExistsRespErrSignupNewUserOrgRespErrSignupUserCreateRespErrHookPreLoginRespErrHookPostLoginRespErrHookPreSignupRespErrHookSignupDefaultRoleRespErrOauthReferrerParsingRespErrOauthMarshalStateRespErrGetAccessClaimsRespErrForbidden"
+const _ResponseId_name = "RespSccsGenericRespSccsLoginRespSccsLogoutRespSccsSignupRespScssSignupEmailConfirmRespSccsAlreadyLoggedInRespErrUndefinedRespErrUnknownInternalRespErrCsrfInvalidRespErrUserDoesNotExistRespErrUserNoRolesRespErrMissingInputRespErrJwtAccessTokenSigningRespErrJwtAccessTokenParsingRespErrJwtRefreshTokenCreateRespErrJwtRefreshTokenSigningRespErrJwtRefreshTokenUpdateRespErrJwtRefreshTokenParsingRespErrJwtRefreshTokenClaimsRespErrJwtRefreshTokenInvalidRespErrJwtRefreshTokenExpiredRespErrJwtRefreshTokenVerifyErrRespErrJwtRefreshTokenVerifyInvalidRespErrOauthCallbackCompleteAuthRespErrOauthCallbackUnknownErrorRespErrAuthLoginUnknownErrorRespErrAuthLoginNotLocalRespErrAuthSignupUnknownErrorRespErrAuthLogoutUnknownErrorRespErrLoginNoUserRespErrLoginComparePasswordRespErrLoginWrongPasswordRespErrSignupPasswordMismatchRespErrSignupPasswordHashRespErrSignupUserExistsRespErrSignupNewUserOrgRespErrSignupUserCreateRespErrHookPreLoginRespErrHookPostLoginRespErrHookPreSignupRespErrHookSignupDefaultRoleRespErrOauthReferrerParsingRespErrOauthMarshalStateRespErrGetAccessClaimsRespErrForbiddenRespErrUserDisabledRespErrHookSignupTenantRespErrInvalidInput"
 
-var _ResponseId_index = [...]uint16{0, 15, 28, 42, 56, 82, 105, 121, 143, 161, 184, 202, 221, 249, 277, 305, 334, 362, 391, 419, 448, 477, 508, 543, 575, 607, 635, 659, 688, 717, 735, 762, 787, 816, 841, 864, 887, 910, 929, 949, 969, 997, 1024, 1048, 1070, 1086}
+var _ResponseId_index = [...]uint16{0, 15, 28, 42, 56, 82, 105, 121, 143, 161, 184, 202, 221, 249, 277, 305, 334, 362, 391, 419, 448, 477, 508, 543, 575, 607, 635, 659, 688, 717, 735, 762, 787, 816, 841, 864, 887, 910, 929, 949, 969, 997, 1024, 1048, 1070, 1086, 1105, 1128, 1147}
 
 func (i ResponseId) String() string {
 	if i >= ResponseId(len(_ResponseId_index)-1) {
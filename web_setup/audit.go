@@ -0,0 +1,102 @@
+package web_setup
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/labstack/echo/v4"
+	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/errx"
+	"gopkg.cc/apibase/log"
+	"gopkg.cc/apibase/web"
+	wr "gopkg.cc/apibase/web_response"
+)
+
+// Audit events filtered by the query params actor, org (ids), action, from, to (RFC 3339) and limit, newest first.
+// Super admins get all events, other users only events of organizations they are admin of
+func GetAuditEvents(api *web.ApiServer) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		accessClaims, err := web.GetAccessClaims(c, api, struct{}{})
+		if err != nil {
+			log.Logf(log.LevelError, "unable to get access claims for audit events: %s", err.Error())
+			return wr.SendJsonErrorResponse(c, http.StatusBadRequest, wr.RespErrGetAccessClaims)
+		}
+		filter, err := auditFilterFromQuery(c)
+		if err != nil {
+			log.Logf(log.LevelDebug, "invalid audit events query: %s", err.Error())
+			return wr.SendJsonErrorResponse(c, http.StatusUnprocessableEntity, wr.RespErrInvalidInput)
+		}
+		if !accessClaims.SuperAdmin {
+			adminOrgs := []uuid.UUID{}
+			for orgID, role := range accessClaims.Roles {
+				if role.OrgAdmin {
+					adminOrgs = append(adminOrgs, orgID)
+				}
+			}
+			if len(adminOrgs) < 1 || (len(filter.OrgIDs) > 0 && !slices.Contains(adminOrgs, filter.OrgIDs[0])) {
+				return wr.SendJsonErrorResponse(c, http.StatusForbidden, wr.RespErrForbidden)
+			}
+			if len(filter.OrgIDs) < 1 {
+				filter.OrgIDs = adminOrgs
+			}
+		}
+
+		events, err := api.DB.GetAuditEvents(c.Request().Context(), filter)
+		if err != nil {
+			log.Logf(log.LevelError, "unable to get audit events: %s", err.Error())
+			return wr.SendJsonErrorResponse(c, http.StatusInternalServerError, wr.RespErrUnknownInternal)
+		}
+		response := []wr.AuditEvent{}
+		for _, e := range events {
+			response = append(response, wr.AuditEvent{
+				ID:        e.ID,
+				ActorID:   e.ActorID,
+				OrgID:     e.OrgID,
+				Action:    e.Action,
+				Target:    e.Target,
+				IP:        e.IP,
+				UserAgent: e.UserAgent,
+				Diff:      json.RawMessage(e.Diff),
+				CreatedAt: e.CreatedAt,
+			})
+		}
+		return c.JSON(http.StatusOK, wr.JsonResponse[[]wr.AuditEvent]{ResponseID: wr.RespSccsGeneric, Data: response})
+	}
+}
+
+func auditFilterFromQuery(c echo.Context) (db.AuditFilter, error) {
+	filter := db.AuditFilter{Action: c.QueryParam("action")}
+	var err error
+	if actor := c.QueryParam("actor"); actor != "" {
+		if filter.ActorID, err = uuid.FromString(actor); err != nil {
+			return filter, errx.Wrap(err, "actor")
+		}
+	}
+	if org := c.QueryParam("org"); org != "" {
+		orgID, err := uuid.FromString(org)
+		if err != nil {
+			return filter, errx.Wrap(err, "org")
+		}
+		filter.OrgIDs = []uuid.UUID{orgID}
+	}
+	if from := c.QueryParam("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return filter, errx.Wrap(err, "from")
+		}
+	}
+	if to := c.QueryParam("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return filter, errx.Wrap(err, "to")
+		}
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			return filter, errx.Wrap(err, "limit")
+		}
+	}
+	return filter, nil
+}
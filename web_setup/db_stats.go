@@ -0,0 +1,31 @@
+package web_setup
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/log"
+	"gopkg.cc/apibase/web"
+	wr "gopkg.cc/apibase/web_response"
+)
+
+// Connection pool and per statement query statistics (calls, errors, latency histogram) of the database, only for super admins.
+// Empty if the store isn't a db.DB
+func GetDatabaseStats(api *web.ApiServer) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		accessClaims, err := web.GetAccessClaims(c, api, struct{}{})
+		if err != nil {
+			log.Logf(log.LevelError, "unable to get access claims for database stats: %s", err.Error())
+			return wr.SendJsonErrorResponse(c, http.StatusBadRequest, wr.RespErrGetAccessClaims)
+		}
+		if !accessClaims.SuperAdmin {
+			return wr.SendJsonErrorResponse(c, http.StatusForbidden, wr.RespErrForbidden)
+		}
+		stats := db.Stats{Queries: []db.QueryStat{}}
+		if database, ok := api.DB.(db.DB); ok {
+			stats = database.Stats()
+		}
+		return c.JSON(http.StatusOK, wr.JsonResponse[db.Stats]{ResponseID: wr.RespSccsGeneric, Data: stats})
+	}
+}
@@ -2,8 +2,8 @@ package web_setup
 
 import (
 	"context"
-	"encoding/base64"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
@@ -20,16 +20,30 @@ import (
 	wr "gopkg.cc/apibase/web_response"
 )
 
-func SetupRest(config web.ApiConfig, database db.DB, appVersion string) (*web.ApiServer, error) {
-	if err := db.ValidateDB(database); err != nil {
-		return nil, errx.Wrap(err, "unable to setup rest api")
-	}
-	if err := db.MigrateDefaultTables(database); err != nil {
-		return nil, errx.Wrap(err, "unable to migrate db tables")
+// Setup echo with all default endpoints, if store is a db.DB it is validated, migrated if BaseConfig.DatabaseAutoMigrate is set
+// (otherwise all migrations must be applied) and verified against the table structs
+func SetupRest(config web.ApiConfig, store db.Store, appVersion string) (*web.ApiServer, error) {
+	if database, ok := store.(db.DB); ok {
+		// startup isn't canceled, every step is bound by its configured timeout
+		ctx := context.Background()
+		if err := db.ValidateDB(ctx, database); err != nil {
+			return nil, errx.Wrap(err, "unable to setup rest api")
+		}
+		if database.BaseConfig.DatabaseAutoMigrate {
+			if err := db.MigrateUp(ctx, database); err != nil {
+				return nil, errx.Wrap(err, "unable to migrate db tables")
+			}
+		} else if err := checkMigrations(ctx, database); err != nil {
+			return nil, err
+		}
+		if err := db.CheckSchema(ctx, database); err != nil {
+			return nil, errx.Wrap(err, "unable to verify db tables")
+		}
 	}
-	tokenSecretBytes, err := base64.StdEncoding.DecodeString(config.TokenSecret.GetSecret())
-	if err != nil || len(tokenSecretBytes) < 64 {
-		return nil, errx.New("TokenSecret must be random base64 byte string with at least 64 bytes")
+	if config.TokenSecret.GetSecret() != "" || config.KeyringFile == "" {
+		if _, err := web.DecodeTokenSecret(config.TokenSecret.GetSecret()); err != nil {
+			return nil, err
+		}
 	}
 	if !config.LocalAuth && !config.OAuthEnabled {
 		return nil, errx.New("No Authentication method enabled, either LocalAuth, OAuthEnabled or both need to be enabled")
@@ -37,15 +51,16 @@ func SetupRest(config web.ApiConfig, database db.DB, appVersion string) (*web.Ap
 	if err := config.ValidateApiRoot(); err != nil {
 		return nil, err
 	}
-	// Verify AppURI can be parsed to *url.URL, this panics if can't be parsed
-	_ = config.AppUri()
+	if _, err := config.ParseAppUri(); err != nil {
+		return nil, err
+	}
 
 	api := &web.ApiServer{
 		E: echo.New(),
 		// Api: will be set by RegisterRestDefaultEndpoints()
 		Kind:   web.REST,
 		Config: config,
-		DB:     database,
+		DB:     store,
 	}
 	if len(config.CORS) < 1 {
 		log.Log(log.LevelWarning, "CORS is not set, assuming '*', this should not be used in a production environment!")
@@ -58,6 +73,22 @@ func SetupRest(config web.ApiConfig, database db.DB, appVersion string) (*web.Ap
 	if err := api.Config.Settings.AddMissingFromDefaults(); err != nil {
 		return nil, err
 	}
+	api.Reload(api.Config.CORS, api.Config.Settings)
+	if api.Config.TokenSecret.GetSecret() != "" {
+		if err := api.SetTokenSecret(api.Config.TokenSecret.GetSecret()); err != nil {
+			return nil, err
+		}
+	}
+	if err := api.LoadKeyringFile(); err != nil {
+		return nil, err
+	}
+	api.Config.TokenSecret.OnChange(func(secret string) {
+		if err := api.SetTokenSecret(secret); err != nil {
+			log.Logf(log.LevelError, "rotated token secret rejected, keeping current token secret: %s", err.Error())
+			return
+		}
+		log.Log(log.LevelNotice, "token secret rotated, previously issued tokens are invalid")
+	})
 
 	api.E.HideBanner = true
 	api.E.HidePort = true
@@ -67,8 +98,9 @@ func SetupRest(config web.ApiConfig, database db.DB, appVersion string) (*web.Ap
 		LogErrorFunc: log.EchoLogPanicStacktrace,
 	}))
 	api.E.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: api.Config.CORS,
+		AllowOriginFunc: api.AllowOrigin,
 	}))
+	api.E.Use(web.PinPrimaryAfterWrite())
 	RegisterRestDefaultEndpoints(api, appVersion)
 	if api.Config.LocalAuth {
 		web_auth.RegisterAuthEndpoints(api)
@@ -79,6 +111,24 @@ func SetupRest(config web.ApiConfig, database db.DB, appVersion string) (*web.Ap
 	return api, nil
 }
 
+// schema changes aren't applied implicitly, e.g. migration uuid_keys rewrites the keys of existing tables
+func checkMigrations(ctx context.Context, database db.DB) error {
+	status, err := db.MigrationsStatus(ctx, database)
+	if err != nil {
+		return errx.Wrap(err, "unable to get db migration status")
+	}
+	pending := []string{}
+	for _, m := range status {
+		if m.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%s/%d_%s", m.Source, m.Version, m.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return errx.NewWithTypef(db.ErrDatabaseMigration, "pending migrations %s, apply them with cli command 'migrate up' or set db_auto_migrate = true in [baseconfig]", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
 func RegisterRestDefaultEndpoints(api *web.ApiServer, appVersion string) {
 	switch api.Config.ApiRoot.Kind {
 	case web.FsLocal:
@@ -134,6 +184,9 @@ func RegisterRestDefaultEndpoints(api *web.ApiServer, appVersion string) {
 		return c.JSON(http.StatusOK, wr.JsonResponse[struct{}]{Message: "Welcome!"})
 	})
 	apiGroup.GET("check_login", CheckLogin(api))
+	apiGroup.GET("audit_events", GetAuditEvents(api))
+	apiGroup.GET("orgs/:org/scheduled_tasks", ListScheduledTasks(api))
+	apiGroup.GET("db_stats", GetDatabaseStats(api))
 	api.Api = apiGroup
 }
 
@@ -162,32 +215,9 @@ func StartRestBlocking(api *web.ApiServer, bind string) error {
 	return nil
 }
 
-// start rest api server, is non-blocking, require shutdown and next channels for clean shutdown, these can be created using base.ApiBase[T].GetCloseStageChannels()
-func StartRest(api *web.ApiServer, bind string, shutdown chan struct{}, next chan struct{}) error {
-	abort := make(chan struct{})
-
-	go func() { // echo shutdown
-		select {
-		case <-shutdown:
-		case <-abort:
-			return
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), api.Config.Settings.TimeoutSubprocShutdown)
-		defer cancel()
-		err := api.E.Shutdown(ctx)
-		if err != nil {
-			log.Logf(log.LevelError, "%s", errx.WrapWithType(ErrWebShutdown, err, "").Error())
-		} else {
-			log.Log(log.LevelNotice, "Rest API Server shutdown successful.")
-		}
-		select {
-		case <-next:
-			log.Log(log.LevelError, "rest api next channel already closed, make sure channel staging is done correctly")
-		default:
-			close(next)
-		}
-	}()
-
+// start rest api server, is non-blocking, requires ShutdownRest() for clean shutdown,
+// which is done automatically if base.ApiBase[T].StartRest() is used
+func StartRest(api *web.ApiServer, bind string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), api.Config.Settings.TimeoutSubprocStartup)
 	defer cancel()
 	startupError := struct {
@@ -210,7 +240,6 @@ func StartRest(api *web.ApiServer, bind string, shutdown chan struct{}, next cha
 	select {
 	case err := <-startupError.Chan:
 		if err != nil {
-			close(abort)
 			return err
 		}
 	case <-ctx.Done():
@@ -223,6 +252,16 @@ func StartRest(api *web.ApiServer, bind string, shutdown chan struct{}, next cha
 	return nil
 }
 
+// gracefully shutdown rest api server, waits for active requests until ctx is done
+func ShutdownRest(ctx context.Context, api *web.ApiServer) error {
+	err := api.E.Shutdown(ctx)
+	if err != nil {
+		return errx.WrapWithType(ErrWebShutdown, err, "")
+	}
+	log.Log(log.LevelNotice, "Rest API Server shutdown successful.")
+	return nil
+}
+
 // func SetupStatic(root string) *ApiServer {
 // 	if root == "" { // TODO: validate root is path to folder
 // 		log.Panic(log.ErrEmptyString, "root '%s' must be valid path to folder", root)
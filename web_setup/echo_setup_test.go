@@ -0,0 +1,225 @@
+package web_setup_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.cc/apibase/baseconfig"
+	"gopkg.cc/apibase/db"
+	h "gopkg.cc/apibase/helper"
+	"gopkg.cc/apibase/table"
+	"gopkg.cc/apibase/web"
+	wr "gopkg.cc/apibase/web_response"
+	"gopkg.cc/apibase/web_setup"
+)
+
+func TestAuthEndpoints(t *testing.T) {
+	store := db.NewMemoryStore()
+	config := web.ApiConfig{
+		AppURI:      "http://localhost:3000",
+		TokenSecret: h.CreateSecretString(web.GenerateTokenSecret()),
+		LocalAuth:   true,
+		ApiRoot:     web.RootOptions{Kind: web.FsLocal},
+	}
+	api, err := web_setup.SetupRest(config, store, "test")
+	if err != nil {
+		t.Fatalf("SetupRest() error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		form     url.Values
+		status   int
+		response wr.ResponseId
+	}{
+		{"signup", "/auth/signup", url.Values{"username": {"alice"}, "email": {"alice@example.com"}, "password": {"secret"}, "password-confirm": {"secret"}}, http.StatusOK, wr.RespSccsSignup},
+		{"signup again", "/auth/signup", url.Values{"username": {"alice"}, "email": {"alice@example.com"}, "password": {"secret"}, "password-confirm": {"secret"}}, http.StatusConflict, wr.RespErrSignupUserExists},
+		{"login", "/auth/login", url.Values{"email": {"alice@example.com"}, "password": {"secret"}}, http.StatusOK, wr.RespSccsLogin},
+		{"wrong password", "/auth/login", url.Values{"email": {"alice@example.com"}, "password": {"wrong"}}, http.StatusUnauthorized, wr.RespErrLoginWrongPassword},
+		{"unknown user", "/auth/login", url.Values{"email": {"bob@example.com"}, "password": {"secret"}}, http.StatusUnauthorized, wr.RespErrLoginNoUser},
+	}
+	var loginCookies []*http.Cookie
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-XSRF-TOKEN", "csrf")
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: "csrf"})
+		rec := httptest.NewRecorder()
+		api.E.ServeHTTP(rec, req)
+
+		response := wr.JsonResponse[struct{}]{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: invalid response '%s': %v", tt.name, rec.Body.String(), err)
+		}
+		if rec.Code != tt.status || response.ResponseID != tt.response {
+			t.Errorf("%s: got %d %s, want %d %s", tt.name, rec.Code, response.ResponseID, tt.status, tt.response)
+		}
+		if tt.response == wr.RespSccsLogin {
+			loginCookies = rec.Result().Cookies()
+		}
+	}
+
+	user, err := store.GetUserByEmail(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail() error: %v", err)
+	}
+	for action, want := range map[string]int{table.AuditSignup: 1, table.AuditLogin: 2, table.AuditLoginFailed: 1, table.AuditRoleGrant: 1} {
+		if events, err := store.GetAuditEvents(context.Background(), db.AuditFilter{Action: action}); err != nil || len(events) != want {
+			t.Errorf("GetAuditEvents() of action '%s' = %d events, %v, want %d", action, len(events), err, want)
+		}
+	}
+	roles, _ := store.GetUserRoles(context.Background(), user.ID)
+	auditTests := []struct {
+		name   string
+		query  string
+		status int
+		events int
+	}{
+		{"own org", "", http.StatusOK, 1},
+		{"own org by id", "?org=" + roles[0].OrgID.String() + "&action=" + table.AuditRoleGrant, http.StatusOK, 1},
+		{"other org", "?org=" + table.NewID().String(), http.StatusForbidden, 0},
+		{"invalid time", "?from=yesterday", http.StatusUnprocessableEntity, 0},
+	}
+	for _, tt := range auditTests {
+		req := httptest.NewRequest(http.MethodGet, "/api/audit_events"+tt.query, nil)
+		req.Header.Set("X-XSRF-TOKEN", "csrf")
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: "csrf"})
+		for _, cookie := range loginCookies {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		api.E.ServeHTTP(rec, req)
+
+		// error responses have an empty object as data
+		response, events := wr.JsonResponse[json.RawMessage]{}, []wr.AuditEvent{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: invalid response '%s': %v", tt.name, rec.Body.String(), err)
+		}
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(response.Data, &events); err != nil {
+				t.Fatalf("%s: invalid events '%s': %v", tt.name, response.Data, err)
+			}
+		}
+		if rec.Code != tt.status || len(events) != tt.events {
+			t.Errorf("%s: got %d with %d events, want %d with %d events", tt.name, rec.Code, len(events), tt.status, tt.events)
+		}
+	}
+
+	for _, taskID := range []string{"a", "b", "c"} {
+		if err := store.CreateScheduledTask(context.Background(), table.ScheduledTask{TaskID: taskID, OrgID: roles[0].OrgID, TaskType: "report", TaskData: "{}"}); err != nil {
+			t.Fatalf("CreateScheduledTask() error: %v", err)
+		}
+	}
+	cursor := ""
+	taskTests := []struct {
+		name   string
+		path   string
+		status int
+		tasks  int
+		next   bool
+	}{
+		{"first page", "/api/orgs/" + roles[0].OrgID.String() + "/scheduled_tasks?limit=2", http.StatusOK, 2, true},
+		{"next page", "/api/orgs/" + roles[0].OrgID.String() + "/scheduled_tasks?limit=2&cursor=", http.StatusOK, 1, false},
+		{"filter", "/api/orgs/" + roles[0].OrgID.String() + "/scheduled_tasks?task_type=cleanup", http.StatusOK, 0, false},
+		{"other org", "/api/orgs/" + table.NewID().String() + "/scheduled_tasks", http.StatusForbidden, 0, false},
+		{"invalid filter", "/api/orgs/" + roles[0].OrgID.String() + "/scheduled_tasks?task_data=x", http.StatusUnprocessableEntity, 0, false},
+	}
+	for _, tt := range taskTests {
+		path := tt.path
+		if strings.HasSuffix(path, "cursor=") {
+			path += cursor
+		}
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-XSRF-TOKEN", "csrf")
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: "csrf"})
+		for _, cookie := range loginCookies {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		api.E.ServeHTTP(rec, req)
+
+		response, tasks := wr.JsonResponse[json.RawMessage]{}, []wr.ScheduledTask{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: invalid response '%s': %v", tt.name, rec.Body.String(), err)
+		}
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(response.Data, &tasks); err != nil || response.Page == nil {
+				t.Fatalf("%s: invalid page '%s': %v", tt.name, rec.Body.String(), err)
+			}
+			cursor = response.Page.NextCursor
+		}
+		if rec.Code != tt.status || len(tasks) != tt.tasks || (rec.Code == http.StatusOK && (cursor != "") != tt.next) {
+			t.Errorf("%s: got %d with %d tasks and next cursor '%s', want %d with %d tasks", tt.name, rec.Code, len(tasks), cursor, tt.status, tt.tasks)
+		}
+	}
+
+	if sessions, _ := store.DeleteRefreshTokens(context.Background(), user.ID); sessions != 2 {
+		t.Errorf("sessions after signup and login = %d, want 2", sessions)
+	}
+}
+
+func TestKeyringOnlyCSRF(t *testing.T) {
+	keyring := &web.Keyring{}
+	keyring.Add(time.Now().Add(-time.Minute), time.Time{})
+	keyringFile := filepath.Join(t.TempDir(), "keyring.toml")
+	if err := keyring.Save(keyringFile); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	config := web.ApiConfig{
+		AppURI:      "http://localhost:3000",
+		KeyringFile: keyringFile,
+		LocalAuth:   true,
+		ApiRoot:     web.RootOptions{Kind: web.FsLocal},
+	}
+	api, err := web_setup.SetupRest(config, db.NewMemoryStore(), "test")
+	if err != nil {
+		t.Fatalf("SetupRest() error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	api.E.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/csrf_token", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /auth/csrf_token = %d, want %d", rec.Code, http.StatusOK)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "csrf_token" && cookie.Value != "" {
+			return
+		}
+	}
+	t.Errorf("GET /auth/csrf_token didn't set csrf_token cookie: %v", rec.Result().Cookies())
+}
+
+func TestSetupRestMigrations(t *testing.T) {
+	bc := baseconfig.BaseConfig{}
+	if err := bc.AddMissingFromDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	database, err := db.SQLiteInit(context.Background(), db.SQLiteConfig{FilePath: filepath.Join(t.TempDir(), "test.db")}, &bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close(context.Background())
+	config := web.ApiConfig{
+		AppURI:      "http://localhost:3000",
+		TokenSecret: h.CreateSecretString(web.GenerateTokenSecret()),
+		LocalAuth:   true,
+		ApiRoot:     web.RootOptions{Kind: web.FsLocal},
+	}
+
+	if _, err := web_setup.SetupRest(config, database, "test"); !errors.Is(err, db.ErrDatabaseMigration) {
+		t.Errorf("SetupRest() with pending migrations = %v, want ErrDatabaseMigration", err)
+	}
+	bc.DatabaseAutoMigrate = true
+	if _, err := web_setup.SetupRest(config, database, "test"); err != nil {
+		t.Errorf("SetupRest() with db_auto_migrate error: %v", err)
+	}
+}
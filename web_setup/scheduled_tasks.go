@@ -0,0 +1,62 @@
+package web_setup
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/labstack/echo/v4"
+	"gopkg.cc/apibase/db"
+	"gopkg.cc/apibase/log"
+	"gopkg.cc/apibase/table"
+	"gopkg.cc/apibase/web"
+	wr "gopkg.cc/apibase/web_response"
+)
+
+// Paginated scheduled tasks of the organization in the path param org, requires view permission for it.
+// Query params: cursor, limit, sort (created_at, updated_at, start_date, task_id) and the filters task_id and task_type
+func ListScheduledTasks(api *web.ApiServer) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		accessClaims, err := web.GetAccessClaims(c, api, struct{}{})
+		if err != nil {
+			log.Logf(log.LevelError, "unable to get access claims for scheduled tasks: %s", err.Error())
+			return wr.SendJsonErrorResponse(c, http.StatusBadRequest, wr.RespErrGetAccessClaims)
+		}
+		orgID, err := uuid.FromString(c.Param("org"))
+		if err != nil {
+			return wr.SendJsonErrorResponse(c, http.StatusUnprocessableEntity, wr.RespErrInvalidInput)
+		}
+		if !accessClaims.SuperAdmin && !accessClaims.Roles[orgID].OrgView {
+			return wr.SendJsonErrorResponse(c, http.StatusForbidden, wr.RespErrForbidden)
+		}
+		q, err := web.BindListQuery(c)
+		if err != nil {
+			log.Logf(log.LevelDebug, "invalid scheduled tasks query: %s", err.Error())
+			return wr.SendJsonErrorResponse(c, http.StatusUnprocessableEntity, wr.RespErrInvalidInput)
+		}
+
+		page, err := api.DB.ListScheduledTasks(c.Request().Context(), orgID, q)
+		if errors.Is(err, db.ErrInvalidListQuery) {
+			log.Logf(log.LevelDebug, "invalid scheduled tasks query: %s", err.Error())
+			return wr.SendJsonErrorResponse(c, http.StatusUnprocessableEntity, wr.RespErrInvalidInput)
+		}
+		if err != nil {
+			log.Logf(log.LevelError, "unable to list scheduled tasks of org (id: %s): %s", orgID, err.Error())
+			return wr.SendJsonErrorResponse(c, http.StatusInternalServerError, wr.RespErrUnknownInternal)
+		}
+		return web.SendPage(c, page, func(t table.ScheduledTask) wr.ScheduledTask {
+			return wr.ScheduledTask{
+				ID:        t.ID,
+				TaskID:    t.TaskID,
+				OrgID:     t.OrgID,
+				StartDate: t.StartDate,
+				Interval:  time.Duration(t.Interval),
+				TaskType:  t.TaskType,
+				TaskData:  t.TaskData,
+				CreatedAt: t.CreatedAt,
+				UpdatedAt: t.UpdatedAt,
+			}
+		})
+	}
+}